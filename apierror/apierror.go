@@ -0,0 +1,133 @@
+// Package apierror 定义 API 对外承诺的错误码目录，以及携带 HTTP 状态码与错误码的错误类型。
+// 错误码是稳定的契约：客户端应按 error_code 判断错误类型，而不是匹配 message 文本。
+package apierror
+
+import (
+	"golang-starter/i18n"
+	"golang-starter/validation"
+)
+
+// Code 是机器可读的应用错误码。
+type Code string
+
+// 错误码目录：新增错误码只能追加，已发布的错误码不能修改含义或删除。
+const (
+	// 通用请求错误
+	BadRequest           Code = "BAD_REQUEST"
+	InvalidJSON          Code = "INVALID_JSON"
+	InvalidID            Code = "INVALID_ID"
+	InvalidPagination    Code = "INVALID_PAGINATION"
+	InvalidParameter     Code = "INVALID_PARAMETER"
+	MethodNotAllowed     Code = "METHOD_NOT_ALLOWED"
+	UnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	PayloadTooLarge      Code = "PAYLOAD_TOO_LARGE"
+	NotFound             Code = "NOT_FOUND"
+	Conflict             Code = "CONFLICT"
+
+	// 校验与更新
+	ValidationFailed Code = "VALIDATION_FAILED"
+	NoFieldsToUpdate Code = "NO_FIELDS_TO_UPDATE"
+	ReadOnlyField    Code = "READ_ONLY_FIELD"
+	InvalidPatch     Code = "INVALID_PATCH"
+	PatchCannotApply Code = "PATCH_CANNOT_APPLY"
+	PatchTestFailed  Code = "PATCH_TEST_FAILED"
+
+	// 认证与授权
+	Unauthorized   Code = "UNAUTHORIZED"
+	Forbidden      Code = "FORBIDDEN"
+	APIKeyNotFound Code = "API_KEY_NOT_FOUND"
+	APIKeyRevoked  Code = "API_KEY_REVOKED"
+
+	// 产品
+	ProductNotFound Code = "PRODUCT_NOT_FOUND"
+
+	// 租户
+	InvalidTenant  Code = "INVALID_TENANT"
+	TenantMismatch Code = "TENANT_MISMATCH"
+	QuotaExceeded  Code = "QUOTA_EXCEEDED"
+
+	// 异步任务
+	JobNotFound        Code = "JOB_NOT_FOUND"
+	JobNotFinished     Code = "JOB_NOT_FINISHED"
+	JobAlreadyFinished Code = "JOB_ALREADY_FINISHED"
+	JobResultNotFound  Code = "JOB_RESULT_NOT_FOUND"
+
+	// 幂等请求
+	IdempotencyKeyInvalid    Code = "IDEMPOTENCY_KEY_INVALID"
+	IdempotencyKeyReused     Code = "IDEMPOTENCY_KEY_REUSED"
+	IdempotencyKeyInProgress Code = "IDEMPOTENCY_KEY_IN_PROGRESS"
+
+	// 限流
+	RateLimited Code = "RATE_LIMITED"
+
+	// 备份
+	BackupNotConfigured Code = "BACKUP_NOT_CONFIGURED"
+
+	// 请求被取消或超时
+	RequestCanceled Code = "REQUEST_CANCELED"
+	RequestTimeout  Code = "REQUEST_TIMEOUT"
+
+	// 服务端错误：具体原因只记录在日志里，不返回给客户端。
+	Internal Code = "INTERNAL_ERROR"
+)
+
+// Error 是可以直接写给客户端的错误：HTTP 状态码 + 错误码 + 消息参数。
+// 可读信息不在这里写死，而是按请求语言从 i18n 消息目录中渲染。
+type Error struct {
+	Status int
+	Code   Code
+	// Variant：同一错误码需要不同文案时使用，消息 key 为 "Code.Variant"；为空时 key 就是 Code。
+	Variant string
+	// Params：消息中占位符的参数，例如 {"param": "limit"}。
+	Params map[string]string
+	// Fields：字段级校验错误（仅 VALIDATION_FAILED 时出现）。
+	Fields validation.Errors
+	// Err：原始错误，只用于日志与 errors.Is/As，不会返回给客户端。
+	Err error
+}
+
+// New 创建一个错误；params 为成对的占位符名与值，例如 New(400, InvalidPagination, "param", "limit")。
+func New(status int, code Code, params ...string) *Error {
+	return NewVariant(status, code, "", params...)
+}
+
+// NewVariant 与 New 相同，但使用错误码下的某个文案变体。
+func NewVariant(status int, code Code, variant string, params ...string) *Error {
+	e := &Error{Status: status, Code: code, Variant: variant}
+	if len(params) > 0 {
+		e.Params = make(map[string]string, len(params)/2)
+		for i := 0; i+1 < len(params); i += 2 {
+			e.Params[params[i]] = params[i+1]
+		}
+	}
+	return e
+}
+
+// Wrap 把 err 的文本作为 {detail} 参数创建错误，并保留原始错误。
+// 只应用于可以安全暴露给客户端的错误（例如请求体解析错误）。
+func Wrap(status int, code Code, err error) *Error {
+	e := New(status, code, "detail", err.Error())
+	e.Err = err
+	return e
+}
+
+// Key 返回错误在消息目录中的 key。
+func (e *Error) Key() string {
+	if e.Variant == "" {
+		return string(e.Code)
+	}
+	return string(e.Code) + "." + e.Variant
+}
+
+// Message 按 lang 渲染可读信息。
+func (e *Error) Message(lang i18n.Lang) string {
+	return i18n.Format(lang, e.Key(), e.Params)
+}
+
+func (e *Error) Error() string {
+	return e.Message(i18n.Default)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,214 @@
+// Package auth 实现 API 密钥认证与基于角色的授权。
+//
+// 密钥形如 "gsk_" + 43 位 base64url 随机串，数据库中只保存其 SHA-256 哈希；
+// 密钥本身有 256 位随机熵，因此不需要加盐或慢哈希。
+// 角色按权限从低到高为 viewer < editor < admin，高权限角色包含低权限角色的全部能力。
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+// Role 是访问角色。
+type Role string
+
+const (
+	// Viewer：只读访问。
+	Viewer Role = "viewer"
+	// Editor：可以创建、修改、删除产品，以及取消任务。
+	Editor Role = "editor"
+	// Admin：在 Editor 基础上可以管理 API 密钥。
+	Admin Role = "admin"
+)
+
+var roleRank = map[Role]int{Viewer: 1, Editor: 2, Admin: 3}
+
+// ParseRole 解析角色名。
+func ParseRole(name string) (Role, bool) {
+	role := Role(name)
+	_, ok := roleRank[role]
+	return role, ok
+}
+
+// Allows 判断当前角色是否满足 required 的要求。
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+// KeyPrefix 是所有 API 密钥的固定前缀，便于在日志、代码仓库扫描中识别泄露的密钥。
+const KeyPrefix = "gsk_"
+
+// LastUsedInterval：last_used_at 的最小更新间隔，避免每个请求都写一次数据库。
+var LastUsedInterval = time.Minute
+
+// ErrInvalidCredentials 表示凭证不存在、格式不对或已被吊销。
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrInvalidPlatformKey 表示试图创建绑定租户或非 admin 的平台级密钥。
+var ErrInvalidPlatformKey = errors.New("platform keys must be unbound admin keys")
+
+// Principal 是通过认证的调用方。
+type Principal struct {
+	// Subject：调用方标识，例如 "apikey:3"。
+	Subject string
+	Role    Role
+	// APIKeyID：使用 API 密钥认证时的密钥 ID。
+	APIKeyID int
+	// Tenant：调用方绑定的租户；为空且不是平台级调用方时只能访问默认租户。
+	Tenant string
+	// Platform：平台级调用方，可以由请求指定任意租户，并管理密钥与租户配额。
+	// 只有显式标记的凭证才是平台级的（平台级 API 密钥，或带平台 claim 的 admin token），不绑定租户并不代表平台级。
+	Platform bool
+}
+
+type principalKey struct{}
+
+// WithPrincipal 把调用方写入 context。
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 取出 WithPrincipal 写入的调用方。
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// GenerateKey 生成一把新的明文密钥。
+func GenerateKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashKey 计算明文密钥的哈希，即数据库中保存的值。
+func HashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// displayPrefix 截取明文密钥的前几位用于展示。
+func displayPrefix(plaintext string) string {
+	n := len(KeyPrefix) + 6
+	if len(plaintext) < n {
+		return plaintext
+	}
+	return plaintext[:n]
+}
+
+// CreateKey 生成并保存一把新密钥，返回密钥记录与明文（明文只在此时可见）。
+// tenantID 非空时密钥只能访问该租户；platform 为 true 时创建平台级密钥，要求 role 为 admin 且不绑定租户。
+func CreateKey(ctx context.Context, db *sql.DB, name string, role Role, tenantID string, platform bool) (*models.APIKey, string, error) {
+	if platform && (role != Admin || tenantID != "") {
+		return nil, "", ErrInvalidPlatformKey
+	}
+	plaintext, err := GenerateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := models.CreateAPIKey(ctx, db, name, string(role), tenantID, platform, displayPrefix(plaintext), HashKey(plaintext))
+	if err != nil {
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
+
+// RotateKey 为已有密钥生成新的明文，旧明文立即失效。
+func RotateKey(ctx context.Context, db *sql.DB, id int) (*models.APIKey, string, error) {
+	plaintext, err := GenerateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := models.RotateAPIKey(ctx, db, id, displayPrefix(plaintext), HashKey(plaintext))
+	if err != nil {
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
+
+// AuthenticateKey 校验明文密钥，返回对应的调用方；密钥无效或已吊销时返回 ErrInvalidCredentials。
+// 查找密钥使用 read（每个请求都要查，不应占用唯一的写连接），只有更新 last_used_at 使用 write。
+func AuthenticateKey(ctx context.Context, read, write *sql.DB, plaintext string) (*Principal, error) {
+	if !strings.HasPrefix(plaintext, KeyPrefix) {
+		return nil, ErrInvalidCredentials
+	}
+
+	key, err := models.GetAPIKeyByHash(ctx, read, HashKey(plaintext))
+	if errors.Is(err, models.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidCredentials
+	} else if err != nil {
+		return nil, err
+	}
+	if key.Revoked() {
+		return nil, ErrInvalidCredentials
+	}
+	role, ok := ParseRole(key.Role)
+	if !ok {
+		return nil, fmt.Errorf("api key %d has unknown role %q", key.ID, key.Role)
+	}
+
+	now := time.Now().UTC()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= LastUsedInterval {
+		if err := models.TouchAPIKey(ctx, write, key.ID, now); err != nil {
+			log.Printf("update api key %d last_used_at failed: %v", key.ID, err)
+		}
+	}
+
+	return &Principal{
+		Subject:  fmt.Sprintf("apikey:%d", key.ID),
+		Role:     role,
+		APIKeyID: key.ID,
+		Tenant:   key.TenantID,
+		Platform: key.Platform,
+	}, nil
+}
+
+// EnsureAdminKey 保证至少存在一把可用的平台级 admin 密钥，用于首次部署时引导：
+// 已有未吊销的平台级密钥时什么都不做；否则用 plaintext（为空时随机生成）创建一把，并返回其明文。
+// 检查与创建在同一个事务中，多个实例同时启动也只会创建一把。
+func EnsureAdminKey(ctx context.Context, db *sql.DB, plaintext string) (string, error) {
+	if plaintext == "" {
+		var err error
+		if plaintext, err = GenerateKey(); err != nil {
+			return "", err
+		}
+	} else if !strings.HasPrefix(plaintext, KeyPrefix) {
+		return "", fmt.Errorf("admin key must start with %q", KeyPrefix)
+	}
+
+	created := false
+	err := utils.RunTx(ctx, db, func(tx *sql.Tx) error {
+		count, err := models.CountActivePlatformKeys(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			created = false
+			return nil
+		}
+		if _, err := models.CreateAPIKey(ctx, tx, "bootstrap admin", string(Admin), "", true, displayPrefix(plaintext), HashKey(plaintext)); err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if err != nil || !created {
+		return "", err
+	}
+	return plaintext, nil
+}
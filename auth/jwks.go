@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeySet 是从本地文件或 URL 加载的 JWKS（RFC 7517），用于校验 JWT 签名。
+//
+// 密钥缓存在内存中：
+//   - 超过有效期（URL 响应的 Cache-Control max-age，否则为 RefreshInterval）后，下次使用时在后台重新加载，
+//     该请求与加载期间的其他请求继续使用已缓存的密钥
+//   - 遇到未知的 kid（签发方轮换了密钥）时立即重新加载，并等待加载完成
+//
+// 两种情况下两次加载都至少间隔 MinRefreshInterval；并发的请求共享同一次加载，加载期间不阻塞使用已缓存密钥的请求。
+// 重新加载失败时继续使用旧密钥，并记录日志。
+type KeySet struct {
+	// RefreshInterval：缓存有效期。
+	RefreshInterval time.Duration
+	// MinRefreshInterval：因未知 kid 触发重新加载的最小间隔，避免伪造 kid 的请求反复拉取 JWKS。
+	MinRefreshInterval time.Duration
+
+	source string
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]*verificationKey
+	expiresAt   time.Time
+	lastAttempt time.Time
+	inflight    *refreshCall
+}
+
+// verificationKey 是解析后的单个 JWK。
+type verificationKey struct {
+	id string
+	// alg：JWK 中声明的算法（可选）；声明了时只接受该算法签名的 token。
+	alg string
+	// key：*rsa.PublicKey、*ecdsa.PublicKey 或 HMAC 共享密钥 []byte。
+	key any
+}
+
+// jsonWebKey 是 JWK 的 JSON 结构，只包含用到的成员。
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// oct（HMAC）
+	K string `json:"k"`
+}
+
+// LoadKeySet 从 source 加载 JWKS：以 http:// 或 https:// 开头时按 URL 拉取，否则按本地文件读取。
+// 首次加载失败直接返回错误。
+func LoadKeySet(source string) (*KeySet, error) {
+	s := &KeySet{
+		RefreshInterval:    time.Hour,
+		MinRefreshInterval: time.Minute,
+		source:             source,
+		client:             &http.Client{Timeout: 10 * time.Second},
+	}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Refresh 立即重新加载 JWKS；失败时保留已有密钥。
+func (s *KeySet) Refresh() error {
+	return s.refresh(time.Now())
+}
+
+// refreshCall 是一次进行中的加载；并发的调用等待 done 关闭后共享 err。
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// refresh 加载 JWKS 并等待加载完成；已有加载正在进行时等待它并共享结果。
+func (s *KeySet) refresh(now time.Time) error {
+	s.mu.Lock()
+	call := s.startRefreshLocked(now)
+	s.mu.Unlock()
+
+	<-call.done
+	return call.err
+}
+
+// startRefreshLocked 在后台开始一次加载并返回它；已有加载正在进行时直接返回该加载，同一时间只有一次加载。
+// 调用方持有 s.mu。
+func (s *KeySet) startRefreshLocked(now time.Time) *refreshCall {
+	if s.inflight != nil {
+		return s.inflight
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	s.inflight = call
+	s.lastAttempt = now
+	go s.runRefresh(call, now)
+	return call
+}
+
+// runRefresh 执行加载并替换密钥；拉取与解析不持有 s.mu，只在替换密钥时加锁，已缓存的密钥在加载期间仍然可以使用。
+func (s *KeySet) runRefresh(call *refreshCall, now time.Time) {
+	keys, ttl, err := s.load()
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+		s.expiresAt = now.Add(ttl)
+	} else if s.keys != nil {
+		log.Printf("refresh jwks failed, keeping cached keys: %v", err)
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+}
+
+// load 拉取并解析 JWKS，返回密钥与缓存有效期。
+func (s *KeySet) load() (map[string]*verificationKey, time.Duration, error) {
+	data, maxAge, err := s.fetch()
+	if err != nil {
+		return nil, 0, fmt.Errorf("load jwks from %s: %w", s.source, err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("load jwks from %s: %w", s.source, err)
+	}
+
+	ttl := s.RefreshInterval
+	if maxAge > 0 {
+		ttl = maxAge
+	}
+	return keys, ttl, nil
+}
+
+// fetch 读取 JWKS 原文；URL 响应带 Cache-Control: max-age 时一并返回。
+func (s *KeySet) fetch() ([]byte, time.Duration, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		data, err := os.ReadFile(s.source)
+		return data, 0, err
+	}
+
+	resp, err := s.client.Get(s.source)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, cacheMaxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+func cacheMaxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age="); ok {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return 0
+}
+
+// lookup 按 kid 查找密钥；kid 为空且只有一把密钥时直接使用它。
+// 缓存过期时在后台重新加载并继续使用已缓存的密钥；只有找不到密钥（未知的 kid，或从未加载成功）时才等待加载完成。
+func (s *KeySet) lookup(kid string) (*verificationKey, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	if now.After(s.expiresAt) && s.canRefreshLocked(now) {
+		s.startRefreshLocked(now)
+	}
+	key := s.find(kid)
+	retry := key == nil && (kid != "" || len(s.keys) == 0) && s.canRefreshLocked(now)
+	s.mu.Unlock()
+	if key != nil {
+		return key, nil
+	}
+	if retry {
+		// 加载失败时 runRefresh 已记录日志并保留旧密钥，这里只需要再找一次。
+		_ = s.refresh(now)
+		s.mu.Lock()
+		key = s.find(kid)
+		s.mu.Unlock()
+		if key != nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidCredentials, kid)
+}
+
+// canRefreshLocked 判断现在能否重新加载：距上次加载至少 MinRefreshInterval，或者已有加载正在进行（等待它不会多拉取一次）。
+// 过期后的重新加载同样受此限制，JWKS 地址不可用时不会每个请求都去拉取。调用方持有 s.mu。
+func (s *KeySet) canRefreshLocked(now time.Time) bool {
+	return s.inflight != nil || now.Sub(s.lastAttempt) >= s.MinRefreshInterval
+}
+
+func (s *KeySet) find(kid string) *verificationKey {
+	if kid == "" {
+		if len(s.keys) == 1 {
+			for _, key := range s.keys {
+				return key
+			}
+		}
+		return nil
+	}
+	return s.keys[kid]
+}
+
+// parseJWKS 解析 {"keys": [...]}；跳过不是用于签名（use != "sig"）或类型不支持的密钥。
+func parseJWKS(data []byte) (map[string]*verificationKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*verificationKey{}
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("key %d (%s): %w", i, jwk.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing keys")
+	}
+	return keys, nil
+}
+
+// parseJWK 把单个 JWK 转换为 Go 的密钥类型；不支持的 kty 返回 (nil, nil)。
+func parseJWK(jwk jsonWebKey) (*verificationKey, error) {
+	key := &verificationKey{id: jwk.Kid, alg: jwk.Alg}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid e")
+		}
+		key.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		key.key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil || len(secret) == 0 {
+			return nil, errors.New("invalid k")
+		}
+		key.key = secret
+	default:
+		return nil, nil
+	}
+	return key, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
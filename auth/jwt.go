@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// JWTVerifier 校验 SSO 签发的 JWT（RS256 / ES256 / HS256），并把 claims 映射为 Principal。
+type JWTVerifier struct {
+	// Keys：用于校验签名的 JWKS。
+	Keys *KeySet
+	// Issuer / Audience：非空时要求 iss 相等、aud 包含该值。
+	Issuer   string
+	Audience string
+	// RoleClaim：承载角色的 claim 名，值可以是字符串（空格分隔）或字符串数组；为空时使用 "roles"。
+	RoleClaim string
+	// RoleMapping：claim 中的值到角色的映射，例如 {"product-admins": Admin}；
+	// 为 nil 时直接识别 viewer / editor / admin。多个值映射出多个角色时取权限最高的一个。
+	RoleMapping map[string]Role
+	// TenantClaim：承载租户的 claim 名（字符串）；设置后非平台级 token 必须带有非空的该 claim，否则视为无效凭证。
+	// 为空时 token 不绑定租户，非平台级 token 只能访问默认租户。
+	TenantClaim string
+	// PlatformClaim：标记平台级 token 的 claim 名（值为 true）；只有 admin 且不带租户 claim 的 token 才能成为平台级调用方。
+	// 为空时没有平台级 token。
+	PlatformClaim string
+	// Leeway：校验 exp / nbf 时容忍的时钟偏差。
+	Leeway time.Duration
+}
+
+// ParseRoleMapping 解析形如 "sso-admins=admin,sso-editors=editor" 的角色映射配置。
+func ParseRoleMapping(spec string) (map[string]Role, error) {
+	mapping := map[string]Role{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		value, roleName, ok := strings.Cut(pair, "=")
+		role, valid := ParseRole(strings.TrimSpace(roleName))
+		if !ok || !valid || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("invalid role mapping %q", pair)
+		}
+		mapping[strings.TrimSpace(value)] = role
+	}
+	return mapping, nil
+}
+
+// LooksLikeJWT 判断凭证是否是 JWT 紧凑格式（三段 base64url，以 "." 分隔）。
+func LooksLikeJWT(credential string) bool {
+	return strings.Count(credential, ".") == 2
+}
+
+// jwtHeader 是 JOSE header 中用到的成员。
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// Verify 校验 token 的签名与标准 claims，返回对应的调用方。
+// 任何校验失败都返回包装了 ErrInvalidCredentials 的错误；具体原因只应写日志，不返回给客户端。
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: invalid header: %v", ErrInvalidCredentials, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature encoding", ErrInvalidCredentials)
+	}
+
+	key, err := v.Keys.lookup(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: invalid claims: %v", ErrInvalidCredentials, err)
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	principal := &Principal{
+		Subject: "jwt:" + subject,
+		Role:    v.role(claims),
+	}
+	if v.TenantClaim != "" {
+		principal.Tenant, _ = claims[v.TenantClaim].(string)
+	}
+	if v.PlatformClaim != "" && principal.Tenant == "" && principal.Role == Admin {
+		principal.Platform, _ = claims[v.PlatformClaim].(bool)
+	}
+	// 缺少租户 claim 的 token 不能退化为“不绑定租户”，否则调用方可以通过请求头选择任意租户。
+	if v.TenantClaim != "" && principal.Tenant == "" && !principal.Platform {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidCredentials, v.TenantClaim)
+	}
+	return principal, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// verifySignature 按 header 中的 alg 校验签名；alg 必须与密钥类型（以及 JWK 声明的 alg）一致，
+// 防止用公钥当作 HMAC 密钥之类的算法混淆攻击。
+func verifySignature(alg string, key *verificationKey, signingInput, signature []byte) error {
+	if key.alg != "" && key.alg != alg {
+		return fmt.Errorf("%w: algorithm %s does not match key %q", ErrInvalidCredentials, alg, key.id)
+	}
+	digest := sha256.Sum256(signingInput)
+
+	switch alg {
+	case "RS256":
+		pub, ok := key.key.(*rsa.PublicKey)
+		if !ok {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredentials)
+		}
+		return nil
+	case "ES256":
+		pub, ok := key.key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() {
+			break
+		}
+		// JWS 中的 ECDSA 签名是定长的 r || s，而不是 ASN.1 DER。
+		if len(signature) != 64 {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredentials)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredentials)
+		}
+		return nil
+	case "HS256":
+		secret, ok := key.key.([]byte)
+		if !ok {
+			break
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidCredentials)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidCredentials, alg)
+	}
+	return fmt.Errorf("%w: algorithm %s does not match key %q", ErrInvalidCredentials, alg, key.id)
+}
+
+// checkClaims 校验 exp（必需）、nbf、iss、aud。
+func (v *JWTVerifier) checkClaims(claims map[string]any, now time.Time) error {
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("%w: missing exp", ErrInvalidCredentials)
+	}
+	if now.After(exp.Add(v.Leeway)) {
+		return fmt.Errorf("%w: token expired", ErrInvalidCredentials)
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.Leeway).Before(nbf) {
+		return fmt.Errorf("%w: token not yet valid", ErrInvalidCredentials)
+	}
+
+	if v.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.Issuer {
+			return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidCredentials, iss)
+		}
+	}
+	if v.Audience != "" && !containsString(claims["aud"], v.Audience) {
+		return fmt.Errorf("%w: token not issued for audience %q", ErrInvalidCredentials, v.Audience)
+	}
+	return nil
+}
+
+// numericDate 解析 JWT 的 NumericDate（自 epoch 起的秒数，可以带小数）。
+func numericDate(v any) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), true
+}
+
+// containsString 判断字符串或字符串数组形式的 claim 是否包含 want。
+func containsString(claim any, want string) bool {
+	for _, s := range claimStrings(claim, false) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+// claimStrings 把字符串或字符串数组形式的 claim 展开；splitSpaces 为 true 时按空格拆分字符串（例如 scope）。
+func claimStrings(claim any, splitSpaces bool) []string {
+	switch value := claim.(type) {
+	case string:
+		if splitSpaces {
+			return strings.Fields(value)
+		}
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// role 从 RoleClaim 中映射出权限最高的角色；没有可识别的角色时返回空角色（任何操作都会被拒绝）。
+func (v *JWTVerifier) role(claims map[string]any) Role {
+	claimName := v.RoleClaim
+	if claimName == "" {
+		claimName = "roles"
+	}
+
+	var best Role
+	for _, value := range claimStrings(claims[claimName], true) {
+		var role Role
+		var ok bool
+		if v.RoleMapping != nil {
+			role, ok = v.RoleMapping[value]
+		} else {
+			role, ok = ParseRole(value)
+		}
+		if ok && !best.Allows(role) {
+			best = role
+		}
+	}
+	return best
+}
@@ -0,0 +1,317 @@
+// Package backup 生成与恢复 SQLite 数据库的在线备份。
+// 快照用 VACUUM INTO 生成（备份期间服务可以照常读写），可以 gzip 压缩，并在旁边写一个 sha256sum 格式的校验文件；
+// 恢复前会校验校验和、数据库完整性与 schema 版本。
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang-starter/utils"
+)
+
+// ChecksumSuffix 是校验文件的后缀：backup.db.gz 的校验文件为 backup.db.gz.sha256。
+const ChecksumSuffix = ".sha256"
+
+// ErrChecksumMismatch 表示备份文件与校验文件中记录的 SHA-256 不一致（文件损坏或被修改）。
+var ErrChecksumMismatch = errors.New("backup: checksum mismatch")
+
+// ErrChecksumMissing 表示要求校验时备份旁边没有校验文件，无法确认备份未被修改。
+var ErrChecksumMissing = errors.New("backup: checksum file not found")
+
+// Info 描述一个备份文件。
+type Info struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	SHA256        string    `json:"sha256"`
+	Compressed    bool      `json:"compressed"`
+	SchemaVersion int       `json:"schema_version,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Verified：SHA256 是否与校验文件一致；不要求校验且没有校验文件时为 false。
+	Verified bool `json:"-"`
+}
+
+// Create 把 db 的一致快照写到 dest；dest 以 .gz 结尾时 gzip 压缩。
+// 快照先写到 dest 所在目录的临时文件，完成后才出现在 dest，因此不会留下写了一半的备份；dest 已存在时返回错误，不会覆盖。
+func Create(ctx context.Context, db *sql.DB, dest string) (*Info, error) {
+	if _, err := os.Stat(dest); err == nil {
+		return nil, fmt.Errorf("backup: %s already exists", dest)
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dest), ".backup-*")
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshot := filepath.Join(tmpDir, "snapshot.db")
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, snapshot); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	info := &Info{Name: filepath.Base(dest), Compressed: isCompressed(dest), CreatedAt: time.Now().UTC()}
+	if info.SchemaVersion, err = schemaVersion(snapshot); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+
+	out := snapshot
+	if info.Compressed {
+		out = snapshot + ".gz"
+		if err := compress(snapshot, out); err != nil {
+			return nil, fmt.Errorf("backup: %w", err)
+		}
+	}
+	if info.SHA256, info.Size, err = checksum(out); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	if err := os.WriteFile(dest+ChecksumSuffix, []byte(info.SHA256+"  "+info.Name+"\n"), 0o644); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	// 用 Link 而不是 Rename：dest 在此期间被创建时返回错误，而不是覆盖它。
+	if err := os.Link(out, dest); err != nil {
+		os.Remove(dest + ChecksumSuffix)
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	return info, nil
+}
+
+// Verify 检查备份文件 src：核对校验文件中的 SHA-256，然后确认它是完好的、本项目的 SQLite 数据库，
+// 且 schema 版本不高于当前程序支持的版本（utils.SchemaVersion）。
+// 没有校验文件时，requireChecksum 为 true 返回 ErrChecksumMissing，否则跳过校验和检查；有校验文件时总是核对。
+func Verify(src string, requireChecksum bool) (*Info, error) {
+	tmpDir, err := os.MkdirTemp("", "backup-verify-*")
+	if err != nil {
+		return nil, fmt.Errorf("verify: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	info, _, err := prepare(src, tmpDir, requireChecksum)
+	return info, err
+}
+
+// Restore 用备份文件 src 替换数据库文件 dest，调用前必须停止使用 dest 的服务。
+// src 先通过 Verify 的所有检查（requireChecksum 的含义相同；压缩的备份会先解压到 dest 旁的临时目录），才会原子替换 dest；
+// dest 残留的 -wal / -shm 文件会被删除，避免旧日志被重放到新数据库上。
+func Restore(src, dest string, requireChecksum bool) (*Info, error) {
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dest), ".restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	info, db, err := prepare(src, tmpDir, requireChecksum)
+	if err != nil {
+		return nil, err
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dest + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("restore: %w", err)
+		}
+	}
+	if err := os.Rename(db, dest); err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+	return info, nil
+}
+
+// prepare 校验 src 并把它（解压后）复制到 dir 下，返回备份信息与复制出的数据库文件路径。
+// 检查的是复制出的文件而不是 src，校验通过后 src 被修改也不会影响恢复结果。
+func prepare(src, dir string, requireChecksum bool) (*Info, string, error) {
+	stat, err := os.Stat(src)
+	if err != nil {
+		return nil, "", fmt.Errorf("verify: %w", err)
+	}
+	info := &Info{Name: filepath.Base(src), Compressed: isCompressed(src), CreatedAt: stat.ModTime().UTC()}
+
+	db := filepath.Join(dir, "restore.db")
+	if err := copyFile(src, db, info); err != nil {
+		return nil, "", err
+	}
+	expected, err := readChecksum(src + ChecksumSuffix)
+	if err != nil {
+		return nil, "", fmt.Errorf("verify: %w", err)
+	}
+	if expected == "" && requireChecksum {
+		return nil, "", fmt.Errorf("%w: %s", ErrChecksumMissing, src+ChecksumSuffix)
+	}
+	if expected != "" {
+		if !strings.EqualFold(expected, info.SHA256) {
+			return nil, "", fmt.Errorf("%w: %s (expected %s, got %s)", ErrChecksumMismatch, src, expected, info.SHA256)
+		}
+		info.Verified = true
+	}
+
+	if err := checkDatabase(db); err != nil {
+		return nil, "", fmt.Errorf("verify: %s: %w", src, err)
+	}
+	if info.SchemaVersion, err = schemaVersion(db); err != nil {
+		return nil, "", fmt.Errorf("verify: %w", err)
+	}
+	if info.SchemaVersion > utils.SchemaVersion {
+		return nil, "", fmt.Errorf("verify: %s has schema version %d, newer than the supported version %d", src, info.SchemaVersion, utils.SchemaVersion)
+	}
+	return info, db, nil
+}
+
+// copyFile 把 src 复制到 dest（压缩的备份同时解压），并记录 src 的大小与 SHA-256。
+func copyFile(src, dest string, info *Info) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	defer in.Close()
+
+	h := sha256.New()
+	counter := &countingReader{r: io.TeeReader(in, h)}
+	var r io.Reader = counter
+	if info.Compressed {
+		zr, err := gzip.NewReader(counter)
+		if err != nil {
+			return fmt.Errorf("verify: %s is not a gzip file: %w", src, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	_, err = io.Copy(out, r)
+	if err == nil {
+		// gzip.Reader 读到结尾就停止：读完剩余的字节，校验和才覆盖整个文件。
+		_, err = io.Copy(io.Discard, counter)
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	info.Size = counter.n
+	info.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// checkDatabase 确认 path 是完好的、本项目的 SQLite 数据库。
+func checkDatabase(path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("not a valid SQLite database: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	var name string
+	if err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'products'`).Scan(&name); err != nil {
+		return errors.New("no products table")
+	}
+	return nil
+}
+
+func schemaVersion(path string) (int, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var version int
+	err = db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	return version, err
+}
+
+func compress(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// readChecksum 读取 sha256sum 格式的校验文件（"<hex>  <文件名>"），文件不存在时返回空字符串。
+func readChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("malformed checksum file %s", path)
+	}
+	return fields[0], nil
+}
+
+func isCompressed(path string) bool {
+	return strings.HasSuffix(path, ".gz")
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 备份目录中的文件名：backup-<UTC 时间>.db 或 backup-<UTC 时间>.db.gz，按名字排序即按时间排序。
+const (
+	filePrefix = "backup-"
+	timeLayout = "20060102T150405.000Z"
+)
+
+// Scheduler 把备份写到 Dir：Snapshot 立即备份一次，Run 按 Interval 定时备份；每次备份后只保留最新的 Keep 个。
+type Scheduler struct {
+	Dir string
+	// Interval：定时备份的间隔，<= 0 时 Run 不做任何事（仍然可以手动 Snapshot）。
+	Interval time.Duration
+	// Keep：保留的备份个数，<= 0 表示全部保留。
+	Keep int
+	// Compress：是否 gzip 压缩。
+	Compress bool
+
+	mu sync.Mutex
+}
+
+// Snapshot 立即备份 db，并按 Keep 删除旧的备份。同一时间只会有一个备份在进行。
+func (s *Scheduler) Snapshot(ctx context.Context, db *sql.DB) (*Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	name := filePrefix + time.Now().UTC().Format(timeLayout) + ".db"
+	if s.Compress {
+		name += ".gz"
+	}
+	info, err := Create(ctx, db, filepath.Join(s.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.prune(); err != nil {
+		log.Printf("backup: failed to remove old backups: %v", err)
+	}
+	return info, nil
+}
+
+// List 返回 Dir 中的备份，最新的在前；SHA256 取自校验文件，不会重新计算。
+func (s *Scheduler) List() ([]Info, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+
+	backups := []Info{}
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(name, filePrefix)
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		compressed := strings.HasSuffix(stamp, ".db.gz")
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ".db")
+		createdAt, err := time.Parse(timeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		sum, _ := readChecksum(filepath.Join(s.Dir, name+ChecksumSuffix))
+		backups = append(backups, Info{Name: name, Size: stat.Size(), SHA256: sum, Compressed: compressed, CreatedAt: createdAt})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// Run 每隔 Interval 备份一次，直到 ctx 被取消；失败只记录日志，下一次照常执行。
+func (s *Scheduler) Run(ctx context.Context, db *sql.DB) {
+	if s.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := s.Snapshot(ctx, db)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Scheduled backup failed: %v", err)
+				}
+				continue
+			}
+			log.Printf("Scheduled backup written: %s (%d bytes)", info.Name, info.Size)
+		}
+	}
+}
+
+// prune 删除超出 Keep 的旧备份及其校验文件。
+func (s *Scheduler) prune() error {
+	if s.Keep <= 0 {
+		return nil
+	}
+	backups, err := s.List()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, old := range backups[min(s.Keep, len(backups)):] {
+		path := filepath.Join(s.Dir, old.Name)
+		if err := os.Remove(path); err != nil {
+			errs = append(errs, err)
+		}
+		if err := os.Remove(path + ChecksumSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,154 @@
+// Package cache 实现带过期时间（TTL）的 LRU 缓存。
+//
+// 条目数达到容量后淘汰最久没有被访问的条目；条目写入后超过 TTL 即视为不存在，在下一次读取时删除。
+// 缓存只保存在内存中，进程重启后清空。
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU 是容量固定的 LRU 缓存，可以并发使用。
+type LRU[K comparable, V any] struct {
+	// Capacity：最多保存的条目数。
+	Capacity int
+	// TTL：条目的有效期；<= 0 表示不过期，只按容量淘汰。
+	TTL time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[K]*list.Element
+	stats Stats
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// Stats 是缓存的命中统计，用于观察缓存是否有效。
+type Stats struct {
+	Entries   int    `json:"entries"`
+	Capacity  int    `json:"capacity"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	// Expirations：读取时发现已过期而删除的条目数（同时计入 Misses）。
+	Expirations uint64 `json:"expirations"`
+	// HitRatio：Hits / (Hits + Misses)，还没有读取时为 0。
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// New 创建缓存；capacity 至少为 1。
+func New[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		Capacity: max(capacity, 1),
+		TTL:      ttl,
+		ll:       list.New(),
+		items:    map[K]*list.Element{},
+	}
+}
+
+// Get 返回 key 对应的值，并把条目标记为最近使用；不存在或已过期时返回 false。
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.remove(el)
+		c.stats.Misses++
+		c.stats.Expirations++
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	c.stats.Hits++
+	return e.value, true
+}
+
+// Set 写入或覆盖 key；超出容量时淘汰最久没有被访问的条目。
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.TTL > 0 {
+		expires = time.Now().Add(c.TTL)
+	}
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	for c.ll.Len() > c.Capacity {
+		c.remove(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Delete 删除 key（不存在时什么都不做）。
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// DeleteFunc 删除所有满足 fn 的条目，返回删除的条目数；fn 在持有锁时调用，不能再访问缓存。
+func (c *LRU[K, V]) DeleteFunc(fn func(key K, value V) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*entry[K, V]); fn(e.key, e.value) {
+			c.remove(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
+
+// Purge 删除所有条目；命中统计保留。
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = map[K]*list.Element{}
+}
+
+// Stats 返回当前的条目数与命中统计。
+func (c *LRU[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Entries = c.ll.Len()
+	s.Capacity = c.Capacity
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRatio = float64(s.Hits) / float64(total)
+	}
+	return s
+}
+
+func (c *LRU[K, V]) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,369 @@
+// Package client 是产品 API 的 Go 客户端：封装认证、租户、统一响应结构的解包与错误码，
+// 并对幂等请求在网络错误、429 与 5xx 时自动退避重试。
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/models"
+	"golang-starter/validation"
+)
+
+// maxBackoff 是两次重试之间等待时间的上限（服务端给出更长的 Retry-After 时以服务端为准）。
+const maxBackoff = 5 * time.Second
+
+// Client 是产品 API 的客户端，字段在首次发起请求前设置，之后可以被多个 goroutine 并发使用。
+type Client struct {
+	// BaseURL：服务地址，例如 "http://localhost:8080"。
+	BaseURL string
+	// APIKey：通过 X-API-Key 发送；为空时不携带凭证。
+	APIKey string
+	// Tenant：通过 X-Tenant-ID 指定租户；为空时使用密钥绑定的租户或默认租户。
+	Tenant string
+	// HTTPClient：发送请求使用的 http.Client。
+	HTTPClient *http.Client
+	// MaxRetries：幂等请求失败后的最多重试次数，0 表示不重试。
+	MaxRetries int
+	// Backoff：第一次重试前的等待时间，之后每次翻倍（带随机抖动）。
+	Backoff time.Duration
+}
+
+// New 创建客户端：默认超时 30 秒，幂等请求最多重试 3 次，首次退避 200ms。
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 3,
+		Backoff:    200 * time.Millisecond,
+	}
+}
+
+// ListParams 是 ListProducts 的分页与排序参数；零值字段不发送，由服务端取默认值（limit 20、offset 0、id_asc）。
+type ListParams struct {
+	Limit  int
+	Offset int
+	// Order："id_asc" 或 "id_desc"。
+	Order string
+}
+
+// ProductPatch 是 PatchProduct 的 merge-patch 请求体：只发送非 nil 的字段。
+type ProductPatch struct {
+	Name  *string  `json:"name,omitempty"`
+	Price *float64 `json:"price,omitempty"`
+	Stock *int     `json:"stock,omitempty"`
+}
+
+// GetProduct 获取产品：GET /api/products/{id}；不存在时返回的错误满足 errors.Is(err, ErrNotFound)。
+func (c *Client) GetProduct(ctx context.Context, id int) (*models.Product, error) {
+	var product models.Product
+	if err := c.do(ctx, request{method: "GET", path: productPath(id)}, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// ListProducts 分页获取产品列表：GET /api/products
+func (c *Client) ListProducts(ctx context.Context, params ListParams) ([]models.Product, error) {
+	query := url.Values{}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Offset > 0 {
+		query.Set("offset", strconv.Itoa(params.Offset))
+	}
+	if params.Order != "" {
+		query.Set("order", params.Order)
+	}
+
+	products := []models.Product{}
+	if err := c.do(ctx, request{method: "GET", path: "/api/products", query: query}, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// SearchProducts 按名称模糊搜索产品：GET /api/products/search?name=
+func (c *Client) SearchProducts(ctx context.Context, name string) ([]models.Product, error) {
+	products := []models.Product{}
+	if err := c.do(ctx, request{method: "GET", path: "/api/products/search", query: url.Values{"name": {name}}}, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// CreateProduct 创建产品：POST /api/products
+// 每次调用生成一个 Idempotency-Key，重试时复用，服务端保证同一产品只会被创建一次。
+func (c *Client) CreateProduct(ctx context.Context, product models.Product) (*models.Product, error) {
+	var created models.Product
+	if err := c.do(ctx, request{method: "POST", path: "/api/products", body: product, idempotencyKey: newIdempotencyKey()}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// BulkCreate 批量创建产品：POST /api/products/bulk；任何一条不合法时都不会写入。
+// 与 CreateProduct 一样使用 Idempotency-Key，可以安全重试。
+func (c *Client) BulkCreate(ctx context.Context, products []models.Product) ([]models.Product, error) {
+	created := []models.Product{}
+	if err := c.do(ctx, request{method: "POST", path: "/api/products/bulk", body: products, idempotencyKey: newIdempotencyKey()}, &created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateProduct 整体更新产品：PUT /api/products/{id}
+func (c *Client) UpdateProduct(ctx context.Context, id int, product models.Product) (*models.Product, error) {
+	var updated models.Product
+	if err := c.do(ctx, request{method: "PUT", path: productPath(id), body: product}, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// PatchProduct 局部更新产品：PATCH /api/products/{id}，Content-Type 为 application/merge-patch+json。
+func (c *Client) PatchProduct(ctx context.Context, id int, patch ProductPatch) (*models.Product, error) {
+	var updated models.Product
+	if err := c.do(ctx, request{method: "PATCH", path: productPath(id), body: patch, contentType: "application/merge-patch+json"}, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteProduct 删除产品：DELETE /api/products/{id}
+// 注意：如果第一次请求已经删除成功但响应丢失，重试会得到 ErrNotFound。
+func (c *Client) DeleteProduct(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: "DELETE", path: productPath(id)}, nil)
+}
+
+func productPath(id int) string {
+	return "/api/products/" + strconv.Itoa(id)
+}
+
+// request 描述一次 API 调用。
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        any
+	contentType string
+	// idempotencyKey：非空时作为 Idempotency-Key 发送，POST 请求也可以重试。
+	idempotencyKey string
+}
+
+// retryable 判断请求重试是否安全：GET / PUT / DELETE / PATCH（merge-patch 写入的是绝对值）天然幂等，
+// POST 只有携带 Idempotency-Key 时才重试。
+func (req request) retryable() bool {
+	return req.method != "POST" || req.idempotencyKey != ""
+}
+
+// envelope 是服务端统一的成功响应结构。
+type envelope struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// do 发送请求并把响应的 data 解码到 out（out 为 nil 时忽略 data）；失败时返回 *Error 或网络错误。
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return fmt.Errorf("client: encode request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req, body)
+		canRetry := req.retryable() && attempt < c.MaxRetries
+		if err != nil {
+			if ctx.Err() != nil || !canRetry {
+				return err
+			}
+			if err := c.wait(ctx, attempt, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if canRetry && retryableStatus(resp.StatusCode) {
+			retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if err := c.wait(ctx, attempt, retryAfter); err != nil {
+				return err
+			}
+			continue
+		}
+		return decodeResponse(resp, out)
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	target := c.BaseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		contentType := req.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if c.APIKey != "" {
+		httpReq.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.Tenant != "" {
+		httpReq.Header.Set("X-Tenant-ID", c.Tenant)
+	}
+	if req.idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.idempotencyKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(httpReq)
+}
+
+// wait 在第 attempt 次重试前等待：Backoff * 2^attempt 加上最多一半的随机抖动，不超过 maxBackoff；
+// 服务端给出的 Retry-After 更长时按 Retry-After 等待。ctx 结束时立即返回。
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	delay := c.Backoff << attempt
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	if c.Backoff <= 0 {
+		delay = 0
+	}
+	if delay > 0 {
+		delay += time.Duration(mathrand.Int64N(int64(delay)/2 + 1))
+	}
+	delay = max(delay, retryAfter)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryableStatus：429 与 5xx（501 Not Implemented 除外）可以重试。
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || (status >= 500 && status != http.StatusNotImplemented)
+}
+
+// parseRetryAfter 解析以秒为单位的 Retry-After；无法解析时返回 0。
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("client: read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		apiErr.StatusCode = resp.StatusCode
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return fmt.Errorf("client: decode response: %w", err)
+	}
+	if len(env.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("client: decode response: %w", err)
+	}
+	return nil
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 可以用 errors.Is 判断的错误类别，对应 *Error 的 HTTP 状态码。
+var (
+	// ErrBadRequest：400，例如参数不合法、请求体不是合法 JSON。
+	ErrBadRequest = errors.New("client: bad request")
+	// ErrNotFound：404，例如产品不存在。
+	ErrNotFound = errors.New("client: not found")
+	// ErrValidation：422，字段校验失败，具体字段见 Error.Fields。
+	ErrValidation = errors.New("client: validation failed")
+)
+
+// Error 是服务端返回的错误响应。应按 Code（稳定的错误码）而不是 Message 判断错误类型。
+type Error struct {
+	StatusCode int                     `json:"code"`
+	Code       apierror.Code           `json:"error_code"`
+	Message    string                  `json:"message"`
+	Fields     []validation.FieldError `json:"errors,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("client: %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("client: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is 让 errors.Is(err, ErrNotFound) 等按状态码匹配。
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrValidation:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
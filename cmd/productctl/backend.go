@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"golang-starter/client"
+	"golang-starter/models"
+	"golang-starter/utils"
+	"golang-starter/validation"
+)
+
+// backend 是子命令访问产品数据的方式：远程模式直接使用 *client.Client，本地模式由 localBackend 通过 models 读写数据库。
+type backend interface {
+	ListProducts(ctx context.Context, params client.ListParams) ([]models.Product, error)
+	GetProduct(ctx context.Context, id int) (*models.Product, error)
+	SearchProducts(ctx context.Context, name string) ([]models.Product, error)
+	CreateProduct(ctx context.Context, product models.Product) (*models.Product, error)
+	BulkCreate(ctx context.Context, products []models.Product) ([]models.Product, error)
+	PatchProduct(ctx context.Context, id int, patch client.ProductPatch) (*models.Product, error)
+	DeleteProduct(ctx context.Context, id int) error
+}
+
+var _ backend = (*client.Client)(nil)
+
+// localBackend 直接读写数据库，校验规则、租户隔离与配额与 HTTP 接口一致。
+type localBackend struct {
+	db     *sql.DB
+	tenant string
+}
+
+func (b *localBackend) ListProducts(ctx context.Context, params client.ListParams) ([]models.Product, error) {
+	if params.Limit <= 0 {
+		params.Limit = 20
+	}
+	if params.Order == "" {
+		params.Order = "id_asc"
+	}
+	products, err := models.GetAllProducts(ctx, b.db, b.tenant, models.GetAllProductsParams{Limit: params.Limit, Offset: params.Offset, Order: params.Order})
+	return derefProducts(products), err
+}
+
+func (b *localBackend) GetProduct(ctx context.Context, id int) (*models.Product, error) {
+	return models.GetProductByID(ctx, b.db, b.tenant, id)
+}
+
+func (b *localBackend) SearchProducts(ctx context.Context, name string) ([]models.Product, error) {
+	products, err := models.SearchProduct(ctx, b.db, b.tenant, name)
+	return derefProducts(products), err
+}
+
+func (b *localBackend) CreateProduct(ctx context.Context, product models.Product) (*models.Product, error) {
+	if errs := validation.Struct(&product); len(errs) > 0 {
+		return nil, errs
+	}
+	return models.CreateProduct(ctx, b.db, b.tenant, &product)
+}
+
+func (b *localBackend) BulkCreate(ctx context.Context, products []models.Product) ([]models.Product, error) {
+	if errs := validation.Slice("products", products); len(errs) > 0 {
+		return nil, errs
+	}
+	ptrs := make([]*models.Product, len(products))
+	for i := range products {
+		ptrs[i] = &products[i]
+	}
+	created, err := models.ProductsBulk(ctx, b.db, b.tenant, ptrs)
+	return derefProducts(created), err
+}
+
+// PatchProduct 与 PATCH 接口一样：先把补丁作用在当前产品上并校验结果，再只更新传入的字段；读取与更新在同一个事务中。
+func (b *localBackend) PatchProduct(ctx context.Context, id int, patch client.ProductPatch) (*models.Product, error) {
+	var updated *models.Product
+	err := utils.RunTx(ctx, b.db, func(tx *sql.Tx) error {
+		current, err := models.GetProductByID(ctx, tx, b.tenant, id)
+		if err != nil {
+			return err
+		}
+		var fields []string
+		if patch.Name != nil {
+			current.Name = *patch.Name
+			fields = append(fields, "name")
+		}
+		if patch.Price != nil {
+			current.Price = *patch.Price
+			fields = append(fields, "price")
+		}
+		if patch.Stock != nil {
+			current.Stock = *patch.Stock
+			fields = append(fields, "stock")
+		}
+		if errs := validation.Struct(current); len(errs) > 0 {
+			return errs
+		}
+		updated, err = models.UpdateLocalProduct(ctx, tx, b.tenant, id, fields, *current)
+		return err
+	})
+	return updated, err
+}
+
+func (b *localBackend) DeleteProduct(ctx context.Context, id int) error {
+	return models.DeleteProduct(ctx, b.db, b.tenant, id)
+}
+
+func derefProducts(products []*models.Product) []models.Product {
+	result := make([]models.Product, 0, len(products))
+	for _, p := range products {
+		result = append(result, *p)
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-starter/client"
+	"golang-starter/models"
+	"golang-starter/validation"
+)
+
+// csvColumns 与 GET /api/products/export?format=csv 的表头一致，导出的文件可以直接再导入。
+var csvColumns = []string{"id", "name", "price", "stock", "created_at", "updated_at"}
+
+// csvPageSize：导出时每页读取的产品数（与列表接口的 limit 上限一致）；importBatchSize：导入时每批写入的产品数。
+const (
+	csvPageSize     = 100
+	importBatchSize = 500
+)
+
+// exportCSV 按 id 升序分页读取全部产品并写出 CSV，返回导出的行数。
+func exportCSV(ctx context.Context, b backend, w io.Writer) (int, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for offset := 0; ; offset += csvPageSize {
+		page, err := b.ListProducts(ctx, client.ListParams{Limit: csvPageSize, Offset: offset, Order: "id_asc"})
+		if err != nil {
+			return count, err
+		}
+		for _, p := range page {
+			record := []string{
+				strconv.Itoa(p.ID),
+				p.Name,
+				strconv.FormatFloat(p.Price, 'f', -1, 64),
+				strconv.Itoa(p.Stock),
+				p.CreatedAt.UTC().Format(time.RFC3339),
+				p.UpdatedAt.UTC().Format(time.RFC3339),
+			}
+			if err := cw.Write(record); err != nil {
+				return count, err
+			}
+		}
+		count += len(page)
+		if len(page) < csvPageSize {
+			break
+		}
+	}
+	cw.Flush()
+	return count, cw.Error()
+}
+
+// readCSV 读取待导入的产品：表头必须包含 name 与 price，stock 可选，其他列（id、时间戳等）忽略。
+// 所有行先按产品的校验规则检查，任何一行有误都返回带行号的错误，不会写入任何数据。
+func readCSV(r io.Reader) ([]models.Product, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv: missing header")
+	} else if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"name", "price"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("csv: missing %q column", required)
+		}
+	}
+
+	var products []models.Product
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		var p models.Product
+		p.Name = record[index["name"]]
+		if p.Price, err = strconv.ParseFloat(strings.TrimSpace(record[index["price"]]), 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid price %q", line, record[index["price"]])
+		}
+		if i, ok := index["stock"]; ok && strings.TrimSpace(record[i]) != "" {
+			if p.Stock, err = strconv.Atoi(strings.TrimSpace(record[i])); err != nil {
+				return nil, fmt.Errorf("line %d: invalid stock %q", line, record[i])
+			}
+		}
+		if errs := validation.Struct(&p); len(errs) > 0 {
+			return nil, fmt.Errorf("line %d: %w", line, errs)
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+// importProducts 按批写入产品，返回成功写入的数量；某一批失败时，之前的批次已经写入。
+func importProducts(ctx context.Context, b backend, products []models.Product) (int, error) {
+	imported := 0
+	for start := 0; start < len(products); start += importBatchSize {
+		batch := products[start:min(start+importBatchSize, len(products))]
+		created, err := b.BulkCreate(ctx, batch)
+		if err != nil {
+			return imported, err
+		}
+		imported += len(created)
+	}
+	return imported, nil
+}
@@ -0,0 +1,415 @@
+// productctl 是产品服务的命令行管理工具。
+// 默认通过 models 直接读写 SQLite 数据库（-db）；指定 -server 时改为通过 HTTP API 访问（使用 client 包，经过认证、限流与租户检查）。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang-starter/backup"
+	"golang-starter/client"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+const usage = `Usage: productctl [flags] <command> [arguments]
+
+Commands:
+  list [-limit N] [-offset N] [-order id_asc|id_desc]
+  get <id>
+  create -name NAME -price PRICE [-stock N]
+  update <id> [-name NAME] [-price PRICE] [-stock N]
+  delete <id>
+  search <name>
+  export [-file products.csv]        write all products as CSV (stdout by default)
+  import <file.csv|->                create products from CSV (columns: name, price[, stock])
+  migrate                            create or upgrade the database schema (local only)
+  backup <file>                      write a consistent copy of the database, gzip if <file> ends in .gz (local only)
+
+Flags:
+`
+
+// errUsage 表示命令行参数有误：打印用法并以状态码 2 退出。
+var errUsage = errors.New("usage")
+
+// app 是子命令运行时的上下文。
+type app struct {
+	backend backend
+	// local：是否直接访问数据库（migrate、backup 只能在本地模式下使用）。
+	local bool
+	// output：输出格式，全局 -o 与子命令的 -o 都写入这里。
+	output string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command func(ctx context.Context, a *app, args []string) error
+
+var commands = map[string]command{
+	"list":    listCommand,
+	"get":     getCommand,
+	"create":  createCommand,
+	"update":  updateCommand,
+	"delete":  deleteCommand,
+	"search":  searchCommand,
+	"export":  exportCommand,
+	"import":  importCommand,
+	"migrate": migrateCommand,
+	"backup":  backupCommand,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run 解析参数并执行子命令，返回进程退出码：0 成功，1 执行失败，2 参数有误。
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("productctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	dbPath := fs.String("db", envOr("PRODUCTCTL_DB", "./database.db"), "SQLite database `path` (env PRODUCTCTL_DB)")
+	server := fs.String("server", os.Getenv("PRODUCTCTL_SERVER"), "use the HTTP API at `url` instead of the database (env PRODUCTCTL_SERVER)")
+	apiKey := fs.String("api-key", os.Getenv("PRODUCTCTL_API_KEY"), "API `key` for -server (env PRODUCTCTL_API_KEY)")
+	tenant := fs.String("tenant", "", "tenant `id` (default: the key's tenant, or \""+models.DefaultTenant+"\")")
+	output := fs.String("o", "table", "output `format`: table, json or yaml")
+	verbose := fs.Bool("v", false, "log database and connection details to stderr")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		if fs.NArg() > 0 {
+			fmt.Fprintf(stderr, "productctl: unknown command %q\n", fs.Arg(0))
+		}
+		fs.Usage()
+		return 2
+	}
+	if *tenant != "" && !models.ValidTenantID(*tenant) {
+		fmt.Fprintf(stderr, "productctl: invalid tenant %q\n", *tenant)
+		return 2
+	}
+
+	if _, ok := outputFormats[*output]; !ok {
+		fmt.Fprintf(stderr, "productctl: unknown output format %q\n", *output)
+		return 2
+	}
+
+	a := &app{output: *output, stdin: stdin, stdout: stdout, stderr: stderr}
+	if *server != "" {
+		c := client.New(*server, *apiKey)
+		c.Tenant = *tenant
+		a.backend = c
+	} else {
+		// utils.InitDB 会打印连接与建表日志，默认只保留错误（log.Fatal 仍然会输出）。
+		if !*verbose {
+			log.SetOutput(quietLog{stderr})
+		}
+		if _, err := os.Stat(filepath.Dir(*dbPath)); err != nil {
+			fmt.Fprintf(stderr, "productctl: %v\n", err)
+			return 1
+		}
+		utils.DBPath = *dbPath
+		utils.InitDB()
+		defer utils.CloseDB()
+
+		if *tenant == "" {
+			*tenant = models.DefaultTenant
+		}
+		a.backend = &localBackend{db: utils.DB, tenant: *tenant}
+		a.local = true
+	}
+
+	if err := cmd(ctx, a, fs.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			return 2
+		}
+		fmt.Fprintf(stderr, "productctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// flags 创建子命令的 FlagSet；每个子命令都接受 -o，因此 "list -o json" 与 "-o json list" 等价。
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&a.output, "o", a.output, "output `format`: table, json or yaml")
+	return fs
+}
+
+// print 按 -o 指定的格式输出结果。
+func (a *app) print(v any) error {
+	format, ok := outputFormats[a.output]
+	if !ok {
+		return fmt.Errorf("unknown output format %q", a.output)
+	}
+	return format(a.stdout, v)
+}
+
+// quietLog 丢弃 utils 的普通日志，只转发错误日志（"Failed to ..." / "Error ..."，包括 log.Fatal 退出前写出的原因）。
+type quietLog struct{ w io.Writer }
+
+func (q quietLog) Write(p []byte) (int, error) {
+	if msg := string(p); strings.Contains(msg, "Failed to") || strings.Contains(msg, "Error ") {
+		return q.w.Write(p)
+	}
+	return len(p), nil
+}
+
+func listCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("list")
+	var params client.ListParams
+	fs.IntVar(&params.Limit, "limit", 20, "maximum number of products (1-100)")
+	fs.IntVar(&params.Offset, "offset", 0, "number of products to skip")
+	fs.StringVar(&params.Order, "order", "id_asc", "id_asc or id_desc")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	products, err := a.backend.ListProducts(ctx, params)
+	if err != nil {
+		return err
+	}
+	return a.print(products)
+}
+
+func getCommand(ctx context.Context, a *app, args []string) error {
+	args, err := parseArgs(a.flags("get"), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args)
+	if err != nil {
+		return err
+	}
+	product, err := a.backend.GetProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.print(product)
+}
+
+func createCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("create")
+	var product models.Product
+	fs.StringVar(&product.Name, "name", "", "product name")
+	fs.Float64Var(&product.Price, "price", 0, "product price")
+	fs.IntVar(&product.Stock, "stock", 0, "stock quantity")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	created, err := a.backend.CreateProduct(ctx, product)
+	if err != nil {
+		return err
+	}
+	return a.print(created)
+}
+
+// updateCommand 只修改传入的字段（merge-patch 语义），例如 update 3 -stock 0。
+func updateCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("update")
+	name := fs.String("name", "", "new product name")
+	price := fs.Float64("price", 0, "new product price")
+	stock := fs.Int("stock", 0, "new stock quantity")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(positional)
+	if err != nil {
+		return err
+	}
+
+	var patch client.ProductPatch
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			patch.Name = name
+		case "price":
+			patch.Price = price
+		case "stock":
+			patch.Stock = stock
+		}
+	})
+	if patch == (client.ProductPatch{}) {
+		return fmt.Errorf("update: %w", models.ErrNoFields)
+	}
+
+	updated, err := a.backend.PatchProduct(ctx, id, patch)
+	if err != nil {
+		return err
+	}
+	return a.print(updated)
+}
+
+func deleteCommand(ctx context.Context, a *app, args []string) error {
+	args, err := parseArgs(a.flags("delete"), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args)
+	if err != nil {
+		return err
+	}
+	if err := a.backend.DeleteProduct(ctx, id); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "deleted product %d\n", id)
+	return nil
+}
+
+func searchCommand(ctx context.Context, a *app, args []string) error {
+	args, err := parseArgs(a.flags("search"), args, 1)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || args[0] == "" {
+		return errUsage
+	}
+	products, err := a.backend.SearchProducts(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return a.print(products)
+}
+
+func exportCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("export")
+	file := fs.String("file", "", "write to `path` instead of stdout")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	w := a.stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	count, err := exportCSV(ctx, a.backend, w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "exported %d products\n", count)
+	return nil
+}
+
+func importCommand(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	r := a.stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	products, err := readCSV(r)
+	if err != nil {
+		return err
+	}
+	count, err := importProducts(ctx, a.backend, products)
+	fmt.Fprintf(a.stderr, "imported %d products\n", count)
+	return err
+}
+
+// migrateCommand 建表并补齐旧版本缺少的列；utils.InitDB 在打开数据库时已经执行了这些幂等的迁移。
+func migrateCommand(ctx context.Context, a *app, args []string) error {
+	if !a.local {
+		return errors.New("migrate needs direct database access; remove -server")
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+	fmt.Fprintf(a.stderr, "database %s is up to date\n", utils.DBPath)
+	return nil
+}
+
+// backupCommand 把数据库写到新文件（见 backup.Create）：得到的是一致的快照，服务不需要停机；文件名以 .gz 结尾时压缩。
+func backupCommand(ctx context.Context, a *app, args []string) error {
+	if !a.local {
+		return errors.New("backup needs direct database access; remove -server")
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+	info, err := backup.Create(ctx, utils.BackupDB, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "backed up %s to %s (%d bytes, sha256 %s)\n", utils.DBPath, args[0], info.Size, info.SHA256)
+	return nil
+}
+
+// parseArgs 解析子命令参数，允许 flag 出现在位置参数之后（例如 update 3 -stock 0）；
+// 位置参数多于 maxPositional 时返回 errUsage；-o 指定了不支持的格式时在执行命令之前报错。
+func parseArgs(fs *flag.FlagSet, args []string, maxPositional int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, errUsage
+			}
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) > maxPositional {
+		return nil, errUsage
+	}
+	if o := fs.Lookup("o"); o != nil {
+		if _, ok := outputFormats[o.Value.String()]; !ok {
+			return nil, fmt.Errorf("unknown output format %q", o.Value.String())
+		}
+	}
+	return positional, nil
+}
+
+func parseID(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, errUsage
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid product id %q", args[0])
+	}
+	return id, nil
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"golang-starter/models"
+)
+
+// outputFormats 是 -o 支持的输出格式。
+var outputFormats = map[string]func(w io.Writer, v any) error{
+	"table": writeTable,
+	"json":  writeJSON,
+	"yaml":  writeYAML,
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeYAML 先编码为 JSON 再转换为 YAML，字段名与顺序和 API 的 JSON 保持一致。
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle 去掉 JSON 解析出的 flow 风格与引号，输出常见的块状 YAML（需要时编码器会自动加引号）。
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// writeTable 以对齐的表格输出产品或产品列表；其他值直接打印。
+func writeTable(w io.Writer, v any) error {
+	var products []models.Product
+	switch v := v.(type) {
+	case *models.Product:
+		products = []models.Product{*v}
+	case []models.Product:
+		products = v
+	default:
+		_, err := fmt.Fprintln(w, v)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPRICE\tSTOCK\tUPDATED")
+	for _, p := range products {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\n", p.ID, p.Name, strconv.FormatFloat(p.Price, 'f', -1, 64), p.Stock, p.UpdatedAt.UTC().Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-starter/auth"
+	"golang-starter/backup"
+	"golang-starter/handlers"
+	"golang-starter/jobs"
+	"golang-starter/models"
+	"golang-starter/ratelimit"
+	"golang-starter/utils"
+)
+
+// configure 读取环境变量并写入 handlers / models 的全局配置。
+// 不会在第一个错误处停下：所有无效的配置项都会合并在返回的错误中，方便 config-check 一次性列出。
+func configure(getenv func(string) string) error {
+	var errs []error
+	invalid := func(name, value string) {
+		errs = append(errs, fmt.Errorf("invalid %s: %q", name, value))
+	}
+
+	// IDEMPOTENCY_TTL：Idempotency-Key 的有效期（例如 "24h"、"30m"），不设置时使用默认值。
+	if ttl := getenv("IDEMPOTENCY_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err != nil || d <= 0 {
+			invalid("IDEMPOTENCY_TTL", ttl)
+		} else {
+			handlers.IdempotencyTTL = d
+		}
+	}
+
+	// TENANT_MAX_PRODUCTS：每个租户默认最多可以拥有的产品数（0 或不设置表示不限制），可按租户单独覆盖。
+	if limit := getenv("TENANT_MAX_PRODUCTS"); limit != "" {
+		if n, err := strconv.Atoi(limit); err != nil || n < 0 {
+			invalid("TENANT_MAX_PRODUCTS", limit)
+		} else {
+			models.DefaultMaxProducts = n
+		}
+	}
+	// TENANT_BASE_DOMAIN：按子域名识别租户，例如 "shop.example.com" 时 acme.shop.example.com 属于租户 acme。
+	handlers.TenantBaseDomain = getenv("TENANT_BASE_DOMAIN")
+
+	// RATE_LIMIT_RPS / RATE_LIMIT_BURST：每个客户端每秒补充的令牌数与桶容量（默认 10 / 20），RATE_LIMIT_RPS=0 关闭限流。
+	// RATE_LIMIT_COSTS：各类请求的开销，例如 "bulk=10,export=5"。
+	rate, burst := 10.0, 20.0
+	if v := getenv("RATE_LIMIT_RPS"); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err != nil || n < 0 {
+			invalid("RATE_LIMIT_RPS", v)
+		} else {
+			rate = n
+		}
+	}
+	if v := getenv("RATE_LIMIT_BURST"); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err != nil || n < 1 {
+			invalid("RATE_LIMIT_BURST", v)
+		} else {
+			burst = n
+		}
+	}
+	if spec := getenv("RATE_LIMIT_COSTS"); spec != "" {
+		if err := handlers.ParseRateLimitCosts(spec); err != nil {
+			errs = append(errs, fmt.Errorf("invalid RATE_LIMIT_COSTS: %w", err))
+		}
+	}
+	handlers.RateLimiter = nil
+	if rate > 0 {
+		handlers.RateLimiter = ratelimit.New(rate, burst)
+	}
+	// IP_RATE_LIMIT_RPS / IP_RATE_LIMIT_BURST：认证之前按客户端 IP 限流（默认 50 / 100），IP_RATE_LIMIT_RPS=0 关闭。
+	ipRate, ipBurst := 50.0, 100.0
+	if v := getenv("IP_RATE_LIMIT_RPS"); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err != nil || n < 0 {
+			invalid("IP_RATE_LIMIT_RPS", v)
+		} else {
+			ipRate = n
+		}
+	}
+	if v := getenv("IP_RATE_LIMIT_BURST"); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err != nil || n < 1 {
+			invalid("IP_RATE_LIMIT_BURST", v)
+		} else {
+			ipBurst = n
+		}
+	}
+	handlers.IPRateLimiter = nil
+	if ipRate > 0 {
+		handlers.IPRateLimiter = ratelimit.New(ipRate, ipBurst)
+	}
+	// TRUST_PROXY_HEADERS=true：服务部署在可信反向代理之后时，按 X-Forwarded-For 识别客户端 IP。
+	handlers.TrustProxyHeaders = getenv("TRUST_PROXY_HEADERS") == "true"
+
+	// CORS_ALLOWED_ORIGINS：允许跨域访问的来源，逗号分隔，例如 "https://admin.example.com"；"*" 表示任意来源。
+	handlers.CORSAllowedOrigins = nil
+	for _, origin := range strings.Split(getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			handlers.CORSAllowedOrigins = append(handlers.CORSAllowedOrigins, origin)
+		}
+	}
+	// CORS_ALLOW_CREDENTIALS=true：允许跨域请求携带 Cookie 等凭证。
+	handlers.CORSAllowCredentials = getenv("CORS_ALLOW_CREDENTIALS") == "true"
+	// MAX_BODY_BYTES / MAX_BULK_BODY_BYTES：请求体大小上限（默认 1 MiB / 10 MiB），超出返回 413。
+	for _, env := range []struct{ name, class string }{{"MAX_BODY_BYTES", "default"}, {"MAX_BULK_BODY_BYTES", "bulk"}} {
+		if v := getenv(env.name); v != "" {
+			if n, err := strconv.ParseInt(v, 10, 64); err != nil || n <= 0 {
+				invalid(env.name, v)
+			} else {
+				handlers.BodyLimits[env.class] = n
+			}
+		}
+	}
+	// REQUEST_TIMEOUT / BULK_REQUEST_TIMEOUT / EXPORT_REQUEST_TIMEOUT：请求的处理时限（默认 10s / 1m / 5m），超时返回 503；0 表示不限制。
+	for _, env := range []struct{ name, class string }{{"REQUEST_TIMEOUT", "default"}, {"BULK_REQUEST_TIMEOUT", "bulk"}, {"EXPORT_REQUEST_TIMEOUT", "export"}} {
+		if v := getenv(env.name); v != "" {
+			if d, err := time.ParseDuration(v); err != nil || d < 0 {
+				invalid(env.name, v)
+			} else {
+				handlers.RequestTimeouts[env.class] = d
+			}
+		}
+	}
+	// STRICT_JSON=true：请求体中出现未定义的字段时返回 400。
+	handlers.RejectUnknownFields = getenv("STRICT_JSON") == "true"
+	// COMPRESSION_ENCODINGS：支持的压缩算法，按偏好排序（默认 "zstd,br,gzip"）；设为 "none" 时不压缩。
+	if v := getenv("COMPRESSION_ENCODINGS"); v != "" {
+		handlers.CompressionEncodings = nil
+		for _, encoding := range strings.Split(v, ",") {
+			if encoding = strings.TrimSpace(encoding); encoding != "" && encoding != "none" {
+				handlers.CompressionEncodings = append(handlers.CompressionEncodings, encoding)
+			}
+		}
+		if err := handlers.ValidateCompressionEncodings(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid COMPRESSION_ENCODINGS: %w", err))
+		}
+	}
+	// COMPRESSION_MIN_SIZE：响应体达到该字节数才压缩（默认 1024）。
+	if v := getenv("COMPRESSION_MIN_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 {
+			invalid("COMPRESSION_MIN_SIZE", v)
+		} else {
+			handlers.CompressionMinSize = n
+		}
+	}
+
+	// PRODUCT_CACHE_SIZE / PRODUCT_CACHE_TTL：产品缓存每类条目（单个产品、列表页）的最大数量与有效期（默认 1000 / 30s），
+	// PRODUCT_CACHE_SIZE=0 关闭缓存。PRODUCT_CACHE_MAX_AGE：产品读取接口 Cache-Control 的 max-age（默认 0，即 no-cache）。
+	size, ttl := 1000, 30*time.Second
+	if v := getenv("PRODUCT_CACHE_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 {
+			invalid("PRODUCT_CACHE_SIZE", v)
+		} else {
+			size = n
+		}
+	}
+	if v := getenv("PRODUCT_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			invalid("PRODUCT_CACHE_TTL", v)
+		} else {
+			ttl = d
+		}
+	}
+	handlers.ProductCache = nil
+	if size > 0 {
+		handlers.ProductCache = handlers.NewProductCache(size, ttl)
+	}
+	handlers.ProductCacheMaxAge = 0
+	if v := getenv("PRODUCT_CACHE_MAX_AGE"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			invalid("PRODUCT_CACHE_MAX_AGE", v)
+		} else {
+			handlers.ProductCacheMaxAge = d
+		}
+	}
+
+	// JOB_RESULT_TTL：异步任务结果文件的保留时间（默认 24h），过期后删除；0 表示永久保留。
+	if v := getenv("JOB_RESULT_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			invalid("JOB_RESULT_TTL", v)
+		} else {
+			jobs.ResultTTL = d
+		}
+	}
+
+	if err := configureJWT(getenv); err != nil {
+		errs = append(errs, err)
+	}
+	if err := configureBackups(getenv); err != nil {
+		errs = append(errs, err)
+	}
+	if err := configureDB(getenv); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// configureDB 读取 SQLite 的连接参数（见 utils.SQLiteConfig），未设置的项保留默认值。
+// 除 serve 外，直接打开数据库的子命令（migrate、seed、backup）也使用这些参数。
+func configureDB(getenv func(string) string) error {
+	cfg := utils.DBConfig
+	var errs []error
+	// DB_JOURNAL_MODE（默认 WAL）/ DB_SYNCHRONOUS（默认 NORMAL）：SQLite 的 journal_mode 与 synchronous。
+	if v := getenv("DB_JOURNAL_MODE"); v != "" {
+		cfg.JournalMode = strings.ToUpper(v)
+	}
+	if v := getenv("DB_SYNCHRONOUS"); v != "" {
+		cfg.Synchronous = strings.ToUpper(v)
+	}
+	// DB_BUSY_TIMEOUT：等待其他连接释放锁的最长时间（默认 5s）。
+	if v := getenv("DB_BUSY_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("invalid DB_BUSY_TIMEOUT: %q", v))
+		} else {
+			cfg.BusyTimeout = d
+		}
+	}
+	// DB_FOREIGN_KEYS=false：关闭外键检查。
+	if v := getenv("DB_FOREIGN_KEYS"); v != "" {
+		if b, err := strconv.ParseBool(v); err != nil {
+			errs = append(errs, fmt.Errorf("invalid DB_FOREIGN_KEYS: %q", v))
+		} else {
+			cfg.ForeignKeys = b
+		}
+	}
+	// DB_MAX_READ_CONNS：只读连接池的最大连接数（默认 4）；写连接始终只有一个。
+	if v := getenv("DB_MAX_READ_CONNS"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 {
+			errs = append(errs, fmt.Errorf("invalid DB_MAX_READ_CONNS: %q", v))
+		} else {
+			cfg.MaxReadConns = n
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid database configuration: %w", err))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	utils.DBConfig = cfg
+	return nil
+}
+
+// configureJWT 在设置了 JWT_JWKS 时加载 SSO 的公钥并启用 JWT 认证。
+func configureJWT(getenv func(string) string) error {
+	handlers.JWTVerifier = nil
+	// JWT_JWKS：SSO 的 JWKS 地址（本地文件路径或 URL）；设置后接受 SSO 签发的 JWT。
+	source := getenv("JWT_JWKS")
+	if source == "" {
+		return nil
+	}
+	keys, err := auth.LoadKeySet(source)
+	if err != nil {
+		return fmt.Errorf("failed to load JWT_JWKS: %w", err)
+	}
+	// JWT_JWKS_REFRESH：JWKS 缓存有效期（URL 响应带 Cache-Control: max-age 时以响应为准）。
+	if refresh := getenv("JWT_JWKS_REFRESH"); refresh != "" {
+		d, err := time.ParseDuration(refresh)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid JWT_JWKS_REFRESH: %q", refresh)
+		}
+		keys.RefreshInterval = d
+	}
+
+	verifier := &auth.JWTVerifier{
+		Keys:      keys,
+		Issuer:    getenv("JWT_ISSUER"),
+		Audience:  getenv("JWT_AUDIENCE"),
+		RoleClaim: getenv("JWT_ROLE_CLAIM"),
+		// JWT_TENANT_CLAIM：承载租户 ID 的 claim 名；设置后 token 只能访问该租户，非平台级 token 缺少该 claim 时认证失败。
+		TenantClaim: getenv("JWT_TENANT_CLAIM"),
+		// JWT_PLATFORM_CLAIM：标记平台级 token 的 claim 名（值为 true），只对 admin 且不带租户 claim 的 token 生效。
+		PlatformClaim: getenv("JWT_PLATFORM_CLAIM"),
+		Leeway:        30 * time.Second,
+	}
+	// JWT_ROLE_MAP：claim 值到角色的映射，例如 "sso-admins=admin,sso-editors=editor"。
+	if spec := getenv("JWT_ROLE_MAP"); spec != "" {
+		if verifier.RoleMapping, err = auth.ParseRoleMapping(spec); err != nil {
+			return fmt.Errorf("invalid JWT_ROLE_MAP: %w", err)
+		}
+	}
+	handlers.JWTVerifier = verifier
+	log.Printf("JWT authentication enabled (JWKS: %s)", source)
+	return nil
+}
+
+// configureBackups 在设置了 BACKUP_DIR 时启用备份：admin 接口可以触发与列出备份，设置了 BACKUP_INTERVAL 时 serve 定时备份。
+func configureBackups(getenv func(string) string) error {
+	handlers.Backups = nil
+	dir := getenv("BACKUP_DIR")
+	if dir == "" {
+		return nil
+	}
+	// BACKUP_KEEP：保留最新的几个备份（默认 7，0 表示全部保留）；BACKUP_COMPRESS=false 时不压缩。
+	scheduler := &backup.Scheduler{Dir: dir, Keep: 7, Compress: getenv("BACKUP_COMPRESS") != "false"}
+	var errs []error
+	// BACKUP_INTERVAL：定时备份的间隔，例如 "6h"；不设置时只能通过 admin 接口手动备份。
+	if v := getenv("BACKUP_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("invalid BACKUP_INTERVAL: %q", v))
+		} else {
+			scheduler.Interval = d
+		}
+	}
+	if v := getenv("BACKUP_KEEP"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("invalid BACKUP_KEEP: %q", v))
+		} else {
+			scheduler.Keep = n
+		}
+	}
+	handlers.Backups = scheduler
+	return errors.Join(errs...)
+}
@@ -0,0 +1,357 @@
+// server 是产品服务的入口：serve 启动 HTTP 服务，其余子命令用于部署与运维（建表、填充示例数据、备份恢复、检查配置）。
+// 配置来自环境变量（见 configure），数据库路径等与部署位置相关的选项来自命令行参数。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	// auth：API 密钥认证与角色授权。
+	"golang-starter/auth"
+	// backup：在线备份、定时备份与恢复。
+	"golang-starter/backup"
+	// handlers：HTTP 路由注册与各接口处理函数（controller/handler 层）。
+	"golang-starter/handlers"
+	// jobs：基于数据库的异步任务队列（worker 池）。
+	"golang-starter/jobs"
+	"golang-starter/models"
+	// utils：数据库初始化与关闭（全局 DB）。
+	"golang-starter/utils"
+	"golang-starter/version"
+)
+
+// 退出码：配置错误（包括命令行参数错误）与运行时失败分开，部署脚本可以据此决定是否重试。
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitConfigError = 2
+)
+
+const usage = `Usage: server <command> [flags]
+
+Commands:
+  serve          start the HTTP server (default)
+  migrate        create or upgrade the database schema
+  seed           insert sample products
+  backup <file>  write a consistent snapshot of the database (gzip if <file> ends in .gz)
+  verify <file>  check a backup's checksum, integrity and schema version
+  restore <file> replace the database with a verified backup (stop the server first)
+  version        print build information
+  config-check   validate the environment configuration and exit
+
+Run "server <command> -h" for the flags of a command.
+Exit codes: 0 success, 1 runtime failure, 2 configuration or usage error.
+`
+
+// errConfig 包装配置与参数错误，run 据此返回 exitConfigError。
+type errConfig struct{ err error }
+
+func (e errConfig) Error() string { return e.err.Error() }
+func (e errConfig) Unwrap() error { return e.err }
+
+type command func(ctx context.Context, args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"serve":        serveCommand,
+	"migrate":      migrateCommand,
+	"seed":         seedCommand,
+	"backup":       backupCommand,
+	"verify":       verifyCommand,
+	"restore":      restoreCommand,
+	"version":      versionCommand,
+	"config-check": configCheckCommand,
+}
+
+// main：程序入口；SIGINT / SIGTERM 会取消 ctx，serve 收到后优雅退出。
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	name := "serve"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "server: unknown command %q\n\n%s", name, usage)
+		return exitConfigError
+	}
+
+	if err := cmd(ctx, args, stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		var cfgErr errConfig
+		if errors.As(err, &cfgErr) {
+			fmt.Fprintf(stderr, "server %s: configuration error:\n%v\n", name, err)
+			return exitConfigError
+		}
+		fmt.Fprintf(stderr, "server: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// newFlagSet 创建子命令的参数集；withDB 为 true 时注册 -db（默认取环境变量 DB_PATH）。
+func newFlagSet(name string, withDB bool) *flag.FlagSet {
+	fs := flag.NewFlagSet("server "+name, flag.ContinueOnError)
+	if withDB {
+		def := os.Getenv("DB_PATH")
+		if def == "" {
+			def = utils.DBPath
+		}
+		fs.StringVar(&utils.DBPath, "db", def, "SQLite database `path` (env DB_PATH)")
+	}
+	return fs
+}
+
+// parseFlags 解析参数并检查位置参数的个数；参数错误按配置错误处理。
+func parseFlags(fs *flag.FlagSet, args []string, positional int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errConfig{err}
+	}
+	if fs.NArg() != positional {
+		fs.Usage()
+		return errConfig{fmt.Errorf("expected %d argument(s), got %d", positional, fs.NArg())}
+	}
+	return nil
+}
+
+// serveCommand 启动 HTTP 服务，直到 ctx 被取消（SIGINT / SIGTERM）后优雅关闭。
+func serveCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("serve", true)
+	addr := fs.String("addr", envOr("LISTEN_ADDR", ":8080"), "listen `address` (env LISTEN_ADDR)")
+	workers := fs.Int("workers", 4, "number of background job workers")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 15*time.Second, "how long to wait for in-flight requests on shutdown")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *workers < 1 {
+		return errConfig{fmt.Errorf("invalid -workers: %d", *workers)}
+	}
+	if err := configure(os.Getenv); err != nil {
+		return errConfig{err}
+	}
+
+	// 初始化数据库连接：打开 SQLite 文件并确保表存在。
+	utils.InitDB()
+	// defer 按后进先出执行：先停止 worker，再关闭数据库。
+	defer utils.CloseDB()
+
+	// 引导 admin 密钥：没有可用的 admin 密钥时，用 ADMIN_API_KEY 创建一把；未设置时随机生成并打印一次。
+	adminKey, err := auth.EnsureAdminKey(ctx, utils.DB, os.Getenv("ADMIN_API_KEY"))
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap admin API key: %w", err)
+	}
+	if adminKey != "" && os.Getenv("ADMIN_API_KEY") == "" {
+		log.Printf("Created bootstrap admin API key (shown only once): %s", adminKey)
+	}
+
+	// 启动异步任务 worker：导出、批量创建等耗时操作在后台执行，进程重启后未完成的任务会继续执行。
+	if err := jobs.Start(ctx, utils.DB, *workers); err != nil {
+		return fmt.Errorf("failed to start job workers: %w", err)
+	}
+	defer jobs.Stop()
+
+	// 定时备份（BACKUP_DIR 与 BACKUP_INTERVAL）：ctx 取消后停止，进行中的备份随之中断。
+	if handlers.Backups != nil {
+		go handlers.Backups.Run(ctx, utils.BackupDB)
+	}
+
+	// 创建 HTTP 路由器并注册路由：把各 API path（/api/health、/api/products...）绑定到 handler 函数。
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux)
+	srv := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	log.Printf("Server %s is running on %s", version.Version, *addr)
+
+	select {
+	case err := <-errc:
+		// 监听失败（例如端口被占用）。
+		return err
+	case <-ctx.Done():
+	}
+	log.Println("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// migrateCommand 建表并补齐旧版本缺少的列（utils.InitDB 中的迁移是幂等的）。
+func migrateCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	if err := parseFlags(newFlagSet("migrate", true), args, 0); err != nil {
+		return err
+	}
+	if err := openDB(); err != nil {
+		return err
+	}
+	defer utils.CloseDB()
+	fmt.Fprintf(stdout, "database %s is up to date\n", utils.DBPath)
+	return nil
+}
+
+// seedCommand 为开发与演示环境插入示例产品。
+func seedCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("seed", true)
+	count := fs.Int("count", 20, "number of products to insert")
+	tenant := fs.String("tenant", models.DefaultTenant, "tenant `id`")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *count < 1 {
+		return errConfig{fmt.Errorf("invalid -count: %d", *count)}
+	}
+	if !models.ValidTenantID(*tenant) {
+		return errConfig{fmt.Errorf("invalid -tenant: %q", *tenant)}
+	}
+
+	if err := openDB(); err != nil {
+		return err
+	}
+	defer utils.CloseDB()
+
+	products := make([]*models.Product, *count)
+	for i := range products {
+		products[i] = &models.Product{
+			Name:  fmt.Sprintf("Sample product %d", i+1),
+			Price: float64(10+i%90) - 0.01,
+			Stock: (i * 7) % 50,
+		}
+	}
+	created, err := models.ProductsBulk(ctx, utils.DB, *tenant, products)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "inserted %d products into tenant %s\n", len(created), *tenant)
+	return nil
+}
+
+// backupCommand 写一个一致的快照，服务运行期间也可以执行；同时写出 <file>.sha256 校验文件。
+func backupCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("backup", true)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if err := openDB(); err != nil {
+		return err
+	}
+	defer utils.CloseDB()
+
+	info, err := backup.Create(ctx, utils.BackupDB, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "backed up %s to %s (%d bytes, sha256 %s)\n", utils.DBPath, fs.Arg(0), info.Size, info.SHA256)
+	return nil
+}
+
+// verifyCommand 只检查备份，不修改数据库。
+func verifyCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("verify", false)
+	noVerify := noVerifyFlag(fs)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	info, err := backup.Verify(fs.Arg(0), !*noVerify)
+	if err != nil {
+		return err
+	}
+	printVerified(stdout, fs.Arg(0), info)
+	fmt.Fprintf(stdout, "%s is a valid backup\n", fs.Arg(0))
+	return nil
+}
+
+// restoreCommand 用备份替换数据库文件；服务运行期间不能恢复，否则正在使用的连接仍然指向旧文件。
+func restoreCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("restore", true)
+	noVerify := noVerifyFlag(fs)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	info, err := backup.Restore(fs.Arg(0), utils.DBPath, !*noVerify)
+	if err != nil {
+		return err
+	}
+	printVerified(stdout, fs.Arg(0), info)
+	fmt.Fprintf(stdout, "restored %s from %s (schema version %d)\n", utils.DBPath, fs.Arg(0), info.SchemaVersion)
+	return nil
+}
+
+// noVerifyFlag 注册 -no-verify：默认没有校验文件的备份不能通过检查，只有显式指定时才跳过校验和检查。
+func noVerifyFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("no-verify", false, "accept a backup without a "+backup.ChecksumSuffix+" checksum file (an existing checksum file is still checked)")
+}
+
+func printVerified(w io.Writer, path string, info *backup.Info) {
+	if info.Verified {
+		fmt.Fprintf(w, "checksum OK (sha256 %s)\n", info.SHA256)
+	} else {
+		fmt.Fprintf(w, "warning: %s not found, checksum not verified\n", path+backup.ChecksumSuffix)
+	}
+}
+
+func versionCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := newFlagSet("version", false)
+	asJSON := fs.Bool("json", false, "print as JSON")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	info := version.Get()
+	if *asJSON {
+		return json.NewEncoder(stdout).Encode(info)
+	}
+	fmt.Fprintf(stdout, "version:    %s\ncommit:     %s\nbuild time: %s\ngo:         %s\n", info.Version, info.Commit, info.BuildTime, info.GoVersion)
+	return nil
+}
+
+// configCheckCommand 只检查配置，不打开数据库也不监听端口，适合在部署前执行。
+func configCheckCommand(ctx context.Context, args []string, stdout io.Writer) error {
+	if err := parseFlags(newFlagSet("config-check", false), args, 0); err != nil {
+		return err
+	}
+	if err := configure(os.Getenv); err != nil {
+		return errConfig{err}
+	}
+	fmt.Fprintln(stdout, "configuration OK")
+	return nil
+}
+
+// openDB 按环境变量中的 SQLite 参数打开数据库（serve 通过 configure 读取同样的参数）。
+func openDB() error {
+	if err := configureDB(os.Getenv); err != nil {
+		return errConfig{err}
+	}
+	utils.InitDB()
+	return nil
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang-starter/auth"
+	"golang-starter/models"
+	"golang-starter/utils"
+	"golang-starter/validation"
+)
+
+// createAPIKeyRequest 是创建密钥的请求体。
+type createAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	Role string `json:"role" validate:"required,oneof=viewer editor admin"`
+	// Tenant：非空时密钥只能访问该租户。
+	Tenant string `json:"tenant" validate:"max=63,regex=^([a-z0-9][a-z0-9-]*)?$"`
+	// Platform：创建平台级密钥，要求 role 为 admin 且不指定 tenant。
+	Platform bool `json:"platform"`
+}
+
+// apiKeyWithSecret 在密钥记录之外附带明文密钥；只在创建与轮换的响应中出现一次。
+type apiKeyWithSecret struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// HandleAPIKeys 处理密钥集合：
+// - GET  /api/admin/keys：列出所有密钥（不含明文）
+// - POST /api/admin/keys：创建密钥
+func HandleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		ListAPIKeys(w, r)
+	case "POST":
+		CreateAPIKey(w, r)
+	default:
+		writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+// HandleAPIKey 处理单个密钥：
+// - POST   /api/admin/keys/{id}/rotate：轮换密钥，旧明文立即失效
+// - DELETE /api/admin/keys/{id}：吊销密钥
+func HandleAPIKey(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/admin/keys/")
+	parts := strings.SplitN(path, "/", 2)
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		writeError(w, r, errInvalidID)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == "DELETE":
+		RevokeAPIKey(w, r, id)
+	case action == "rotate" && r.Method == "POST":
+		RotateAPIKey(w, r, id)
+	case action == "" || action == "rotate":
+		writeError(w, r, errMethodNotAllowed)
+	default:
+		writeError(w, r, errNotFound)
+	}
+}
+
+// ListAPIKeys 列出所有密钥。
+func ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := models.ListAPIKeys(r.Context(), utils.ReadDB)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeSuccess(w, r, http.StatusOK, successResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    keys,
+	})
+}
+
+// CreateAPIKey 创建密钥并返回 201；响应中的 key 是唯一一次能看到的明文。
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req createAPIKeyRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, r, invalidJSON(err))
+		return
+	}
+	if errs := validation.Struct(&req); len(errs) > 0 {
+		writeError(w, r, errs)
+		return
+	}
+	if req.Platform && (auth.Role(req.Role) != auth.Admin || req.Tenant != "") {
+		writeError(w, r, validation.Errors{validation.NewFieldError("platform", "platform", "validation.platform", "")})
+		return
+	}
+
+	key, plaintext, err := auth.CreateKey(r.Context(), utils.DB, req.Name, auth.Role(req.Role), req.Tenant, req.Platform)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/admin/keys/%d", key.ID))
+	w.Header().Set("Cache-Control", "no-store")
+	writeSuccess(w, r, http.StatusCreated, successResponse{
+		Code:    http.StatusCreated,
+		Message: "success",
+		Data:    apiKeyWithSecret{APIKey: key, Key: plaintext},
+	})
+}
+
+// RotateAPIKey 轮换密钥；已吊销的密钥返回 409。
+func RotateAPIKey(w http.ResponseWriter, r *http.Request, id int) {
+	key, plaintext, err := auth.RotateKey(r.Context(), utils.DB, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeSuccess(w, r, http.StatusOK, successResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    apiKeyWithSecret{APIKey: key, Key: plaintext},
+	})
+}
+
+// RevokeAPIKey 吊销密钥；记录会保留，revoked_at 表示吊销时间。
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request, id int) {
+	key, err := models.RevokeAPIKey(r.Context(), utils.DB, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeSuccess(w, r, http.StatusOK, successResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    key,
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/utils"
+)
+
+// AuthEnabled 控制是否要求认证，默认开启。
+// 关闭后所有请求都不做认证与授权检查，只应在测试等受控环境中使用。
+var AuthEnabled = true
+
+// JWTVerifier 用于校验 SSO 签发的 JWT；为 nil 时只接受 API 密钥。
+var JWTVerifier *auth.JWTVerifier
+
+var (
+	errUnauthorized       = apierror.New(http.StatusUnauthorized, apierror.Unauthorized)
+	errInvalidCredentials = apierror.NewVariant(http.StatusUnauthorized, apierror.Unauthorized, "invalid")
+)
+
+// accessPolicy 根据请求决定访问所需的最低角色。
+type accessPolicy func(r *http.Request) auth.Role
+
+// readWrite：GET / HEAD 只需要 viewer，其余（写操作）需要 editor。
+func readWrite(r *http.Request) auth.Role {
+	if r.Method == "GET" || r.Method == "HEAD" {
+		return auth.Viewer
+	}
+	return auth.Editor
+}
+
+// adminOnly：所有方法都需要 admin。
+func adminOnly(*http.Request) auth.Role {
+	return auth.Admin
+}
+
+// withAuth 要求请求携带有效凭证，且角色满足 policy；通过后把调用方写入 request context。
+// - 未携带凭证或凭证无效：401，并带上 WWW-Authenticate 头
+// - 角色不足：403
+func withAuth(policy accessPolicy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !AuthEnabled {
+			next(w, r)
+			return
+		}
+
+		credential := requestCredential(r)
+		if credential == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(w, r, errUnauthorized)
+			return
+		}
+
+		principal, err := authenticate(r.Context(), credential)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				log.Printf("authentication failed: %v", err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+				writeError(w, r, errInvalidCredentials)
+			} else {
+				writeError(w, r, err)
+			}
+			return
+		}
+
+		if required := policy(r); !principal.Role.Allows(required) {
+			writeError(w, r, apierror.New(http.StatusForbidden, apierror.Forbidden, "role", string(required)))
+			return
+		}
+
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// authenticate 按凭证格式选择认证方式：配置了 JWTVerifier 时 JWT 格式的凭证按 JWT 校验，其余按 API 密钥校验。
+func authenticate(ctx context.Context, credential string) (*auth.Principal, error) {
+	if JWTVerifier != nil && auth.LooksLikeJWT(credential) {
+		return JWTVerifier.Verify(credential)
+	}
+	return auth.AuthenticateKey(ctx, utils.ReadDB, utils.DB, credential)
+}
+
+// requestCredential 读取请求携带的凭证：优先 X-API-Key，其次 Authorization: Bearer。
+func requestCredential(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
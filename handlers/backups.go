@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"golang-starter/apierror"
+	"golang-starter/backup"
+	"golang-starter/utils"
+)
+
+// Backups 保存备份的目录与保留策略；为 nil（未设置 BACKUP_DIR）时备份接口返回 409 BACKUP_NOT_CONFIGURED。
+var Backups *backup.Scheduler
+
+// HandleBackups 处理数据库备份：
+// - GET  /api/admin/backups：列出备份目录中的备份，最新的在前
+// - POST /api/admin/backups：立即备份一次（服务不停机），并按保留策略删除旧备份
+//
+// 恢复需要停止服务，只能通过 server restore 命令执行。
+func HandleBackups(w http.ResponseWriter, r *http.Request) {
+	if Backups == nil {
+		writeError(w, r, apierror.New(http.StatusConflict, apierror.BackupNotConfigured))
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		backups, err := Backups.List()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeSuccess(w, r, http.StatusOK, successResponse{
+			Code:    http.StatusOK,
+			Message: "success",
+			Data:    backups,
+		})
+	case "POST":
+		// 备份使用独立的 BackupDB 连接，不占用唯一的写连接，备份期间写请求照常执行。
+		info, err := Backups.Snapshot(r.Context(), utils.BackupDB)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeSuccess(w, r, http.StatusCreated, successResponse{
+			Code:    http.StatusCreated,
+			Message: "success",
+			Data:    info,
+		})
+	default:
+		writeError(w, r, errMethodNotAllowed)
+	}
+}
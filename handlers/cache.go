@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"golang-starter/cache"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+// ProductCache 缓存单个产品与产品列表页的查询结果；为 nil 时不缓存，每次都查询数据库。
+// 通过本服务的写接口修改产品时精确失效；其他进程（例如本地模式的 productctl）直接写数据库时，
+// 最多在 TTL 之后才能看到变化，也可以调用 DELETE /api/admin/cache 立即清空。
+var ProductCache = NewProductCache(1000, 30*time.Second)
+
+// ProductCacheMaxAge 是产品读取接口 Cache-Control 的 max-age；0 时为 "private, no-cache"，客户端每次都要重新请求。
+var ProductCacheMaxAge time.Duration
+
+// ProductCacheStore 是两个 LRU 缓存：按 (租户, ID) 保存单个产品，按 (租户, limit, offset, order) 保存列表页。
+type ProductCacheStore struct {
+	products *cache.LRU[productCacheKey, models.Product]
+	lists    *cache.LRU[listCacheKey, []*models.Product]
+
+	mu sync.Mutex
+	// version：每次失效加 1。查询前记下版本，写回缓存时版本已经变化说明查询期间发生了写入，
+	// 查到的可能是旧数据，不再写回（只是少缓存一次，不影响正确性）。
+	version uint64
+	// generation：utils.SchemaGeneration；数据库重新初始化（迁移、恢复）后整个缓存作废。
+	generation uint64
+}
+
+type productCacheKey struct {
+	tenant string
+	id     int
+}
+
+type listCacheKey struct {
+	tenant string
+	params models.GetAllProductsParams
+}
+
+// NewProductCache 创建缓存；size 是每类条目的最大数量，ttl 是条目的有效期。
+func NewProductCache(size int, ttl time.Duration) *ProductCacheStore {
+	return &ProductCacheStore{
+		products: cache.New[productCacheKey, models.Product](size, ttl),
+		lists:    cache.New[listCacheKey, []*models.Product](size, ttl),
+	}
+}
+
+// currentVersion 返回当前版本；数据库重新初始化后先清空缓存。
+func (c *ProductCacheStore) currentVersion() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation := utils.SchemaGeneration(); c.generation != generation {
+		c.purge()
+		c.generation = generation
+	}
+	return c.version
+}
+
+// store 在版本没有变化时执行 set。
+func (c *ProductCacheStore) store(version uint64, set func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.version == version {
+		set()
+	}
+}
+
+// invalidate 使版本加 1，并在同一把锁内执行 drop 删除受影响的条目。
+func (c *ProductCacheStore) invalidate(drop func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	drop()
+}
+
+// Product 先查缓存，未命中时调用 load 并写回；hit 表示结果是否来自缓存。c 为 nil 时直接调用 load。
+// 不存在的产品（load 返回错误）不缓存。
+func (c *ProductCacheStore) Product(tenant string, id int, load func() (*models.Product, error)) (product *models.Product, hit bool, err error) {
+	if c == nil {
+		product, err = load()
+		return product, false, err
+	}
+
+	key := productCacheKey{tenant: tenant, id: id}
+	version := c.currentVersion()
+	if cached, ok := c.products.Get(key); ok {
+		return &cached, true, nil
+	}
+	if product, err = load(); err != nil {
+		return nil, false, err
+	}
+	c.store(version, func() { c.products.Set(key, *product) })
+	return product, false, nil
+}
+
+// List 与 Product 相同，缓存的是一页产品列表；缓存中的切片是共享的，调用方不能修改。
+func (c *ProductCacheStore) List(tenant string, params models.GetAllProductsParams, load func() ([]*models.Product, error)) (products []*models.Product, hit bool, err error) {
+	if c == nil {
+		products, err = load()
+		return products, false, err
+	}
+
+	key := listCacheKey{tenant: tenant, params: params}
+	version := c.currentVersion()
+	if cached, ok := c.lists.Get(key); ok {
+		return cached, true, nil
+	}
+	if products, err = load(); err != nil {
+		return nil, false, err
+	}
+	c.store(version, func() { c.lists.Set(key, products) })
+	return products, false, nil
+}
+
+// ProductChanged 在产品被更新（PUT / PATCH）后调用：删除该产品，以及包含它的列表页。
+// 更新不改变产品的 ID，因此不包含它的列表页仍然有效。
+func (c *ProductCacheStore) ProductChanged(tenant string, id int) {
+	if c == nil {
+		return
+	}
+	c.invalidate(func() {
+		c.products.Delete(productCacheKey{tenant: tenant, id: id})
+		c.lists.DeleteFunc(func(key listCacheKey, products []*models.Product) bool {
+			return key.tenant == tenant && slices.ContainsFunc(products, func(p *models.Product) bool { return p.ID == id })
+		})
+	})
+}
+
+// ProductDeleted 在产品被删除后调用：删除该产品与租户的所有列表页（之后的页都会前移）。
+func (c *ProductCacheStore) ProductDeleted(tenant string, id int) {
+	if c == nil {
+		return
+	}
+	c.invalidate(func() {
+		c.products.Delete(productCacheKey{tenant: tenant, id: id})
+		c.dropLists(tenant)
+	})
+}
+
+// ProductsCreated 在创建（包括批量创建）产品后调用：新产品可能出现在租户的任何一页，删除租户的所有列表页；
+// 单个产品的缓存不受影响（不存在的产品本来就不缓存）。
+func (c *ProductCacheStore) ProductsCreated(tenant string) {
+	if c == nil {
+		return
+	}
+	c.invalidate(func() { c.dropLists(tenant) })
+}
+
+func (c *ProductCacheStore) dropLists(tenant string) {
+	c.lists.DeleteFunc(func(key listCacheKey, _ []*models.Product) bool { return key.tenant == tenant })
+}
+
+// Purge 清空缓存（命中统计保留）。
+func (c *ProductCacheStore) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purge()
+}
+
+// purge 清空条目并使版本加 1：清空之前开始的查询不能再写回；调用方持有 c.mu。
+func (c *ProductCacheStore) purge() {
+	c.version++
+	c.products.Purge()
+	c.lists.Purge()
+}
+
+// Stats 返回两类条目的命中统计。
+func (c *ProductCacheStore) Stats() (products, lists cache.Stats) {
+	return c.products.Stats(), c.lists.Stats()
+}
+
+// cacheControlProductRead 写出产品读取接口的缓存相关响应头：
+// - Cache-Control：private（响应与凭证、租户相关，共享缓存不能保存），max-age 由 ProductCacheMaxAge 决定
+// - Vary：租户请求头不同，内容不同
+// - X-Cache：HIT / MISS，表示服务端缓存是否命中（关闭缓存时不输出）
+func cacheControlProductRead(w http.ResponseWriter, hit bool) {
+	if ProductCacheMaxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(ProductCacheMaxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	addVary(w, TenantHeader)
+	if ProductCache == nil {
+		return
+	}
+	if hit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+}
+
+// withNoStore 为写操作的响应加上 Cache-Control: no-store，包括幂等重放的响应。
+func withNoStore(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		next(w, r)
+	}
+}
+
+type cacheStatsResponse struct {
+	Enabled  bool         `json:"enabled"`
+	Products *cache.Stats `json:"products,omitempty"`
+	Lists    *cache.Stats `json:"lists,omitempty"`
+}
+
+func productCacheStats() cacheStatsResponse {
+	if ProductCache == nil {
+		return cacheStatsResponse{}
+	}
+	products, lists := ProductCache.Stats()
+	return cacheStatsResponse{Enabled: true, Products: &products, Lists: &lists}
+}
+
+// HandleCache 管理产品缓存：
+// - GET    /api/admin/cache：条目数与命中率
+// - DELETE /api/admin/cache：清空缓存（例如绕过本服务直接修改了数据库之后）
+func HandleCache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "DELETE":
+		ProductCache.Purge()
+	default:
+		writeError(w, r, errMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeSuccess(w, r, http.StatusOK, successResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    productCacheStats(),
+	})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionEncodings 是支持的响应压缩算法，按服务端偏好排序；客户端给出相同 q 值时取靠前的一个。
+// 为空时不压缩。
+var CompressionEncodings = []string{"zstd", "br", "gzip"}
+
+// CompressionMinSize：响应体小于该字节数时不压缩，压缩小响应得不偿失。
+var CompressionMinSize = 1024
+
+// newEncoders 创建各算法的压缩 writer。
+var newEncoders = map[string]func(w io.Writer) (io.WriteCloser, error){
+	"gzip": func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	},
+	"br": func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	},
+	"zstd": func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	},
+}
+
+// ValidateCompressionEncodings 检查 CompressionEncodings 中的算法是否都受支持。
+func ValidateCompressionEncodings() error {
+	for _, encoding := range CompressionEncodings {
+		if _, ok := newEncoders[encoding]; !ok {
+			return fmt.Errorf("unsupported compression encoding %q", encoding)
+		}
+	}
+	return nil
+}
+
+// withCompression 按 Accept-Encoding 协商压缩算法并压缩响应体。
+// 响应体先缓冲到 CompressionMinSize，达到阈值才开始压缩；已经带 Content-Encoding、
+// 内容本身已压缩（例如 xlsx）或 Range 请求的响应原样输出。
+func withCompression(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == "HEAD" || r.Header.Get("Range") != "" {
+			next(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next(cw, r)
+	}
+}
+
+// negotiateEncoding 从 Accept-Encoding 中选出 q 值最高的受支持算法；"*" 匹配所有算法，q=0 表示不接受。
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	quality := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := parseQuality(params)
+		if name == "*" {
+			wildcard = q
+		} else {
+			quality[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range CompressionEncodings {
+		q, ok := quality[name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter 缓冲响应体直到能决定是否压缩。
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         bytes.Buffer
+	enc         io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+	// 没有响应体的状态码不需要等待。
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf.Write(p)
+	if cw.buf.Len() >= CompressionMinSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush 让流式响应（例如导出）尽早发出：未决定时按已缓冲的大小决定是否压缩。
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		return
+	}
+	if !cw.decided {
+		cw.decide(cw.buf.Len() >= CompressionMinSize)
+	}
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close 输出剩余的缓冲并结束压缩流。
+func (cw *compressWriter) Close() error {
+	if !cw.wroteHeader {
+		return nil
+	}
+	if !cw.decided {
+		if err := cw.decide(cw.buf.Len() >= CompressionMinSize); err != nil {
+			return err
+		}
+	}
+	if cw.enc != nil {
+		return cw.enc.Close()
+	}
+	return nil
+}
+
+// decide 写出响应头与已缓冲的内容；compress 为 true 且响应适合压缩时之后的内容都经过压缩。
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+
+	h := cw.Header()
+	if compress && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		enc, err := newEncoders[cw.encoding](cw.ResponseWriter)
+		if err == nil {
+			cw.enc = enc
+			h.Set("Content-Encoding", cw.encoding)
+			// 压缩后长度未知，字节范围也不再对应原始内容。
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
+
+// compressible 判断内容类型是否值得压缩：文本类与 JSON / MessagePack / CBOR 等结构化数据；
+// 图片、压缩包、xlsx 等本身已压缩的格式不再压缩。
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	case mediaType == "application/xml", mediaType == "application/javascript",
+		mediaType == msgpackContentType, mediaType == cborContentType:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+
+	"golang-starter/openapi"
+)
+
+// docsContentSecurityPolicy 放宽 Swagger UI 页面所需的同源脚本、样式（含内联样式）、data: 图片与请求。
+const docsContentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'"
+
+// swaggerInitializer 替换 Swagger UI 自带的初始化脚本，指向本服务的 OpenAPI 文档。
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/api/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+var swaggerUIFiles = http.StripPrefix("/docs/", http.FileServer(http.FS(swaggerFiles.FS)))
+
+// OpenAPISpec 返回 OpenAPI 文档：GET /api/openapi.json
+func OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, r, errMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == "GET" {
+		_, _ = w.Write(openapi.Spec)
+	}
+}
+
+// SwaggerUI 提供内嵌的 Swagger UI：GET /docs/
+func SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, r, errMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == "/docs" {
+		http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", docsContentSecurityPolicy)
+	if strings.TrimPrefix(r.URL.Path, "/docs/") == "swagger-initializer.js" {
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		_, _ = w.Write([]byte(swaggerInitializer))
+		return
+	}
+	swaggerUIFiles.ServeHTTP(w, r)
+}
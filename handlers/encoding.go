@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	msgpackContentType = "application/msgpack"
+	cborContentType    = "application/cbor"
+)
+
+// msgpackAliases 是 MessagePack 常见的非标准媒体类型，客户端使用它们时同样返回 MessagePack。
+var msgpackAliases = []string{"application/x-msgpack", "application/vnd.msgpack"}
+
+// responseFormat 是响应体的编码格式。
+type responseFormat struct {
+	contentType string
+	marshal     func(v any) ([]byte, error)
+}
+
+var (
+	msgpackFormat = responseFormat{msgpackContentType, msgpack.Marshal}
+	cborFormat    = responseFormat{cborContentType, cbor.Marshal}
+)
+
+// negotiateFormat 按 Accept 选择响应体格式：客户端明确要求 MessagePack 或 CBOR，
+// 且 q 值不低于 application/json 时返回对应格式；否则返回 nil，使用 JSON。
+func negotiateFormat(r *http.Request) *responseFormat {
+	if r == nil || r.Header.Get("Accept") == "" {
+		return nil
+	}
+	for _, mediaType := range append([]string{msgpackContentType}, msgpackAliases...) {
+		if prefersMediaType(r, mediaType) {
+			return &msgpackFormat
+		}
+	}
+	if prefersMediaType(r, cborContentType) {
+		return &cborFormat
+	}
+	return nil
+}
+
+// encodeBinary 把 payload 编码为 MessagePack / CBOR。
+// payload 先按 JSON 序列化再转为通用结构，字段名、omitempty、json.RawMessage 与时间格式都与 JSON 响应保持一致。
+func encodeBinary(format *responseFormat, payload any) ([]byte, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return format.marshal(normalizeNumbers(generic))
+}
+
+// normalizeNumbers 把 json.Number 转为 int64 或 float64，整数在二进制格式中保持整数类型。
+func normalizeNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, value := range v {
+			v[key] = normalizeNumbers(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = normalizeNumbers(value)
+		}
+	}
+	return v
+}
+
+// parseQuality 解析 Accept-Encoding 条目的参数部分（例如 "q=0.5"），返回 q 值；未给出时为 1，无法解析时为 0。
+func parseQuality(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
+
+// addVary 向 Vary 头追加 value，已存在时不重复添加。
+func addVary(w http.ResponseWriter, value string) {
+	for _, existing := range w.Header().Values("Vary") {
+		for _, v := range strings.Split(existing, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return
+			}
+		}
+	}
+	w.Header().Add("Vary", value)
+}
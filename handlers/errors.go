@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"golang-starter/apierror"
+	"golang-starter/models"
+	"golang-starter/patch"
+	"golang-starter/validation"
+)
+
+// handler 层常用的请求错误。
+var (
+	errMethodNotAllowed     = apierror.New(http.StatusMethodNotAllowed, apierror.MethodNotAllowed)
+	errNotFound             = apierror.New(http.StatusNotFound, apierror.NotFound)
+	errInvalidID            = apierror.New(http.StatusBadRequest, apierror.InvalidID)
+	errUnsupportedMediaType = apierror.New(http.StatusUnsupportedMediaType, apierror.UnsupportedMediaType)
+)
+
+// invalidJSON 包装请求体 JSON 解析失败的错误；解析错误描述的是客户端输入，可以直接返回。
+// 请求体超出大小上限导致的失败转换为 413。
+func invalidJSON(err error) *apierror.Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return payloadTooLarge(tooLarge.Limit)
+	}
+	return apierror.Wrap(http.StatusBadRequest, apierror.InvalidJSON, err)
+}
+
+// errorMapping 描述一个哨兵错误对应的 HTTP 状态码与错误码。
+// detail 为 true 时把错误本身的文本作为消息参数 {detail}（仅用于可以安全暴露的错误，例如补丁错误的具体位置）。
+type errorMapping struct {
+	target error
+	status int
+	code   apierror.Code
+	detail bool
+}
+
+// errorMappings 是 models 等下层包的哨兵错误到 API 错误的集中映射表。
+var errorMappings = []errorMapping{
+	{models.ErrProductNotFound, http.StatusNotFound, apierror.ProductNotFound, false},
+	{models.ErrQuotaExceeded, http.StatusForbidden, apierror.QuotaExceeded, false},
+	{models.ErrNoFields, http.StatusBadRequest, apierror.NoFieldsToUpdate, false},
+	{models.ErrJobNotFound, http.StatusNotFound, apierror.JobNotFound, false},
+	{models.ErrJobFinished, http.StatusConflict, apierror.JobAlreadyFinished, false},
+	{models.ErrAPIKeyNotFound, http.StatusNotFound, apierror.APIKeyNotFound, false},
+	{models.ErrAPIKeyRevoked, http.StatusConflict, apierror.APIKeyRevoked, false},
+	{models.ErrConflict, http.StatusConflict, apierror.Conflict, false},
+	{models.ErrIdempotencyKeyExists, http.StatusConflict, apierror.IdempotencyKeyInProgress, false},
+	{patch.ErrTestFailed, http.StatusConflict, apierror.PatchTestFailed, true},
+	{patch.ErrInvalidPatch, http.StatusBadRequest, apierror.InvalidPatch, true},
+	{patch.ErrCannotApply, http.StatusUnprocessableEntity, apierror.PatchCannotApply, true},
+	// 请求的 context 被取消：客户端断开是 499，超过 withTimeout 的时限是 503。
+	{context.Canceled, StatusClientClosedRequest, apierror.RequestCanceled, false},
+	{context.DeadlineExceeded, http.StatusServiceUnavailable, apierror.RequestTimeout, false},
+}
+
+// toAPIError 把任意错误转换为 *apierror.Error：
+// - 已经是 *apierror.Error 的原样返回
+// - validation.Errors 转换为 422 VALIDATION_FAILED
+// - 映射表中的哨兵错误按表转换
+// - 其余一律视为内部错误，对客户端只返回固定文案，避免泄露数据库等内部细节
+func toAPIError(err error) *apierror.Error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		return &apierror.Error{
+			Status: http.StatusUnprocessableEntity,
+			Code:   apierror.ValidationFailed,
+			Fields: fieldErrs,
+			Err:    err,
+		}
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.target) {
+			if m.detail {
+				return apierror.Wrap(m.status, m.code, err)
+			}
+			return &apierror.Error{Status: m.status, Code: m.code, Err: err}
+		}
+	}
+
+	return &apierror.Error{
+		Status: http.StatusInternalServerError,
+		Code:   apierror.Internal,
+		Err:    err,
+	}
+}
+
+// writeError 把错误转换为统一错误响应，按请求协商出的语言渲染信息后写出；5xx 错误的原始信息只写日志。
+// 客户端 Accept 明确要求 application/problem+json 时改为输出 RFC 7807 文档。
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := toAPIError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Printf("internal error: %v", err)
+	}
+
+	lang := negotiateLang(w, r)
+	addVary(w, "Accept")
+	if acceptsProblemJSON(r) {
+		writeProblem(w, problemDetails{
+			Type:      problemType(apiErr.Code),
+			Title:     statusText(apiErr.Status),
+			Status:    apiErr.Status,
+			Detail:    apiErr.Message(lang),
+			Instance:  r.URL.Path,
+			ErrorCode: apiErr.Code,
+			Errors:    apiErr.Fields.Localize(lang),
+		})
+		return
+	}
+	writeJSON(w, r, apiErr.Status, errorResponse{
+		Code:      apiErr.Status,
+		ErrorCode: apiErr.Code,
+		Message:   apiErr.Message(lang),
+		Errors:    apiErr.Fields.Localize(lang),
+	})
+}
+
+// statusText 与 http.StatusText 相同，但包含非标准的 499。
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
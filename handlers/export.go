@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/flate"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+// exportFlushEvery：每写出多少行主动 Flush 一次，让客户端尽早收到数据，同时避免缓冲区无限增长。
+const exportFlushEvery = 1000
+
+// exportColumns 是导出文件的表头，顺序与 productRecord 输出的列一致。
+var exportColumns = []string{"id", "name", "price", "stock", "created_at", "updated_at"}
+
+// exportFormat 描述一种导出格式：响应头所需信息 + 创建对应 writer 的工厂函数。
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(w io.Writer) productWriter
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv": {
+		contentType: "text/csv; charset=utf-8",
+		extension:   "csv",
+		newWriter:   newCSVProductWriter,
+	},
+	"ndjson": {
+		contentType: "application/x-ndjson",
+		extension:   "ndjson",
+		newWriter:   newNDJSONProductWriter,
+	},
+	"xlsx": {
+		contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		extension:   "xlsx",
+		newWriter:   newXLSXProductWriter,
+	},
+}
+
+// productWriter 把产品逐行编码到输出流；Flush 把缓冲中已编码的数据写到输出流，Close 负责写出尾部数据（例如 xlsx 的 zip 目录）。
+type productWriter interface {
+	WriteProduct(p *models.Product) error
+	Flush() error
+	Close() error
+}
+
+// ExportProducts 导出产品：GET /api/products/export?format=csv|ndjson|xlsx
+// - 过滤参数与列表接口一致（order/limit/offset），但 limit 不设上限，未传时导出全部
+// - 直接从 sql.Rows 游标边读边写，内存占用不随行数增长
+// - 读到第一行（或确认没有数据）之后才发出响应头：查询开始前出错时按普通错误响应，例如超过处理时限返回 503
+// - 开始输出后出错时中断连接，见下方说明
+func ExportProducts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, r, errMethodNotAllowed)
+		return
+	}
+
+	formatName, params := parseExportRequest(r.URL.Query())
+	format := exportFormats[formatName]
+
+	// async=true：不在请求内导出，而是创建后台任务，完成后通过 /api/jobs/{id}/result 下载。
+	if r.URL.Query().Get("async") == "true" {
+		enqueueJob(w, r, exportJobType, exportJobPayload{Format: formatName, Params: params})
+		return
+	}
+
+	// started：是否已经发出响应头。
+	started := false
+	start := func() {
+		filename := fmt.Sprintf("products-%s.%s", time.Now().UTC().Format("20060102-150405"), format.extension)
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		w.WriteHeader(http.StatusOK)
+		started = true
+	}
+
+	flusher, _ := w.(http.Flusher)
+	pw := format.newWriter(w)
+
+	count := 0
+	err := models.StreamProducts(r.Context(), utils.ReadDB, tenantFromRequest(r), params, func(p *models.Product) error {
+		if !started {
+			start()
+		}
+		if err := pw.WriteProduct(p); err != nil {
+			return err
+		}
+		count++
+		if flusher != nil && count%exportFlushEvery == 0 {
+			// 先把 writer 自己缓冲的数据写出，否则 Flush 只能发出之前已经写到 w 的部分。
+			if err := pw.Flush(); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		// 还没有任何输出（例如打开游标时超过时限或客户端已断开），可以正常返回错误响应。
+		writeError(w, r, err)
+		return
+	}
+	if err == nil {
+		if !started {
+			start()
+		}
+		err = pw.Close()
+	}
+	if err != nil {
+		// 响应头已经发出，无法再改成错误状态码（例如 EXPORT_REQUEST_TIMEOUT 到期）。直接中断连接而不是正常结束响应：
+		// 分块传输缺少结束块（HTTP/2 为 RST_STREAM），客户端读取时会报错，不会把截断的文件当成完整的导出。
+		log.Printf("export products (%s) aborted after %d rows: %v", formatName, count, err)
+		panic(http.ErrAbortHandler)
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// parseExportRequest 读取导出参数；format / limit / offset / order 已经由 withValidation 校验过。
+// 与列表接口不同，limit 未传（或为 0）时导出全部。
+func parseExportRequest(query url.Values) (formatName string, params models.GetAllProductsParams) {
+	formatName = query.Get("format")
+	if formatName == "" {
+		formatName = "csv"
+	}
+	return formatName, listParams(query, 0)
+}
+
+// productRecord 把产品转成导出用的字符串列（时间统一为 RFC3339）。
+func productRecord(p *models.Product) []string {
+	return []string{
+		strconv.Itoa(p.ID),
+		p.Name,
+		strconv.FormatFloat(p.Price, 'f', -1, 64),
+		strconv.Itoa(p.Stock),
+		p.CreatedAt.Format(time.RFC3339),
+		p.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type csvProductWriter struct {
+	cw          *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVProductWriter(w io.Writer) productWriter {
+	return &csvProductWriter{cw: csv.NewWriter(w)}
+}
+
+func (c *csvProductWriter) WriteProduct(p *models.Product) error {
+	if !c.wroteHeader {
+		if err := c.cw.Write(exportColumns); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+	return c.cw.Write(productRecord(p))
+}
+
+func (c *csvProductWriter) Flush() error {
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+func (c *csvProductWriter) Close() error {
+	// 没有任何数据时也输出表头，保证文件可被正常识别。
+	if !c.wroteHeader {
+		if err := c.cw.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+type ndjsonProductWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONProductWriter(w io.Writer) productWriter {
+	return &ndjsonProductWriter{enc: json.NewEncoder(w)}
+}
+
+// WriteProduct：json.Encoder.Encode 每次输出一个对象并追加换行，正好是 NDJSON 的格式。
+func (n *ndjsonProductWriter) WriteProduct(p *models.Product) error {
+	return n.enc.Encode(p)
+}
+
+// Flush：json.Encoder 不缓冲，每个对象都直接写到输出流。
+func (n *ndjsonProductWriter) Flush() error {
+	return nil
+}
+
+func (n *ndjsonProductWriter) Close() error {
+	return nil
+}
+
+// xlsxMaxRows：单个工作表最多 1048576 行（含表头），超出后自动切换到下一个工作表。
+const xlsxMaxRows = 1048576
+
+// xlsxNumericColumns 标记 exportColumns 中哪些列按数值写入（id/price/stock）。
+var xlsxNumericColumns = []bool{true, false, true, true, false, false}
+
+// xlsxProductWriter 以流式方式生成 xlsx（本质是一个 zip 包）：
+// 工作表内容逐行写入 zip 条目，工作簿、关系与内容类型等描述文件在 Close 时根据实际工作表数量补写。
+type xlsxProductWriter struct {
+	zw *zip.Writer
+	// deflate：当前 zip 条目的压缩器；Flush 时需要同步刷新，否则数据会停留在压缩器内部。
+	deflate   *flate.Writer
+	sheet     *bufio.Writer
+	sheets    int
+	sheetRows int
+}
+
+func newXLSXProductWriter(w io.Writer) productWriter {
+	x := &xlsxProductWriter{zw: zip.NewWriter(w)}
+	x.zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		fw, err := flate.NewWriter(out, flate.DefaultCompression)
+		x.deflate = fw
+		return fw, err
+	})
+	return x
+}
+
+func (x *xlsxProductWriter) WriteProduct(p *models.Product) error {
+	if x.sheet == nil || x.sheetRows >= xlsxMaxRows {
+		if err := x.nextSheet(); err != nil {
+			return err
+		}
+	}
+	x.sheetRows++
+	return x.writeRow(productRecord(p), xlsxNumericColumns)
+}
+
+// nextSheet 结束当前工作表并开启一个新的工作表，新表第一行是表头。
+func (x *xlsxProductWriter) nextSheet() error {
+	if err := x.endSheet(); err != nil {
+		return err
+	}
+	x.sheets++
+	f, err := x.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", x.sheets))
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(f)
+	x.sheetRows = 1
+	if _, err := io.WriteString(x.sheet, xml.Header+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return err
+	}
+	return x.writeRow(exportColumns, make([]bool, len(exportColumns)))
+}
+
+func (x *xlsxProductWriter) endSheet() error {
+	if x.sheet == nil {
+		return nil
+	}
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	err := x.sheet.Flush()
+	x.sheet = nil
+	return err
+}
+
+// Flush 依次刷新工作表缓冲、压缩器与 zip 的输出缓冲；没有打开的工作表时只刷新 zip。
+func (x *xlsxProductWriter) Flush() error {
+	if x.sheet != nil {
+		if err := x.sheet.Flush(); err != nil {
+			return err
+		}
+		if err := x.deflate.Flush(); err != nil {
+			return err
+		}
+	}
+	return x.zw.Flush()
+}
+
+// writeRow 写出一行：数值列用 <v>，文本列用 inlineStr，避免维护 sharedStrings 表。
+func (x *xlsxProductWriter) writeRow(values []string, numeric []bool) error {
+	if _, err := io.WriteString(x.sheet, "<row>"); err != nil {
+		return err
+	}
+	for i, v := range values {
+		if numeric[i] {
+			if _, err := fmt.Fprintf(x.sheet, `<c><v>%s</v></c>`, v); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := io.WriteString(x.sheet, `<c t="inlineStr"><is><t xml:space="preserve">`); err != nil {
+			return err
+		}
+		if err := xml.EscapeText(x.sheet, []byte(v)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(x.sheet, `</t></is></c>`); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(x.sheet, "</row>")
+	return err
+}
+
+func (x *xlsxProductWriter) Close() error {
+	// 没有任何数据时也生成一个只有表头的工作表。
+	if x.sheets == 0 {
+		if err := x.nextSheet(); err != nil {
+			return err
+		}
+	}
+	if err := x.endSheet(); err != nil {
+		return err
+	}
+
+	var sheets, sheetRels, overrides string
+	for i := 1; i <= x.sheets; i++ {
+		sheets += fmt.Sprintf(`<sheet name="products%s" sheetId="%d" r:id="rId%d"/>`, xlsxSheetSuffix(i), i, i)
+		sheetRels += fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+		overrides += fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+
+	parts := []struct{ name, body string }{
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + sheets + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + sheetRels + `</Relationships>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` + overrides + `</Types>`},
+	}
+	for _, part := range parts {
+		f, err := x.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xml.Header+part.body); err != nil {
+			return err
+		}
+	}
+
+	return x.zw.Close()
+}
+
+// xlsxSheetSuffix：第一个工作表叫 products，之后依次为 products_2、products_3……
+func xlsxSheetSuffix(i int) string {
+	if i == 1 {
+		return ""
+	}
+	return "_" + strconv.Itoa(i)
+}
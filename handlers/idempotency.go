@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+// IdempotencyTTL 是 Idempotency-Key 的有效期：过期后同一个 key 会被当作新请求处理。
+var IdempotencyTTL = 24 * time.Hour
+
+// idempotencyCleanupInterval：顺带清理过期 key 的最小间隔，避免每个请求都扫表。
+const idempotencyCleanupInterval = time.Hour
+
+var (
+	idempotencyCleanupMu sync.Mutex
+	idempotencyCleanupAt time.Time
+)
+
+// withIdempotency 为 POST 接口提供 Idempotency-Key 支持：
+// - 首次请求：执行 handler，并把响应（状态码、Content-Type、Location、body）连同请求体哈希一起保存
+// - 相同 key + 相同请求体：直接重放保存的响应，并带上 Idempotent-Replayed: true
+// - 相同 key + 不同请求体：422
+// - 首次请求仍在处理中：409
+// 5xx 响应不会被保存，客户端可以用同一个 key 重试。未携带该请求头的请求不受影响。
+func withIdempotency(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method != "POST" {
+			next(w, r)
+			return
+		}
+		if len(key) > 255 {
+			writeError(w, r, apierror.New(http.StatusBadRequest, apierror.IdempotencyKeyInvalid))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			writeError(w, r, bodyReadError(err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// 哈希同时覆盖 query，避免 ?async=true 与同步请求共用一个 key 时被误判为同一请求。
+		sum := sha256.Sum256(append([]byte(r.URL.RawQuery+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+		path := r.URL.Path
+		tenant := tenantFromRequest(r)
+		now := time.Now().UTC()
+
+		cleanupIdempotencyRecords(r.Context(), now)
+
+		// 查找使用只读连接；并发的相同 key 都没有查到时，由唯一约束保证只有一个能插入记录，其余返回 409。
+		record, err := models.GetIdempotencyRecord(r.Context(), utils.ReadDB, tenant, key, r.Method, path, now)
+		if err == nil {
+			replayIdempotencyRecord(w, r, record, requestHash)
+			return
+		}
+		if !errors.Is(err, models.ErrIdempotencyRecordNotFound) {
+			writeError(w, r, err)
+			return
+		}
+
+		if err := models.CreatePendingIdempotencyRecord(r.Context(), utils.DB, tenant, key, r.Method, path, requestHash, now, IdempotencyTTL); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		capture := newResponseCapture()
+		next(capture, r)
+
+		// 请求被取消或超时后仍然要释放或保存记录，否则 key 会一直处于“处理中”。
+		// 5xx 与 499（客户端已断开）都没有得到确定的结果，释放 key 以便重试。
+		ctx := context.WithoutCancel(r.Context())
+		if capture.status >= 500 || capture.status == StatusClientClosedRequest {
+			if err := models.DeleteIdempotencyRecord(ctx, utils.DB, tenant, key, r.Method, path); err != nil {
+				log.Printf("release idempotency key %q failed: %v", key, err)
+			}
+		} else if err := models.CompleteIdempotencyRecord(ctx, utils.DB, tenant, key, r.Method, path, capture.status,
+			capture.header.Get("Content-Type"), capture.header.Get("Location"), capture.body.Bytes()); err != nil {
+			log.Printf("save idempotency key %q failed: %v", key, err)
+		}
+
+		capture.copyTo(w)
+	}
+}
+
+// replayIdempotencyRecord 根据已有记录返回：请求体不一致 422、仍在处理 409、否则重放保存的响应。
+func replayIdempotencyRecord(w http.ResponseWriter, r *http.Request, record *models.IdempotencyRecord, requestHash string) {
+	if record.RequestHash != requestHash {
+		writeError(w, r, apierror.New(http.StatusUnprocessableEntity, apierror.IdempotencyKeyReused))
+		return
+	}
+	if record.Pending() {
+		writeError(w, r, models.ErrIdempotencyKeyExists)
+		return
+	}
+
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	if record.Location != "" {
+		w.Header().Set("Location", record.Location)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(record.Status)
+	_, _ = w.Write(record.Body)
+}
+
+// cleanupIdempotencyRecords 每隔 idempotencyCleanupInterval 清理一次过期记录。
+func cleanupIdempotencyRecords(ctx context.Context, now time.Time) {
+	idempotencyCleanupMu.Lock()
+	if now.Sub(idempotencyCleanupAt) < idempotencyCleanupInterval {
+		idempotencyCleanupMu.Unlock()
+		return
+	}
+	idempotencyCleanupAt = now
+	idempotencyCleanupMu.Unlock()
+
+	if _, err := models.DeleteExpiredIdempotencyRecords(ctx, utils.DB, now); err != nil {
+		log.Printf("cleanup idempotency keys failed: %v", err)
+	}
+}
+
+// responseCapture 缓存 handler 写出的响应，便于保存后再转发给客户端。
+type responseCapture struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseCapture() *responseCapture {
+	return &responseCapture{header: http.Header{}}
+}
+
+func (c *responseCapture) Header() http.Header {
+	return c.header
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.body.Write(b)
+}
+
+// copyTo 把缓存的响应原样写给真正的 ResponseWriter。
+func (c *responseCapture) copyTo(w http.ResponseWriter) {
+	for k, v := range c.header {
+		w.Header()[k] = v
+	}
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	w.WriteHeader(c.status)
+	_, _ = w.Write(c.body.Bytes())
+}
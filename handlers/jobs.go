@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang-starter/apierror"
+	"golang-starter/jobs"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+const (
+	exportJobType     = "export"
+	bulkCreateJobType = "bulk_create"
+)
+
+// bulkJobChunkSize：异步批量创建时每批插入的条数；每批完成后上报一次进度并检查是否被取消。
+const bulkJobChunkSize = 500
+
+// exportJobPayload 是导出任务的参数。
+type exportJobPayload struct {
+	Format string                      `json:"format"`
+	Params models.GetAllProductsParams `json:"params"`
+}
+
+// bulkCreateJobPayload 是批量创建任务的参数。
+type bulkCreateJobPayload struct {
+	Products []models.Product `json:"products"`
+}
+
+func init() {
+	jobs.Register(exportJobType, runExportJob)
+	jobs.Register(bulkCreateJobType, runBulkCreateJob)
+}
+
+// enqueueJob 创建后台任务并返回 202 Accepted；Location 头指向任务查询地址。
+func enqueueJob(w http.ResponseWriter, r *http.Request, jobType string, payload any) {
+	job, err := jobs.Enqueue(r.Context(), utils.DB, tenantFromRequest(r), jobType, payload)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/jobs/%d", job.ID))
+	writeSuccess(w, r, http.StatusAccepted, successResponse{
+		Code:    http.StatusAccepted,
+		Message: "accepted",
+		Data:    job,
+	})
+}
+
+// HandleJob 处理单个任务资源：
+// - GET  /api/jobs/{id}：查询任务状态、进度与结果
+// - POST /api/jobs/{id}/cancel：取消任务
+// - GET  /api/jobs/{id}/result：下载任务结果文件
+func HandleJob(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	parts := strings.SplitN(path, "/", 2)
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		writeError(w, r, errInvalidID)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == "GET":
+		GetJob(w, r, id)
+	case action == "cancel" && r.Method == "POST":
+		CancelJob(w, r, id)
+	case action == "result" && r.Method == "GET":
+		DownloadJobResult(w, r, id)
+	case action == "" || action == "cancel" || action == "result":
+		writeError(w, r, errMethodNotAllowed)
+	default:
+		writeError(w, r, errNotFound)
+	}
+}
+
+// GetJob 查询任务。
+func GetJob(w http.ResponseWriter, r *http.Request, id int) {
+	job, err := models.GetJobByID(r.Context(), utils.ReadDB, tenantFromRequest(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeSuccess(w, r, http.StatusOK, successResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    job,
+	})
+}
+
+// CancelJob 取消任务：已结束的任务返回 409。
+func CancelJob(w http.ResponseWriter, r *http.Request, id int) {
+	job, err := jobs.Cancel(r.Context(), utils.DB, tenantFromRequest(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeSuccess(w, r, http.StatusAccepted, successResponse{
+		Code:    http.StatusAccepted,
+		Message: "success",
+		Data:    job,
+	})
+}
+
+// DownloadJobResult 下载任务结果文件；任务未成功结束或没有结果文件时返回 409 / 404。
+func DownloadJobResult(w http.ResponseWriter, r *http.Request, id int) {
+	job, err := models.GetJobByID(r.Context(), utils.ReadDB, tenantFromRequest(r), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if job.Status != models.JobSucceeded {
+		writeError(w, r, apierror.New(http.StatusConflict, apierror.JobNotFinished))
+		return
+	}
+	if job.ResultFile == "" {
+		writeError(w, r, apierror.New(http.StatusNotFound, apierror.JobResultNotFound))
+		return
+	}
+
+	f, err := os.Open(job.ResultFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, r, apierror.NewVariant(http.StatusNotFound, apierror.JobResultNotFound, "gone"))
+		} else {
+			writeError(w, r, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 按扩展名找回导出格式，设置对应的 Content-Type。
+	ext := strings.TrimPrefix(filepath.Ext(job.ResultFile), ".")
+	if format, ok := exportFormats[ext]; ok {
+		w.Header().Set("Content-Type", format.contentType)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products-job-%d.%s"`, job.ID, ext))
+	// ServeContent 负责 Content-Length、Range 与条件请求。
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// runExportJob 把产品导出到结果文件，每写出 exportFlushEvery 行上报一次进度。
+func runExportJob(ctx context.Context, job *models.Job, report func(done, total int) bool) (*jobs.Result, error) {
+	var payload exportJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, err
+	}
+	format, ok := exportFormats[payload.Format]
+	if !ok {
+		return nil, fmt.Errorf("invalid format: %s", payload.Format)
+	}
+
+	// total：按 offset/limit 推算本次导出的总行数，用于展示进度百分比。
+	total, err := models.CountProducts(ctx, utils.ReadDB, job.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	total -= payload.Params.Offset
+	if total < 0 {
+		total = 0
+	}
+	if payload.Params.Limit > 0 && payload.Params.Limit < total {
+		total = payload.Params.Limit
+	}
+
+	path := jobs.ResultPath(job, format.extension)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pw := format.newWriter(f)
+	count := 0
+	err = models.StreamProducts(ctx, utils.ReadDB, job.TenantID, payload.Params, func(p *models.Product) error {
+		if err := pw.WriteProduct(p); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 && !report(count, total) {
+			return jobs.ErrCanceled
+		}
+		return ctx.Err()
+	})
+	if err == nil {
+		err = pw.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	report(count, total)
+
+	return &jobs.Result{
+		Data: map[string]any{"rows": count, "format": payload.Format},
+		File: path,
+	}, nil
+}
+
+// bulkCreateCheckpoint 是批量创建任务的断点：Offset 之前的产品已经提交，Ranges 是它们的 ID 区间 [first, last]
+// （同一批插入的 ID 通常连续，保存区间而不是逐个保存 ID）。
+type bulkCreateCheckpoint struct {
+	Offset int      `json:"offset"`
+	Ranges [][2]int `json:"ranges"`
+}
+
+// add 记录新提交的一批产品，返回新的断点（不修改 cp）。
+func (cp bulkCreateCheckpoint) add(created []*models.Product, offset int) bulkCreateCheckpoint {
+	ranges := slices.Clone(cp.Ranges)
+	for _, p := range created {
+		if n := len(ranges); n > 0 && ranges[n-1][1]+1 == p.ID {
+			ranges[n-1][1] = p.ID
+		} else {
+			ranges = append(ranges, [2]int{p.ID, p.ID})
+		}
+	}
+	return bulkCreateCheckpoint{Offset: offset, Ranges: ranges}
+}
+
+func (cp bulkCreateCheckpoint) ids() []int {
+	ids := make([]int, 0, cp.Offset)
+	for _, r := range cp.Ranges {
+		for id := r[0]; id <= r[1]; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// runBulkCreateJob 分批插入产品；被取消时已写入的批次不会回滚。
+// 每批产品与断点在同一个事务中提交，任务被打断（进程退出、崩溃）后重新执行时从断点继续，不会重复插入。
+func runBulkCreateJob(ctx context.Context, job *models.Job, report func(done, total int) bool) (*jobs.Result, error) {
+	var payload bulkCreateJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, err
+	}
+	var cp bulkCreateCheckpoint
+	if len(job.Checkpoint) > 0 {
+		if err := json.Unmarshal(job.Checkpoint, &cp); err != nil {
+			return nil, err
+		}
+	}
+
+	total := len(payload.Products)
+	for start := cp.Offset; start < total; start += bulkJobChunkSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		end := start + bulkJobChunkSize
+		if end > total {
+			end = total
+		}
+
+		chunk := make([]*models.Product, 0, end-start)
+		for i := start; i < end; i++ {
+			chunk = append(chunk, &payload.Products[i])
+		}
+
+		var next bulkCreateCheckpoint
+		err := utils.WithTx(ctx, func(tx *sql.Tx) error {
+			created, err := models.ProductsBulk(ctx, tx, job.TenantID, chunk)
+			if err != nil {
+				return err
+			}
+			next = cp.add(created, end)
+			raw, err := json.Marshal(next)
+			if err != nil {
+				return err
+			}
+			return models.SaveJobCheckpoint(ctx, tx, job.ID, raw, end, total)
+		})
+		if err != nil {
+			return nil, err
+		}
+		cp = next
+		ProductCache.ProductsCreated(job.TenantID)
+
+		if !report(end, total) {
+			return nil, jobs.ErrCanceled
+		}
+	}
+
+	ids := cp.ids()
+	return &jobs.Result{
+		Data: map[string]any{"created": len(ids), "ids": ids},
+	}, nil
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang-starter/apierror"
+	"golang-starter/validation"
+)
+
+// problemContentType 是 RFC 7807 错误文档的媒体类型。
+const problemContentType = "application/problem+json"
+
+// ProblemTypeBase 是 problem 文档 type 字段的前缀：type = ProblemTypeBase + 小写、以连字符分隔的错误码，
+// 例如 "/problems/product-not-found"。部署时可以改成指向错误码文档的绝对地址。
+var ProblemTypeBase = "/problems/"
+
+// problemDetails 是 RFC 7807 错误文档；error_code 与 errors 是扩展成员，含义与默认错误响应一致。
+type problemDetails struct {
+	Type      string                  `json:"type"`
+	Title     string                  `json:"title"`
+	Status    int                     `json:"status"`
+	Detail    string                  `json:"detail,omitempty"`
+	Instance  string                  `json:"instance,omitempty"`
+	ErrorCode apierror.Code           `json:"error_code"`
+	Errors    []validation.FieldError `json:"errors,omitempty"`
+}
+
+// acceptsProblemJSON 判断客户端是否要求 RFC 7807 格式：
+// Accept 中 application/problem+json 的 q 值大于 0，且不低于 application/json 的 q 值。
+// 通配符（*/*、application/*）不算，未明确要求时保持默认错误格式。
+func acceptsProblemJSON(r *http.Request) bool {
+	return prefersMediaType(r, problemContentType)
+}
+
+// acceptQualities 解析 Accept 头，返回每个媒体类型的 q 值（未给出时为 1）。
+func acceptQualities(r *http.Request) map[string]float64 {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		qualities[mediaType] = q
+	}
+	return qualities
+}
+
+// prefersMediaType 判断 Accept 是否明确要求 mediaType：q 值大于 0，且不低于 application/json 的 q 值。
+func prefersMediaType(r *http.Request, mediaType string) bool {
+	qualities := acceptQualities(r)
+	q, ok := qualities[mediaType]
+	if !ok || q <= 0 {
+		return false
+	}
+	jsonQ, ok := qualities["application/json"]
+	return !ok || q >= jsonQ
+}
+
+// problemType 把错误码转换为 type URI，例如 PRODUCT_NOT_FOUND -> /problems/product-not-found。
+func problemType(code apierror.Code) string {
+	return ProblemTypeBase + strings.ToLower(strings.ReplaceAll(string(code), "_", "-"))
+}
+
+// writeProblem 以 application/problem+json 写出错误文档。
+func writeProblem(w http.ResponseWriter, problem problemDetails) {
+	w.Header().Set("Content-Type", problemContentType+"; charset=utf-8")
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
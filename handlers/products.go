@@ -2,48 +2,103 @@ package handlers
 
 // import 块：handler 层依赖的标准库与项目内包。
 import (
+	"bytes"
+	"database/sql"
 	// encoding/json：用于 JSON 编解码（请求体解析、响应体输出）。
 	"encoding/json"
-	"errors"
-	"fmt"
+	"io"
+	"mime"
 
 	// net/http：HTTP handler 所需的核心类型与工具函数（ResponseWriter、Request、StatusCode、http.Error）。
 	"net/http"
+	"net/url"
 	// strconv：字符串与数值转换；这里用 Atoi 把 path 中的 id 解析成 int。
 	"strconv"
 	// strings：字符串处理；这里用于从 URL path 中裁剪前缀与拆分片段。
 	"strings"
 
+	// apierror：统一的 API 错误类型与错误码。
+	"golang-starter/apierror"
 	// models：数据模型与数据库 CRUD 操作（对 SQLite 的增删改查）。
 	"golang-starter/models"
+	// patch：JSON Merge Patch / JSON Patch 的通用实现。
+	"golang-starter/patch"
 	// utils：提供全局数据库连接 utils.DB（在 main 启动时 InitDB 初始化）。
 	"golang-starter/utils"
+	// validation：基于 struct tag 的声明式校验。
+	"golang-starter/validation"
+	// version：构建信息（由 ldflags 注入）。
+	"golang-starter/version"
 )
 
 // RegisterRoutes 注册所有 API 路由。
 // 约定：同一个 path 用不同 HTTP Method 表示不同动作（GET 列表 / POST 创建 / GET 单个 / PUT 更新 / DELETE 删除）。
+// 权限：除健康检查、构建信息与文档外都需要认证；读操作需要 viewer，写操作需要 editor，密钥与租户管理需要不绑定租户的 admin。
+// 租户：产品与任务接口都按 withTenant 解析出的租户隔离。
+// 限流：除健康检查、构建信息与文档外都经过 withIPRateLimit（认证之前按 IP）与 withRateLimit（认证之后按调用方），
+// 后者对批量创建与导出按更高的开销计算。
+// 时限：除健康检查、构建信息与文档外都经过 withTimeout，请求的 context 传到 models 层，超时或客户端断开时中断数据库查询。
+// 校验：业务路由都经过 withValidation，按 OpenAPI 文档校验参数与请求体后才进入 handler。
+// 所有路由都带安全响应头、处理 CORS 并按 Accept-Encoding 压缩响应；有请求体的路由按 withBodyLimit 限制大小，批量创建的上限更高。
+// 文档：/api/openapi.json 是 OpenAPI 文档，/docs/ 是 Swagger UI，都不需要认证。
 func RegisterRoutes(mux *http.ServeMux) {
-	// /api/health：健康检查接口（一般用于探活、负载均衡检查等）。
-	mux.HandleFunc("/api/health", HealthCheck)
+	registerRoutes(func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, withSecurityHeaders(withCORS(withCompression(handler))))
+	})
+
+	// /docs：Swagger UI 页面，不属于 API，不在 OpenAPI 文档中。
+	mux.HandleFunc("/docs", withSecurityHeaders(SwaggerUI))
+	mux.HandleFunc("/docs/", withSecurityHeaders(withCompression(SwaggerUI)))
+}
+
+// Routes 返回 RegisterRoutes 注册的所有 API 路由模式（ServeMux 的 pattern），用于核对 OpenAPI 文档。
+func Routes() []string {
+	var patterns []string
+	registerRoutes(func(pattern string, _ http.HandlerFunc) {
+		patterns = append(patterns, pattern)
+	})
+	return patterns
+}
+
+func registerRoutes(handle func(pattern string, handler http.HandlerFunc)) {
+	// /api/health：健康检查接口（一般用于探活、负载均衡检查等），不需要认证。
+	handle("/api/health", HealthCheck)
+	// /api/version：构建信息，不需要认证。
+	handle("/api/version", Version)
+	// /api/openapi.json：OpenAPI 文档，不需要认证。
+	handle("/api/openapi.json", OpenAPISpec)
 	// /api/products：集合资源路径；用 method 区分 GET（列表）与 POST（创建）。
-	mux.HandleFunc("/api/products", func(w http.ResponseWriter, r *http.Request) {
+	handle("/api/products", withTimeout("default", withIPRateLimit(withAuth(readWrite, withRateLimit("default", withBodyLimit("default", withTenant(func(w http.ResponseWriter, r *http.Request) {
 		// r.Method：HTTP 方法字符串，例如 "GET"、"POST"、"PUT"、"DELETE"。
 		switch r.Method {
 		case "GET":
 			// GET /api/products：返回所有产品。
-			GetAllProducts(w, r)
+			withValidation(GetAllProducts)(w, r)
 		case "POST":
-			// POST /api/products：创建一个产品。
-			CreateProduct(w, r)
+			// POST /api/products：创建一个产品；携带 Idempotency-Key 时重试不会重复创建。
+			// 校验放在幂等之内，校验失败的响应同样会被重放。
+			withNoStore(withIdempotency(withValidation(CreateProduct)))(w, r)
 		default:
 			// 其他方法不支持：返回 405 Method Not Allowed。
-			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			writeError(w, r, errMethodNotAllowed)
 		}
-	})
+	})))))))
 	// /api/products/：注意以 "/" 结尾时，ServeMux 会做前缀匹配；例如 /api/products/123 会进入 HandleProduct。
-	mux.HandleFunc("/api/products/search", SearchProducts)
-	mux.HandleFunc("/api/products/bulk", ProductBulk)
-	mux.HandleFunc("/api/products/", HandleProduct)
+	handle("/api/products/search", withTimeout("default", withIPRateLimit(withAuth(readWrite, withRateLimit("default", withTenant(withValidation(SearchProducts)))))))
+	handle("/api/products/bulk", withTimeout("bulk", withIPRateLimit(withAuth(readWrite, withRateLimit("bulk", withBodyLimit("bulk", withTenant(withNoStore(withIdempotency(withValidation(ProductBulk))))))))))
+	handle("/api/products/export", withTimeout("export", withIPRateLimit(withAuth(readWrite, withRateLimit("export", withTenant(withValidation(ExportProducts)))))))
+	handle("/api/products/", withTimeout("default", withIPRateLimit(withAuth(readWrite, withRateLimit("default", withBodyLimit("default", withTenant(withValidation(HandleProduct))))))))
+	// /api/jobs/{id}：异步任务的查询、取消与结果下载。
+	handle("/api/jobs/", withTimeout("default", withIPRateLimit(withAuth(readWrite, withRateLimit("default", withTenant(withValidation(HandleJob)))))))
+	// /api/admin/keys：API 密钥管理。
+	handle("/api/admin/keys", withTimeout("default", withIPRateLimit(withAuth(adminOnly, withRateLimit("default", withBodyLimit("default", platformOnly(withValidation(HandleAPIKeys))))))))
+	handle("/api/admin/keys/", withTimeout("default", withIPRateLimit(withAuth(adminOnly, withRateLimit("default", platformOnly(withValidation(HandleAPIKey)))))))
+	// /api/admin/backups：在线备份（需要设置 BACKUP_DIR）。
+	handle("/api/admin/backups", withTimeout("export", withIPRateLimit(withAuth(adminOnly, withRateLimit("export", platformOnly(withValidation(HandleBackups)))))))
+	// /api/admin/cache：产品缓存的命中统计与清空。
+	handle("/api/admin/cache", withTimeout("default", withIPRateLimit(withAuth(adminOnly, withRateLimit("default", platformOnly(withValidation(HandleCache)))))))
+	// /api/admin/tenants/{id}：租户配额管理。
+	handle("/api/admin/tenants/", withTimeout("default", withIPRateLimit(withAuth(adminOnly, withRateLimit("default", withBodyLimit("default", platformOnly(withValidation(HandleTenant))))))))
 }
 
 // HandleProduct 处理单个产品资源的请求（GET / PUT / DELETE）。
@@ -61,15 +116,20 @@ func HandleProduct(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		// 400 Bad Request：客户端传参不合法（id 不是数字）。
-		writeError(w, http.StatusBadRequest, "invalid id")
+		writeError(w, r, errInvalidID)
 		return
 	}
 
 	if id <= 0 {
-		writeError(w, http.StatusBadRequest, "invalid id")
+		writeError(w, r, errInvalidID)
 		return
 	}
 
+	// 写操作的响应不能被缓存；GET 的缓存头由 cacheControlProductRead 写出。
+	if r.Method != "GET" {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
 	// 按 method 分发到单个资源的 CRUD 操作。
 	switch r.Method {
 	case "GET":
@@ -85,7 +145,7 @@ func HandleProduct(w http.ResponseWriter, r *http.Request) {
 		UpdateLocalProduct(w, r, id)
 	default:
 		// 不支持的方法返回 405。
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		writeError(w, r, errMethodNotAllowed)
 	}
 }
 
@@ -95,107 +155,83 @@ type healthResponse struct {
 
 // HealthCheck 健康检查接口：返回固定 JSON，表明服务可用。
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
-	writeSuccess(w, http.StatusOK, successResponse{
+	writeSuccess(w, r, http.StatusOK, successResponse{
 		Code:    http.StatusOK,
 		Message: "success",
 		Data:    healthResponse{Status: "ok"},
 	})
 }
 
+// Version 返回构建信息：GET /api/version
+func Version(w http.ResponseWriter, r *http.Request) {
+	writeSuccess(w, r, http.StatusOK, successResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    version.Get(),
+	})
+}
+
 // GetAllProducts 获取所有产品列表：调用 model 层查询 DB，并以 JSON 形式返回。
+// limit / offset / order 已经由 withValidation 按 OpenAPI 文档校验过（limit 为 0~100 的整数，order 为 id_asc / id_desc）。
 func GetAllProducts(w http.ResponseWriter, r *http.Request) {
+	params := listParams(r.URL.Query(), 20)
 
-	query := r.URL.Query()
-
-	params := models.GetAllProductsParams{}
-	limitStr := query.Get("limit")
-	offsetStr := query.Get("offset")
-	order := query.Get("order")
-
-	if limitStr == "" {
-		params.Limit = 20
-	}
-
-	if limitStr != "" {
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "invalid limit")
-			return
-		}
-		if limit > 0 && limit <= 100 {
-			params.Limit = limit
-		}
-		if limit < 0 {
-			writeError(w, http.StatusBadRequest, "invalid limit")
-			return
-		}
-	}
-
-	if offsetStr == "" {
-		params.Offset = 0
-	}
-
-	if offsetStr != "" {
-		offset, err := strconv.Atoi(offsetStr)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "invalid offset")
-			return
-		}
-
-		if offset >= 0 {
-			params.Offset = offset
-		}
-	}
-
-	if order == "id_asc" || order == "id_desc" {
-		params.Order = order
-	}
-
-	if query.Has("order") && order == "" {
-		writeError(w, http.StatusBadRequest, "invalid order")
-		return
-	}
-
-	if query.Has("order") && order != "id_asc" && order != "id_desc" {
-		writeError(w, http.StatusBadRequest, "invalid order")
-		return
-	}
-
-	if query.Has("order") == false {
-		params.Order = "id_asc"
-	}
-
-	// utils.DB：全局数据库连接（*sql.DB，实际上是连接池句柄），并发安全。
-	products, err := models.GetAllProducts(utils.DB, params)
+	tenantID := tenantFromRequest(r)
+	// 先查 ProductCache；未命中时查询数据库并写回缓存。
+	// utils.ReadDB：全局只读连接池（*sql.DB，实际上是连接池句柄），并发安全；写操作使用 utils.DB。
+	products, hit, err := ProductCache.List(tenantID, params, func() ([]*models.Product, error) {
+		return models.GetAllProducts(r.Context(), utils.ReadDB, tenantID, params)
+	})
 	if err != nil {
 		// 500：服务端错误（例如 DB 查询失败、SQL 语法错误、连接异常等）。
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, r, err)
 		return
 	}
-	writeSuccess(w, http.StatusOK, successResponse{
+	cacheControlProductRead(w, hit)
+	writeSuccess(w, r, http.StatusOK, successResponse{
 		Code:    http.StatusOK,
 		Message: "success",
 		Data:    products,
 	})
 }
 
+// listParams 读取列表类接口共用的 limit / offset / order 参数；参数已经过校验，未传时分别取 defaultLimit、0 与 id_asc。
+// 负数 offset 按 0 处理。
+func listParams(query url.Values, defaultLimit int) models.GetAllProductsParams {
+	params := models.GetAllProductsParams{
+		Limit:  queryInt(query, "limit", defaultLimit),
+		Offset: max(queryInt(query, "offset", 0), 0),
+		Order:  query.Get("order"),
+	}
+	if params.Order == "" {
+		params.Order = "id_asc"
+	}
+	return params
+}
+
+// queryInt 读取整数 query 参数；未传（或无法解析）时返回 def。
+func queryInt(query url.Values, name string, def int) int {
+	if n, err := strconv.Atoi(query.Get(name)); err == nil {
+		return n
+	}
+	return def
+}
+
 // GetProduct 根据 ID 获取产品：查到则 200 + data；不存在则 404。
 func GetProduct(w http.ResponseWriter, r *http.Request, id int) {
-	// 调用 model 层按 id 查询。
-	product, err := models.GetProductByID(utils.DB, id)
+	tenantID := tenantFromRequest(r)
+	// 先查 ProductCache；未命中时调用 model 层按 id 查询。
+	product, hit, err := ProductCache.Product(tenantID, id, func() (*models.Product, error) {
+		return models.GetProductByID(r.Context(), utils.ReadDB, tenantID, id)
+	})
 	if err != nil {
 		// 通过错误消息区分“未找到”和“内部错误”（学习项目的简化写法）。
-		if errors.Is(err, models.ErrProductNotFound) {
-			// 404 Not Found：资源不存在。
-			writeError(w, http.StatusNotFound, "product not found")
-		} else {
-			// 500 Internal Server Error：数据库或其他内部错误。
-			writeError(w, http.StatusInternalServerError, err.Error())
-		}
+		writeError(w, r, err)
 		return
 	}
+	cacheControlProductRead(w, hit)
 
-	writeSuccess(w, http.StatusOK, successResponse{
+	writeSuccess(w, r, http.StatusOK, successResponse{
 		Code:    http.StatusOK,
 		Message: "success",
 		Data:    product,
@@ -205,49 +241,37 @@ func GetProduct(w http.ResponseWriter, r *http.Request, id int) {
 
 // CreateProduct 创建产品：
 // 1) 从 body 解析 JSON 到 Product
-// 2) 校验字段
+// 2) 校验字段（失败返回 422 与字段级错误列表）
 // 3) 调用 model 层写入 DB
 // 4) 返回 201 + 创建后的对象
 func CreateProduct(w http.ResponseWriter, r *http.Request) {
 	// product：用于接收请求体 JSON 解码后的结果。
 	var product models.Product
 	// NewDecoder(r.Body)：从请求体流读取 JSON；Decode(&product) 需要传指针才能写入字段。
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
-		// 400：请求体不是合法 JSON，或字段类型不匹配导致解码失败。
-		writeError(w, http.StatusBadRequest, err.Error())
+	if err := decodeJSON(r.Body, &product); err != nil {
+		// 400：请求体不是合法 JSON，或字段类型不匹配导致解码失败；超出大小上限时为 413。
+		writeError(w, r, invalidJSON(err))
 		return
 	}
 	// 关闭请求体（释放资源）；defer 确保函数返回时执行。
 	defer r.Body.Close()
 
-	// 验证必填字段
-	if product.Name == "" {
-		// name 为空：业务校验失败，返回 400。
-		writeError(w, http.StatusBadRequest, "name is required")
-		return
-	}
-
-	if product.Price <= 0 {
-		// price 必须为正数：避免无效数据进入 DB。
-		writeError(w, http.StatusBadRequest, "price must be greater than 0")
-		return
-	}
-
-	if product.Stock < 0 {
-		// stock 不能为负：库存不能小于 0。
-		writeError(w, http.StatusBadRequest, "stock cannot be negative")
+	// 按 models.Product 上声明的规则校验，一次性返回所有字段错误（422）。
+	if errs := validation.Struct(&product); len(errs) > 0 {
+		writeError(w, r, errs)
 		return
 	}
 
 	// 调用 model 层创建产品；成功后会填充 ID/时间字段。
-	createdProduct, err := models.CreateProduct(utils.DB, &product)
+	createdProduct, err := models.CreateProduct(r.Context(), utils.DB, tenantFromRequest(r), &product)
 	if err != nil {
 		// 500：插入失败（例如数据库写入错误）。
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, r, err)
 		return
 	}
+	ProductCache.ProductsCreated(tenantFromRequest(r))
 
-	writeSuccess(w, http.StatusCreated, successResponse{
+	writeSuccess(w, r, http.StatusCreated, successResponse{
 		Code:    http.StatusCreated,
 		Message: "success",
 		Data:    createdProduct,
@@ -261,9 +285,9 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 func UpdateProduct(w http.ResponseWriter, r *http.Request, id int) {
 	// product：用于接收请求体中的更新字段。
 	var product models.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+	if err := decodeJSON(r.Body, &product); err != nil {
 		// 400：JSON 解码失败。
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, r, invalidJSON(err))
 		return
 	}
 	// 关闭请求体。
@@ -272,39 +296,21 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request, id int) {
 	// 强制使用 path 中的 id，覆盖 body 中可能存在的 id。
 	product.ID = id
 
-	// 验证必填字段
-	if product.Name == "" {
-		// name 为空：返回 400。
-		writeError(w, http.StatusBadRequest, "name is required")
-		return
-	}
-
-	if product.Price <= 0 {
-		// price 非正：返回 400。
-		writeError(w, http.StatusBadRequest, "price must be greater than 0")
-		return
-	}
-
-	if product.Stock < 0 {
-		// stock 为负：返回 400。
-		writeError(w, http.StatusBadRequest, "stock cannot be negative")
+	// 按 models.Product 上声明的规则校验，一次性返回所有字段错误（422）。
+	if errs := validation.Struct(&product); len(errs) > 0 {
+		writeError(w, r, errs)
 		return
 	}
 
 	// 调用 model 层执行 UPDATE；rowsAffected==0 时会返回 "product not found"。
-	updatedProduct, err := models.UpdateProduct(utils.DB, &product)
+	updatedProduct, err := models.UpdateProduct(r.Context(), utils.DB, tenantFromRequest(r), &product)
 	if err != nil {
-		if errors.Is(err, models.ErrProductNotFound) {
-			// 404：要更新的资源不存在。
-			writeError(w, http.StatusNotFound, "product not found")
-		} else {
-			// 500：其他内部错误。
-			writeError(w, http.StatusInternalServerError, err.Error())
-		}
+		writeError(w, r, err)
 		return
 	}
+	ProductCache.ProductChanged(tenantFromRequest(r), id)
 
-	writeSuccess(w, http.StatusOK, successResponse{
+	writeSuccess(w, r, http.StatusOK, successResponse{
 		Code:    http.StatusOK,
 		Message: "success",
 		Data:    updatedProduct,
@@ -315,17 +321,13 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request, id int) {
 // DeleteProduct 删除产品：如果不存在返回 404；成功返回 200。
 func DeleteProduct(w http.ResponseWriter, r *http.Request, id int) {
 	// 调用 model 层删除；内部通过 rowsAffected 判断是否真的删除到数据。
-	err := models.DeleteProduct(utils.DB, id)
+	err := models.DeleteProduct(r.Context(), utils.DB, tenantFromRequest(r), id)
 	if err != nil {
-		if errors.Is(err, models.ErrProductNotFound) {
-			writeError(w, http.StatusNotFound, "product not found")
-		} else {
-			// 500：删除过程的内部错误。
-			writeError(w, http.StatusInternalServerError, err.Error())
-		}
+		writeError(w, r, err)
 		return
 	}
-	writeSuccess(w, http.StatusOK, successResponse{
+	ProductCache.ProductDeleted(tenantFromRequest(r), id)
+	writeSuccess(w, r, http.StatusOK, successResponse{
 		Code:    http.StatusOK,
 		Message: "success",
 		Data:    nil,
@@ -335,22 +337,17 @@ func DeleteProduct(w http.ResponseWriter, r *http.Request, id int) {
 func SearchProducts(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != "GET" {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
-
-	name := r.URL.Query().Get("name")
-	if name == "" {
-		writeError(w, http.StatusBadRequest, "name is required")
+		writeError(w, r, errMethodNotAllowed)
 		return
 	}
 
-	products, err := models.SearchProduct(utils.DB, name)
+	// name 是必填参数，由 withValidation 校验。
+	products, err := models.SearchProduct(r.Context(), utils.ReadDB, tenantFromRequest(r), r.URL.Query().Get("name"))
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, r, err)
 		return
 	}
-	writeSuccess(w, http.StatusOK, successResponse{
+	writeSuccess(w, r, http.StatusOK, successResponse{
 		Code:    http.StatusOK,
 		Message: "success",
 		Data:    products,
@@ -359,36 +356,33 @@ func SearchProducts(w http.ResponseWriter, r *http.Request) {
 
 func ProductBulk(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		writeError(w, r, errMethodNotAllowed)
 		return
 	}
 	defer r.Body.Close()
 
 	var products []models.Product
 
-	if err := json.NewDecoder(r.Body).Decode(&products); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	if err := decodeJSON(r.Body, &products); err != nil {
+		writeError(w, r, invalidJSON(err))
 		return
 	}
 
 	if len(products) == 0 {
-		writeError(w, http.StatusBadRequest, "products is empty")
+		writeError(w, r, apierror.NewVariant(http.StatusBadRequest, apierror.BadRequest, "products_empty"))
 		return
 	}
 
-	for i, product := range products {
-		if product.Name == "" {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("products[%d].name is required", i))
-			return
-		}
-		if product.Price <= 0 {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("products[%d].price must be greater than 0", i))
-			return
-		}
-		if product.Stock < 0 {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("products[%d].stock cannot be negative", i))
-			return
-		}
+	// 任何一条不合法都不会写入；错误字段形如 products[1].price。
+	if errs := validation.Slice("products", products); len(errs) > 0 {
+		writeError(w, r, errs)
+		return
+	}
+
+	// async=true：校验通过后交给后台任务分批写入，立即返回 202 与任务 id。
+	if r.URL.Query().Get("async") == "true" {
+		enqueueJob(w, r, bulkCreateJobType, bulkCreateJobPayload{Products: products})
+		return
 	}
 
 	productPtrs := make([]*models.Product, 0, len(products))
@@ -396,61 +390,168 @@ func ProductBulk(w http.ResponseWriter, r *http.Request) {
 		productPtrs = append(productPtrs, &products[i])
 	}
 
-	created, err := models.ProductsBulk(utils.DB, productPtrs)
+	created, err := models.ProductsBulk(r.Context(), utils.DB, tenantFromRequest(r), productPtrs)
 
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, r, err)
 		return
 	}
+	ProductCache.ProductsCreated(tenantFromRequest(r))
 
-	writeSuccess(w, http.StatusCreated, successResponse{
+	writeSuccess(w, r, http.StatusCreated, successResponse{
 		Code:    http.StatusCreated,
 		Message: "success",
 		Data:    created,
 	})
 }
 
+// patchReadOnlyFields 是 PATCH 不允许修改的字段。
+var patchReadOnlyFields = []string{"id", "created_at", "updated_at"}
+
+// patchableFields 是 PATCH 可以修改的字段（与 models.UpdateLocalProduct 支持的字段一致）。
+var patchableFields = []string{"name", "price", "stock"}
+
+// UpdateLocalProduct 局部更新产品，按 Content-Type 选择补丁语义：
+// - application/merge-patch+json（以及 application/json）：RFC 7396，出现的字段即更新，哪怕值是 0；null 表示删除
+// - application/json-patch+json：RFC 6902，支持 add/remove/replace/move/copy/test
+// 补丁先作用在当前产品的 JSON 表示上，再对结果做与 PUT 相同的校验，最后只更新真正变化的字段。
 func UpdateLocalProduct(w http.ResponseWriter, r *http.Request, id int) {
-	var product models.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
-		return
-	}
 	defer r.Body.Close()
 
-	args := []string{}
-
-	if product.Name != "" {
-		args = append(args, "name")
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		parsed, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			writeError(w, r, errUnsupportedMediaType)
+			return
+		}
+		mediaType = parsed
 	}
-
-	if product.Price > 0 {
-		args = append(args, "price")
+	if mediaType != "application/json" && mediaType != "application/merge-patch+json" && mediaType != "application/json-patch+json" {
+		writeError(w, r, errUnsupportedMediaType)
+		return
 	}
 
-	if product.Stock > 0 {
-		args = append(args, "stock")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, bodyReadError(err))
+		return
 	}
 
-	updatedProduct, err := models.UpdateLocalProduct(utils.DB, id, args, product)
-
-	if err != nil {
-		if errors.Is(err, models.ErrProductNotFound) {
-			writeError(w, http.StatusNotFound, "product not found")
-			return
+	// 读取当前产品、应用补丁与写入在同一个写事务中：并发的 PATCH 依次作用，不会基于过期的数据覆盖彼此的修改。
+	ctx := r.Context()
+	tenantID := tenantFromRequest(r)
+	var updatedProduct *models.Product
+	err = utils.WithTx(ctx, func(tx *sql.Tx) error {
+		current, err := models.GetProductByID(ctx, tx, tenantID, id)
+		if err != nil {
+			return err
 		}
-		if errors.Is(err, models.ErrNoFields) {
-			writeError(w, http.StatusBadRequest, "no fields to update")
-			return
+		product, fields, err := applyProductPatch(current, mediaType, body)
+		if err != nil {
+			return err
 		}
-		writeError(w, http.StatusBadRequest, err.Error())
+		// 补丁没有带来任何变化（例如只有 test 操作）：直接返回当前产品。
+		if len(fields) == 0 {
+			updatedProduct = current
+			return nil
+		}
+		updatedProduct, err = models.UpdateLocalProduct(ctx, tx, tenantID, id, fields, product)
+		return err
+	})
+
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
+	// 事务提交之后再失效：之后的读取一定能看到新数据。
+	ProductCache.ProductChanged(tenantID, id)
 
-	writeSuccess(w, http.StatusOK, successResponse{
+	writeSuccess(w, r, http.StatusOK, successResponse{
 		Code:    http.StatusOK,
 		Message: "success",
 		Data:    updatedProduct,
 	})
 
 }
+
+// applyProductPatch 把补丁作用在 current 上并校验结果，返回补丁后的产品与值真正发生变化的字段。
+func applyProductPatch(current *models.Product, mediaType string, body []byte) (models.Product, []string, error) {
+	var product models.Product
+
+	// original：当前产品的通用 JSON 表示，补丁作用在它之上。
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return product, nil, err
+	}
+	original, err := patch.Decode(currentJSON)
+	if err != nil {
+		return product, nil, err
+	}
+
+	var patched any
+	if mediaType == "application/json-patch+json" {
+		ops, err := patch.DecodeOperations(body)
+		if err != nil {
+			return product, nil, err
+		}
+		if len(ops) == 0 {
+			return product, nil, models.ErrNoFields
+		}
+		patched, err = patch.ApplyOperations(original, ops)
+		if err != nil {
+			return product, nil, err
+		}
+	} else {
+		doc, err := patch.Decode(body)
+		if err != nil {
+			return product, nil, invalidJSON(err)
+		}
+		obj, ok := doc.(map[string]any)
+		if !ok {
+			return product, nil, apierror.NewVariant(http.StatusBadRequest, apierror.InvalidPatch, "not_object")
+		}
+		if len(obj) == 0 {
+			return product, nil, models.ErrNoFields
+		}
+		patched = patch.MergePatch(original, doc)
+	}
+
+	patchedObj, ok := patched.(map[string]any)
+	if !ok {
+		return product, nil, apierror.NewVariant(http.StatusUnprocessableEntity, apierror.PatchCannotApply, "result")
+	}
+	originalObj := original.(map[string]any)
+
+	// 只读字段不允许被修改（值不变则视为无操作）。
+	for _, field := range patchReadOnlyFields {
+		if !patch.Equal(originalObj[field], patchedObj[field]) {
+			return product, nil, apierror.New(http.StatusBadRequest, apierror.ReadOnlyField, "field", field)
+		}
+	}
+
+	// 把补丁结果解码回 Product：未知字段、类型不匹配（例如 stock 为小数）都会在这里被拒绝。
+	patchedJSON, err := json.Marshal(patchedObj)
+	if err != nil {
+		return product, nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(patchedJSON))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&product); err != nil {
+		return product, nil, apierror.Wrap(http.StatusBadRequest, apierror.InvalidPatch, err)
+	}
+
+	// 对补丁结果做与创建/更新一致的校验；字段被删除（null / remove）时按零值处理。
+	if errs := validation.Struct(&product); len(errs) > 0 {
+		return product, nil, errs
+	}
+
+	// fields：只更新值真正发生变化的字段。
+	fields := []string{}
+	for _, field := range patchableFields {
+		if !patch.Equal(originalObj[field], patchedObj[field]) {
+			fields = append(fields, field)
+		}
+	}
+	return product, fields, nil
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/ratelimit"
+)
+
+// RateLimiter 为 nil 时不限流。
+var RateLimiter *ratelimit.Limiter
+
+// IPRateLimiter 在认证之前按客户端 IP 限流，每个请求消耗 1 个令牌；为 nil 时不限流。
+// 它限制的是认证本身（错误或缺失的凭证同样计数），额度应比 RateLimiter 宽松：同一个 IP 后面可能有多个调用方。
+var IPRateLimiter *ratelimit.Limiter
+
+// RateLimitCosts 是各类请求消耗的令牌数；没有列出的类别按 "default" 计算。
+// 批量创建与导出会长时间占用数据库，开销更高。
+var RateLimitCosts = map[string]float64{
+	"default": 1,
+	"bulk":    10,
+	"export":  5,
+}
+
+// TrustProxyHeaders 为 true 时用 X-Forwarded-For 中的第一个地址识别客户端；
+// 只应在可信的反向代理之后开启，否则客户端可以伪造该请求头绕过限流。
+var TrustProxyHeaders = false
+
+// ParseRateLimitCosts 解析形如 "bulk=10,export=5" 的开销配置，覆盖 RateLimitCosts 中的同名类别。
+func ParseRateLimitCosts(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		class, value, ok := strings.Cut(pair, "=")
+		cost, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || cost < 0 || strings.TrimSpace(class) == "" {
+			return fmt.Errorf("invalid rate limit cost %q", pair)
+		}
+		RateLimitCosts[strings.TrimSpace(class)] = cost
+	}
+	return nil
+}
+
+// withRateLimit 按调用方限流：已认证的请求按凭证（API 密钥 / JWT subject）计数，否则按客户端 IP 计数。
+// 所有经过限流的响应都带 X-RateLimit-* 头；额度不足时返回 429 并带上 Retry-After。
+// 需要放在 withAuth 之内，才能拿到认证后的调用方。
+func withRateLimit(class string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limiter := RateLimiter
+		if limiter == nil {
+			next(w, r)
+			return
+		}
+
+		cost, ok := RateLimitCosts[class]
+		if !ok {
+			cost = RateLimitCosts["default"]
+		}
+		d := limiter.Allow(rateLimitKey(r), cost)
+		writeRateLimitHeaders(w, d)
+		if !d.Allowed {
+			writeRateLimited(w, r, d)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// withIPRateLimit 在认证之前按客户端 IP（遵循 TrustProxyHeaders）限流，防止用错误的凭证反复尝试；
+// 需要放在 withAuth 之外。放行时不写响应头，由 withRateLimit 写出调用方自己的额度；被拒绝时与 withRateLimit 相同返回 429。
+func withIPRateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limiter := IPRateLimiter
+		if limiter == nil {
+			next(w, r)
+			return
+		}
+
+		if d := limiter.Allow("ip:"+clientIP(r), 1); !d.Allowed {
+			writeRateLimitHeaders(w, d)
+			writeRateLimited(w, r, d)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func writeRateLimitHeaders(w http.ResponseWriter, d ratelimit.Decision) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+}
+
+// writeRateLimited 返回 429，Retry-After 至少为 1 秒。
+func writeRateLimited(w http.ResponseWriter, r *http.Request, d ratelimit.Decision) {
+	retryAfter := ceilSeconds(d.RetryAfter)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeError(w, r, apierror.New(http.StatusTooManyRequests, apierror.RateLimited, "retry_after", strconv.Itoa(retryAfter)))
+}
+
+// rateLimitKey 返回限流使用的客户端标识。
+func rateLimitKey(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.Subject
+	}
+	return "ip:" + clientIP(r)
+}
+
+// clientIP 返回请求方的 IP；开启 TrustProxyHeaders 时以 X-Forwarded-For 为准。
+func clientIP(r *http.Request) string {
+	if TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
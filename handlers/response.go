@@ -3,35 +3,58 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+
+	"golang-starter/apierror"
+	"golang-starter/i18n"
+	"golang-starter/validation"
 )
 
 // errorResponse 是统一错误响应结构。
-// - code：HTTP 状态码（同时也是业务层错误码的最小实现）
+// - code：HTTP 状态码
+// - error_code：稳定的机器可读错误码（见 apierror 包），客户端应据此判断错误类型
 // - message：可读的错误信息
+// - errors：字段级校验错误列表（仅校验失败时出现）
 type errorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code      int                     `json:"code"`
+	ErrorCode apierror.Code           `json:"error_code"`
+	Message   string                  `json:"message"`
+	Errors    []validation.FieldError `json:"errors,omitempty"`
 }
 
-func writeJSON(w http.ResponseWriter, status int, payload any) {
+// writeJSON 写出 JSON 响应；客户端通过 Accept 要求 MessagePack 或 CBOR 时改用对应格式，结构不变。
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, payload any) {
+	addVary(w, "Accept")
+	if format := negotiateFormat(r); format != nil {
+		if body, err := encodeBinary(format, payload); err == nil {
+			w.Header().Set("Content-Type", format.contentType)
+			w.WriteHeader(status)
+			_, _ = w.Write(body)
+			return
+		}
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
-func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, errorResponse{
-		Code:    status,
-		Message: message,
-	})
-}
-
 type successResponse struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
 }
 
-func writeSuccess(w http.ResponseWriter, status int, data successResponse) {
-	writeJSON(w, status, data)
+// writeSuccess 写出成功响应；Message 若是消息目录中的 key（例如 "success"），按请求语言渲染。
+func writeSuccess(w http.ResponseWriter, r *http.Request, status int, data successResponse) {
+	if message, ok := i18n.Message(negotiateLang(w, r), data.Message, nil); ok {
+		data.Message = message
+	}
+	writeJSON(w, r, status, data)
+}
+
+// negotiateLang 协商响应语言，并写出 Content-Language 与 Vary 头。
+func negotiateLang(w http.ResponseWriter, r *http.Request) i18n.Lang {
+	lang := i18n.Negotiate(r)
+	w.Header().Set("Content-Language", string(lang))
+	w.Header().Add("Vary", "Accept-Language")
+	return lang
 }
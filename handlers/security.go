@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-starter/apierror"
+)
+
+// CORSAllowedOrigins 是允许跨域访问的来源，例如 "https://admin.example.com"；"*" 表示任意来源。
+// 为空时不输出任何 CORS 头，浏览器会拒绝跨域请求。
+var CORSAllowedOrigins []string
+
+// CORSAllowCredentials 为 true 时允许跨域请求携带 Cookie 等凭证；此时不会输出通配的 "*"，而是回显请求的 Origin。
+var CORSAllowCredentials = false
+
+// CORSMaxAge 是浏览器缓存预检结果的时长。
+var CORSMaxAge = 10 * time.Minute
+
+const corsAllowedMethods = "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS"
+
+// corsExposedHeaders 是允许跨域脚本读取的响应头。
+const corsExposedHeaders = "Location, Retry-After, Content-Language, Content-Disposition, Idempotent-Replayed, " +
+	"X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset"
+
+// BodyLimits 是各类请求体的大小上限（字节）；没有列出的类别按 "default" 计算。
+var BodyLimits = map[string]int64{
+	"default": 1 << 20,
+	"bulk":    10 << 20,
+}
+
+// RejectUnknownFields 为 true 时，请求体中出现未定义的 JSON 字段返回 400，而不是静默忽略。
+var RejectUnknownFields = false
+
+// withSecurityHeaders 为所有响应加上通用的安全响应头。
+// 通过 HTTPS 访问时（直接 TLS，或可信代理的 X-Forwarded-Proto）还会输出 HSTS。
+func withSecurityHeaders(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+		if r.TLS != nil || (TrustProxyHeaders && r.Header.Get("X-Forwarded-Proto") == "https") {
+			h.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		}
+		next(w, r)
+	}
+}
+
+// withCORS 处理跨域请求：
+// - 来源在 CORSAllowedOrigins 中时输出 Access-Control-Allow-* 头
+// - 预检请求（OPTIONS + Access-Control-Request-Method）在这里直接返回 204，不需要认证
+func withCORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+
+		h := w.Header()
+		if origin != "" {
+			h.Add("Vary", "Origin")
+		}
+		allowed := origin != "" && corsOriginAllowed(origin)
+		if allowed {
+			if CORSAllowCredentials || !corsAllowsAny() {
+				h.Set("Access-Control-Allow-Origin", origin)
+			} else {
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+			if CORSAllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if !preflight {
+			if allowed {
+				h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			}
+			next(w, r)
+			return
+		}
+
+		if allowed {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowedHeaders())
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(CORSMaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func corsAllowsAny() bool {
+	for _, allowed := range CORSAllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func corsOriginAllowed(origin string) bool {
+	for _, allowed := range CORSAllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsAllowedHeaders 是允许跨域请求携带的请求头。
+func corsAllowedHeaders() string {
+	return strings.Join([]string{"Accept", "Accept-Language", "Authorization", "Content-Type", "Idempotency-Key", "X-API-Key", TenantHeader}, ", ")
+}
+
+// withBodyLimit 限制请求体大小：Content-Length 超出上限时直接返回 413；
+// 未声明长度的请求在读取超出上限时失败，由 invalidJSON / bodyReadError 转换为 413。
+func withBodyLimit(class string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := BodyLimits[class]
+		if !ok {
+			limit = BodyLimits["default"]
+		}
+		if limit <= 0 {
+			next(w, r)
+			return
+		}
+		if r.ContentLength > limit {
+			writeError(w, r, payloadTooLarge(limit))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next(w, r)
+	}
+}
+
+func payloadTooLarge(limit int64) *apierror.Error {
+	return apierror.New(http.StatusRequestEntityTooLarge, apierror.PayloadTooLarge, "limit", strconv.FormatInt(limit, 10))
+}
+
+// bodyReadError 转换读取请求体时的错误：超出大小上限为 413，其余为 400。
+func bodyReadError(err error) *apierror.Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return payloadTooLarge(tooLarge.Limit)
+	}
+	return apierror.Wrap(http.StatusBadRequest, apierror.BadRequest, err)
+}
+
+// decodeJSON 把请求体解码到 v；开启 RejectUnknownFields 时拒绝未定义的字段。
+func decodeJSON(body io.Reader, v any) error {
+	dec := json.NewDecoder(body)
+	if RejectUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/models"
+	"golang-starter/utils"
+	"golang-starter/validation"
+)
+
+// TenantHeader 是客户端指定租户的请求头。
+var TenantHeader = "X-Tenant-ID"
+
+// TenantBaseDomain 非空时按子域名识别租户，例如 base 为 "shop.example.com" 时，
+// acme.shop.example.com 的请求属于租户 acme。
+var TenantBaseDomain = ""
+
+var errPlatformCredentialsRequired = apierror.NewVariant(http.StatusForbidden, apierror.Forbidden, "platform_only")
+
+type tenantKey struct{}
+
+// withTenant 解析请求所属的租户并写入 request context，之后的产品、任务、幂等记录都只在该租户内可见。
+// 非平台级凭证只能访问绑定的租户（API 密钥的 tenant_id 或 JWT 的租户 claim），不绑定租户时只能访问 models.DefaultTenant；
+// 请求另外指定了不同的租户时返回 403。平台级凭证（以及未启用认证时）按以下优先级选择租户：
+// 1. TenantHeader 请求头
+// 2. TenantBaseDomain 下的子域名
+// 3. models.DefaultTenant
+func withTenant(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requested, err := requestedTenant(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		tenant := requested
+		if principal, ok := auth.FromContext(r.Context()); ok && !principal.Platform {
+			bound := principal.Tenant
+			if bound == "" {
+				bound = models.DefaultTenant
+			}
+			if requested != "" && requested != bound {
+				writeError(w, r, apierror.New(http.StatusForbidden, apierror.TenantMismatch, "tenant", requested))
+				return
+			}
+			tenant = bound
+		}
+		if tenant == "" {
+			tenant = models.DefaultTenant
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant)))
+	}
+}
+
+// requestedTenant 读取请求头或子域名中指定的租户；都没有指定时返回空字符串。
+func requestedTenant(r *http.Request) (string, error) {
+	tenant := strings.TrimSpace(r.Header.Get(TenantHeader))
+	if tenant == "" && TenantBaseDomain != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		tenant, _ = strings.CutSuffix(host, "."+strings.ToLower(TenantBaseDomain))
+		if tenant == host {
+			tenant = ""
+		}
+	}
+	if tenant != "" && !models.ValidTenantID(tenant) {
+		return "", apierror.New(http.StatusBadRequest, apierror.InvalidTenant, "tenant", tenant)
+	}
+	return tenant, nil
+}
+
+// tenantFromRequest 返回 withTenant 解析出的租户。
+func tenantFromRequest(r *http.Request) string {
+	if tenant, ok := r.Context().Value(tenantKey{}).(string); ok {
+		return tenant
+	}
+	return models.DefaultTenant
+}
+
+// platformOnly 拒绝非平台级的凭证：密钥、租户配额与备份管理会影响所有租户，只能由显式标记为平台级的 admin 操作。
+func platformOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if principal, ok := auth.FromContext(r.Context()); ok && !principal.Platform {
+			writeError(w, r, errPlatformCredentialsRequired)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// setTenantQuotaRequest 是设置租户配额的请求体；max_products 为 0 表示不限制。
+type setTenantQuotaRequest struct {
+	MaxProducts int `json:"max_products" validate:"min=0"`
+}
+
+// HandleTenant 管理单个租户：
+// - GET    /api/admin/tenants/{id}：查询配额与用量
+// - PUT    /api/admin/tenants/{id}：设置产品配额
+// - DELETE /api/admin/tenants/{id}：删除单独配置的配额，恢复为默认配额（不会删除产品）
+func HandleTenant(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/admin/tenants/")
+	if !models.ValidTenantID(id) {
+		writeError(w, r, apierror.New(http.StatusBadRequest, apierror.InvalidTenant, "tenant", id))
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		GetTenant(w, r, id)
+	case "PUT":
+		SetTenantQuota(w, r, id)
+	case "DELETE":
+		ResetTenantQuota(w, r, id)
+	default:
+		writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+// GetTenant 查询租户的配额与用量。
+func GetTenant(w http.ResponseWriter, r *http.Request, id string) {
+	tenant, err := models.GetTenant(r.Context(), utils.ReadDB, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeSuccess(w, r, http.StatusOK, successResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    tenant,
+	})
+}
+
+// SetTenantQuota 设置租户的产品配额。
+func SetTenantQuota(w http.ResponseWriter, r *http.Request, id string) {
+	defer r.Body.Close()
+
+	var req setTenantQuotaRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, r, invalidJSON(err))
+		return
+	}
+	if errs := validation.Struct(&req); len(errs) > 0 {
+		writeError(w, r, errs)
+		return
+	}
+
+	tenant, err := models.SetTenantQuota(r.Context(), utils.DB, id, &req.MaxProducts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeSuccess(w, r, http.StatusOK, successResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    tenant,
+	})
+}
+
+// ResetTenantQuota 恢复租户的默认配额。
+func ResetTenantQuota(w http.ResponseWriter, r *http.Request, id string) {
+	tenant, err := models.SetTenantQuota(r.Context(), utils.DB, id, nil)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeSuccess(w, r, http.StatusOK, successResponse{
+		Code:    http.StatusOK,
+		Message: "success",
+		Data:    tenant,
+	})
+}
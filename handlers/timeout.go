@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// StatusClientClosedRequest 是非标准状态码 499（源自 nginx）：客户端在收到响应之前断开了连接。
+// 客户端看不到这个响应，它只出现在访问日志与幂等记录的处理中。
+const StatusClientClosedRequest = 499
+
+// RequestTimeouts 是各类请求的处理时限，按 withTimeout 的 class 查找；<= 0 表示不限制。
+// 到期后请求的 context 被取消，进行中的数据库查询随之中断，响应 503 REQUEST_TIMEOUT。
+var RequestTimeouts = map[string]time.Duration{
+	"default": 10 * time.Second,
+	"bulk":    time.Minute,
+	"export":  5 * time.Minute,
+}
+
+// withTimeout 为请求的 context 加上 class 对应的截止时间；放在认证之外，认证时的数据库查询同样受时限约束。
+// 客户端断开连接时 net/http 会取消同一个 context，handler 收到的是 context.Canceled，响应 499。
+func withTimeout(class string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout := RequestTimeouts[class]
+		if timeout <= 0 {
+			next(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,135 @@
+package i18n
+
+// 内置消息目录。新增错误码时需要在每种语言中补充对应消息；缺失时回退到英文。
+func init() {
+	Register(En, map[string]string{
+		// 成功响应
+		"success":  "success",
+		"accepted": "accepted",
+
+		// 错误码
+		"BAD_REQUEST":                 "bad request: {detail}",
+		"BAD_REQUEST.products_empty":  "products is empty",
+		"INVALID_JSON":                "invalid JSON: {detail}",
+		"INVALID_ID":                  "invalid id",
+		"INVALID_PAGINATION":          "invalid {param}",
+		"INVALID_PARAMETER":           "invalid {param}",
+		"INVALID_PARAMETER.required":  "{param} is required",
+		"METHOD_NOT_ALLOWED":          "method not allowed",
+		"UNSUPPORTED_MEDIA_TYPE":      "unsupported content type",
+		"PAYLOAD_TOO_LARGE":           "request body too large: limit is {limit} bytes",
+		"NOT_FOUND":                   "not found",
+		"CONFLICT":                    "resource conflict",
+		"VALIDATION_FAILED":           "validation failed",
+		"NO_FIELDS_TO_UPDATE":         "no fields to update",
+		"READ_ONLY_FIELD":             "{field} is read-only",
+		"INVALID_PATCH":               "{detail}",
+		"INVALID_PATCH.not_object":    "merge patch must be a JSON object",
+		"PATCH_CANNOT_APPLY":          "{detail}",
+		"PATCH_CANNOT_APPLY.result":   "patched product must be a JSON object",
+		"PATCH_TEST_FAILED":           "{detail}",
+		"UNAUTHORIZED":                "authentication required",
+		"UNAUTHORIZED.invalid":        "invalid credentials",
+		"FORBIDDEN":                   "insufficient permissions: requires {role} role",
+		"FORBIDDEN.platform_only":     "only platform credentials can perform this operation",
+		"API_KEY_NOT_FOUND":           "api key not found",
+		"API_KEY_REVOKED":             "api key has been revoked",
+		"PRODUCT_NOT_FOUND":           "product not found",
+		"INVALID_TENANT":              "invalid tenant id: {tenant}",
+		"TENANT_MISMATCH":             "credentials are not valid for tenant {tenant}",
+		"QUOTA_EXCEEDED":              "product quota exceeded for this tenant",
+		"JOB_NOT_FOUND":               "job not found",
+		"JOB_NOT_FINISHED":            "job not finished",
+		"JOB_ALREADY_FINISHED":        "job already finished",
+		"JOB_RESULT_NOT_FOUND":        "job has no result file",
+		"JOB_RESULT_NOT_FOUND.gone":   "job result file is gone",
+		"IDEMPOTENCY_KEY_INVALID":     "invalid Idempotency-Key",
+		"IDEMPOTENCY_KEY_REUSED":      "Idempotency-Key was used with a different request body",
+		"IDEMPOTENCY_KEY_IN_PROGRESS": "a request with this Idempotency-Key is in progress",
+		"RATE_LIMITED":                "too many requests, retry after {retry_after} seconds",
+		"BACKUP_NOT_CONFIGURED":       "backups are not configured: set BACKUP_DIR",
+		"REQUEST_CANCELED":            "request canceled by the client",
+		"REQUEST_TIMEOUT":             "request took too long to process, retry later",
+		"INTERNAL_ERROR":              "internal server error",
+
+		// 字段校验
+		"validation.required":        "{field} is required",
+		"validation.min":             "{field} must be at least {param}",
+		"validation.min.length":      "{field} must be at least {param} characters long",
+		"validation.min.nonnegative": "{field} cannot be negative",
+		"validation.max":             "{field} must be at most {param}",
+		"validation.max.length":      "{field} must be at most {param} characters long",
+		"validation.gt":              "{field} must be greater than {param}",
+		"validation.lt":              "{field} must be less than {param}",
+		"validation.len":             "{field} must be exactly {param} characters long",
+		"validation.regex":           "{field} has an invalid format",
+		"validation.oneof":           "{field} must be one of: {param}",
+		"validation.type":            "{field} must be of type {param}",
+		"validation.unknown":         "{field} is not an allowed field",
+		"validation.platform":        "{field} requires role admin and no tenant",
+	})
+
+	Register(ZhCN, map[string]string{
+		"success":  "成功",
+		"accepted": "已受理",
+
+		"BAD_REQUEST":                 "请求不合法：{detail}",
+		"BAD_REQUEST.products_empty":  "products 不能为空",
+		"INVALID_JSON":                "JSON 格式不正确：{detail}",
+		"INVALID_ID":                  "id 不合法",
+		"INVALID_PAGINATION":          "分页参数 {param} 不合法",
+		"INVALID_PARAMETER":           "参数 {param} 不合法",
+		"INVALID_PARAMETER.required":  "缺少参数 {param}",
+		"METHOD_NOT_ALLOWED":          "不支持的请求方法",
+		"UNSUPPORTED_MEDIA_TYPE":      "不支持的 Content-Type",
+		"PAYLOAD_TOO_LARGE":           "请求体过大：上限为 {limit} 字节",
+		"NOT_FOUND":                   "资源不存在",
+		"CONFLICT":                    "资源冲突",
+		"VALIDATION_FAILED":           "参数校验失败",
+		"NO_FIELDS_TO_UPDATE":         "没有需要更新的字段",
+		"READ_ONLY_FIELD":             "{field} 是只读字段",
+		"INVALID_PATCH":               "补丁文档不合法：{detail}",
+		"INVALID_PATCH.not_object":    "merge patch 必须是 JSON 对象",
+		"PATCH_CANNOT_APPLY":          "补丁无法应用：{detail}",
+		"PATCH_CANNOT_APPLY.result":   "补丁应用后的产品必须是 JSON 对象",
+		"PATCH_TEST_FAILED":           "补丁 test 操作未通过：{detail}",
+		"UNAUTHORIZED":                "需要认证",
+		"UNAUTHORIZED.invalid":        "凭证无效",
+		"FORBIDDEN":                   "权限不足：需要 {role} 角色",
+		"FORBIDDEN.platform_only":     "只有平台级凭证才能执行该操作",
+		"API_KEY_NOT_FOUND":           "API 密钥不存在",
+		"API_KEY_REVOKED":             "API 密钥已被吊销",
+		"PRODUCT_NOT_FOUND":           "产品不存在",
+		"INVALID_TENANT":              "租户 ID 无效：{tenant}",
+		"TENANT_MISMATCH":             "凭证无权访问租户 {tenant}",
+		"QUOTA_EXCEEDED":              "已超出该租户的产品配额",
+		"JOB_NOT_FOUND":               "任务不存在",
+		"JOB_NOT_FINISHED":            "任务尚未完成",
+		"JOB_ALREADY_FINISHED":        "任务已结束",
+		"JOB_RESULT_NOT_FOUND":        "任务没有结果文件",
+		"JOB_RESULT_NOT_FOUND.gone":   "任务结果文件已被删除",
+		"IDEMPOTENCY_KEY_INVALID":     "Idempotency-Key 不合法",
+		"IDEMPOTENCY_KEY_REUSED":      "该 Idempotency-Key 已用于不同的请求体",
+		"IDEMPOTENCY_KEY_IN_PROGRESS": "使用该 Idempotency-Key 的请求仍在处理中",
+		"RATE_LIMITED":                "请求过于频繁，请在 {retry_after} 秒后重试",
+		"BACKUP_NOT_CONFIGURED":       "未配置备份：请设置 BACKUP_DIR",
+		"REQUEST_CANCELED":            "客户端已取消请求",
+		"REQUEST_TIMEOUT":             "请求处理超时，请稍后重试",
+		"INTERNAL_ERROR":              "服务器内部错误",
+
+		"validation.required":        "{field} 不能为空",
+		"validation.min":             "{field} 不能小于 {param}",
+		"validation.min.length":      "{field} 长度不能少于 {param} 个字符",
+		"validation.min.nonnegative": "{field} 不能为负数",
+		"validation.max":             "{field} 不能大于 {param}",
+		"validation.max.length":      "{field} 长度不能超过 {param} 个字符",
+		"validation.gt":              "{field} 必须大于 {param}",
+		"validation.lt":              "{field} 必须小于 {param}",
+		"validation.len":             "{field} 长度必须为 {param} 个字符",
+		"validation.regex":           "{field} 格式不正确",
+		"validation.oneof":           "{field} 必须是以下值之一：{param}",
+		"validation.type":            "{field} 的类型必须是 {param}",
+		"validation.unknown":         "{field} 是未定义的字段",
+		"validation.platform":        "{field} 要求角色为 admin 且不绑定租户",
+	})
+}
@@ -0,0 +1,144 @@
+// Package i18n 提供多语言消息目录与语言协商。
+//
+// 消息按 key 索引：错误消息的 key 就是错误码（例如 "PRODUCT_NOT_FOUND"），
+// 同一错误码需要不同文案时使用 "错误码.变体" 形式（例如 "JOB_RESULT_NOT_FOUND.gone"）；
+// 校验消息的 key 形如 "validation.required"。
+// 消息中的 {name} 占位符在渲染时替换为对应参数，例如 "{field} is required"。
+package i18n
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Lang 是受支持的语言标签。
+type Lang string
+
+const (
+	En   Lang = "en"
+	ZhCN Lang = "zh-CN"
+)
+
+// Default 是无法协商出受支持语言时使用的语言，也是日志与 error.Error() 使用的语言。
+const Default = En
+
+var (
+	mu       sync.RWMutex
+	catalogs = map[Lang]map[string]string{}
+)
+
+// Register 向某种语言的消息目录追加（或覆盖）消息。
+func Register(lang Lang, messages map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	catalog, ok := catalogs[lang]
+	if !ok {
+		catalog = map[string]string{}
+		catalogs[lang] = catalog
+	}
+	for key, message := range messages {
+		catalog[key] = message
+	}
+}
+
+// Message 按语言渲染 key 对应的消息；目标语言缺少该 key 时回退到 Default。
+// 两者都没有时返回 ok=false。
+func Message(lang Lang, key string, params map[string]string) (string, bool) {
+	mu.RLock()
+	template, ok := catalogs[lang][key]
+	if !ok {
+		template, ok = catalogs[Default][key]
+	}
+	mu.RUnlock()
+	if !ok {
+		return "", false
+	}
+	return render(template, params), true
+}
+
+// Format 与 Message 相同，但找不到 key 时直接返回 key 本身，便于发现遗漏的消息。
+func Format(lang Lang, key string, params map[string]string) string {
+	if message, ok := Message(lang, key, params); ok {
+		return message
+	}
+	return key
+}
+
+func render(template string, params map[string]string) string {
+	if len(params) == 0 {
+		return template
+	}
+	pairs := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// Parse 把语言标签（例如 "zh"、"zh-Hans-CN"、"en-US"）匹配到受支持的语言，只看主语言子标签。
+func Parse(tag string) (Lang, bool) {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	primary, _, _ = strings.Cut(primary, "_")
+	switch strings.ToLower(primary) {
+	case "zh":
+		return ZhCN, true
+	case "en":
+		return En, true
+	}
+	return "", false
+}
+
+// Negotiate 决定请求使用的语言：
+// 1) 查询参数 lang（例如 ?lang=zh-CN）优先
+// 2) 其次按 Accept-Language 的 q 值从高到低选第一个受支持的语言
+// 3) 都没有时使用 Default
+func Negotiate(r *http.Request) Lang {
+	if lang, ok := Parse(r.URL.Query().Get("lang")); ok {
+		return lang
+	}
+	if lang, ok := parseAcceptLanguage(r.Header.Get("Accept-Language")); ok {
+		return lang
+	}
+	return Default
+}
+
+// parseAcceptLanguage 解析形如 "zh-CN,zh;q=0.9,en;q=0.8" 的请求头。
+func parseAcceptLanguage(header string) (Lang, bool) {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag, q})
+		}
+	}
+	// SliceStable：q 值相同时保持请求头中的原始顺序。
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.tag == "*" {
+			return Default, true
+		}
+		if lang, ok := Parse(c.tag); ok {
+			return lang, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,328 @@
+// Package jobs 实现基于数据库的异步任务队列：
+// 任务记录持久化在 jobs 表中，由一组 worker 轮询领取并执行；进程重启后未完成的任务会重新排队。
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang-starter/models"
+)
+
+// Task 是任务执行体。
+// - ctx：任务被取消或服务停止时会被 cancel，实现方应及时返回
+// - report：上报进度；返回 false 表示任务已被请求取消，调用方应尽快停止
+// 返回的 Result.File 会被记录为结果文件，供下载接口读取，超过 ResultTTL 后删除；
+// 任务失败或被取消时，实现方需自行清理已生成的文件（遗留的文件同样在超过 ResultTTL 后删除）。
+type Task func(ctx context.Context, job *models.Job, report func(done, total int) bool) (*Result, error)
+
+// Result 是任务成功后的输出。
+type Result struct {
+	// Data：写入 jobs.result 的结果摘要，会原样返回给客户端。
+	Data any
+	// File：结果文件路径（可选），例如导出任务生成的文件。
+	File string
+}
+
+// ErrCanceled 供任务实现在 report 返回 false（任务被取消）时返回。
+var ErrCanceled = errors.New("job canceled")
+
+// ResultDir 是任务结果文件的存放目录。
+var ResultDir = "./job_results"
+
+// ResultTTL 是结果文件的保留时间：ResultDir 中修改时间早于 ResultTTL 之前的文件会被定期删除，
+// 之后下载结果返回 404。<= 0 表示永久保留。
+var ResultTTL = 24 * time.Hour
+
+// PollInterval 是 worker 在队列为空时的轮询间隔；Enqueue 会主动唤醒 worker，因此这里只是兜底。
+var PollInterval = time.Second
+
+var (
+	mu       sync.Mutex
+	tasks    = map[string]Task{}
+	running  = map[int]context.CancelFunc{}
+	stop     context.CancelFunc
+	wg       sync.WaitGroup
+	wake     = make(chan struct{}, 1)
+	canceled = map[int]bool{}
+)
+
+// Register 注册一种任务类型的执行体；重复注册会覆盖之前的实现。
+func Register(jobType string, task Task) {
+	mu.Lock()
+	defer mu.Unlock()
+	tasks[jobType] = task
+}
+
+// Registered 判断任务类型是否已注册。
+func Registered(jobType string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	_, ok := tasks[jobType]
+	return ok
+}
+
+// Start 启动 workers 个 worker 开始消费任务队列。
+// 启动前会先恢复上次进程退出时遗留的 running 任务（重新排队或标记为已取消）；ctx 只用于这一步，worker 由 Stop 停止。
+func Start(ctx context.Context, database *sql.DB, workers int) error {
+	if err := models.RecoverJobs(ctx, database); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(ResultDir, 0o755); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mu.Lock()
+	stop = cancel
+	mu.Unlock()
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx, database)
+		}()
+	}
+	if ResultTTL > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sweep(ctx)
+		}()
+	}
+
+	log.Printf("Job workers started (%d)", workers)
+	return nil
+}
+
+// Stop 停止所有 worker 并等待其退出；执行中的任务会被放回队列，下次启动时重新执行。
+func Stop() {
+	mu.Lock()
+	cancel := stop
+	stop = nil
+	mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	wg.Wait()
+}
+
+// Enqueue 为租户创建任务并唤醒空闲 worker。
+func Enqueue(ctx context.Context, database *sql.DB, tenantID string, jobType string, payload any) (*models.Job, error) {
+	if !Registered(jobType) {
+		return nil, fmt.Errorf("unknown job type: %s", jobType)
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := models.CreateJob(ctx, database, tenantID, jobType, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Cancel 请求取消任务：排队中的任务直接取消；本进程正在执行的任务会立即收到 ctx 取消信号。
+func Cancel(ctx context.Context, database *sql.DB, tenantID string, id int) (*models.Job, error) {
+	job, err := models.RequestJobCancel(ctx, database, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	if cancel, ok := running[id]; ok {
+		canceled[id] = true
+		cancel()
+	}
+	mu.Unlock()
+
+	return job, nil
+}
+
+// ResultPath 返回任务结果文件的存放路径。
+func ResultPath(job *models.Job, ext string) string {
+	return filepath.Join(ResultDir, fmt.Sprintf("job-%d.%s", job.ID, ext))
+}
+
+// SweepResults 删除 ResultDir 中修改时间早于 before 的文件，返回删除的文件数。
+// 除了过期的结果，也会清理进程崩溃时遗留的不完整文件。
+func SweepResults(before time.Time) (int, error) {
+	entries, err := os.ReadDir(ResultDir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(ResultDir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// sweep 在启动时以及之后每隔 min(ResultTTL, 1h) 删除一次过期的结果文件，直到 ctx 被取消。
+func sweep(ctx context.Context) {
+	interval := min(ResultTTL, time.Hour)
+	for {
+		if n, err := SweepResults(time.Now().Add(-ResultTTL)); err != nil {
+			log.Printf("sweep job results failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Removed %d expired job result files", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func work(ctx context.Context, database *sql.DB) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := models.ClaimNextJob(ctx, database)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("claim job failed: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			case <-time.After(PollInterval):
+			}
+			continue
+		}
+
+		run(ctx, database, job)
+	}
+}
+
+// run 执行单个任务，并根据执行结果把任务写入终态或放回队列。
+// 进度与终态的写入使用不会被取消的 bookkeeping：任务被取消或服务停止时，仍然要把任务状态写回数据库。
+func run(parent context.Context, database *sql.DB, job *models.Job) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	bookkeeping := context.WithoutCancel(parent)
+
+	mu.Lock()
+	task := tasks[job.Type]
+	running[job.ID] = cancel
+	mu.Unlock()
+
+	defer func() {
+		mu.Lock()
+		delete(running, job.ID)
+		delete(canceled, job.ID)
+		mu.Unlock()
+	}()
+
+	if task == nil {
+		finish(bookkeeping, database, job, models.JobFailed, nil, "", fmt.Sprintf("unknown job type: %s", job.Type))
+		return
+	}
+	if job.CancelRequested {
+		finish(bookkeeping, database, job, models.JobCancelled, nil, "", "")
+		return
+	}
+
+	report := func(done, total int) bool {
+		cancelRequested, err := models.UpdateJobProgress(bookkeeping, database, job.ID, done, total)
+		if err != nil {
+			log.Printf("update job %d progress failed: %v", job.ID, err)
+			return ctx.Err() == nil
+		}
+		if cancelRequested {
+			// 取消请求可能来自其他进程或发生在任务登记之前，这里统一兜底。
+			mu.Lock()
+			canceled[job.ID] = true
+			mu.Unlock()
+			cancel()
+			return false
+		}
+		return ctx.Err() == nil
+	}
+
+	result, err := task(ctx, job, report)
+
+	mu.Lock()
+	userCanceled := canceled[job.ID]
+	mu.Unlock()
+
+	switch {
+	case err == nil:
+		// 即使任务完成的同时收到了取消请求，已经完成的结果仍然保留。
+		var data json.RawMessage
+		var file string
+		if result != nil {
+			if result.Data != nil {
+				if data, err = json.Marshal(result.Data); err != nil {
+					removeResult(result)
+					finish(bookkeeping, database, job, models.JobFailed, nil, "", err.Error())
+					return
+				}
+			}
+			file = result.File
+		}
+		finish(bookkeeping, database, job, models.JobSucceeded, data, file, "")
+	case userCanceled:
+		removeResult(result)
+		finish(bookkeeping, database, job, models.JobCancelled, nil, "", "")
+	case parent.Err() != nil:
+		// 服务停止导致的中断：放回队列，重启后重新执行。
+		if err := models.RequeueJob(bookkeeping, database, job.ID); err != nil {
+			log.Printf("requeue job %d failed: %v", job.ID, err)
+		}
+	default:
+		finish(bookkeeping, database, job, models.JobFailed, nil, "", err.Error())
+	}
+}
+
+// removeResult 删除不会被记录的结果文件。
+func removeResult(result *Result) {
+	if result == nil || result.File == "" {
+		return
+	}
+	if err := os.Remove(result.File); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("remove job result %s failed: %v", result.File, err)
+	}
+}
+
+func finish(ctx context.Context, database *sql.DB, job *models.Job, status string, data json.RawMessage, file string, errMsg string) {
+	if err := models.FinishJob(ctx, database, job.ID, status, data, file, errMsg); err != nil {
+		log.Printf("finish job %d failed: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// APIKey API 密钥模型；明文密钥不落库，只保存哈希。
+type APIKey struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Prefix：明文密钥的前几位，便于在列表中辨认是哪一把密钥。
+	Prefix  string `json:"prefix"`
+	KeyHash string `json:"-"`
+	Role    string `json:"role"`
+	// TenantID：密钥绑定的租户；为空且不是平台级密钥时只能访问默认租户。
+	TenantID string `json:"tenant_id,omitempty"`
+	// Platform：平台级密钥（只能是不绑定租户的 admin），可以访问任意租户并管理密钥与租户配额。
+	Platform   bool       `json:"platform"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Revoked 表示密钥是否已被吊销。
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+var ErrAPIKeyRevoked = errors.New("api key revoked")
+
+const apiKeyColumns = `id, name, prefix, key_hash, role, tenant_id, platform, created_at, rotated_at, last_used_at, revoked_at`
+
+// scanAPIKey 从一行结果中读取 APIKey；row 可以是 *sql.Row 或 *sql.Rows。
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*APIKey, error) {
+	var key APIKey
+	var rotatedAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.KeyHash, &key.Role, &key.TenantID, &key.Platform, &key.CreatedAt, &rotatedAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if rotatedAt.Valid {
+		key.RotatedAt = &rotatedAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// CreateAPIKey 保存一把新密钥（调用方负责生成明文并计算哈希）。
+func CreateAPIKey(ctx context.Context, db Querier, name, role, tenantID string, platform bool, prefix, keyHash string) (*APIKey, error) {
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, `INSERT INTO api_keys (name, prefix, key_hash, role, tenant_id, platform, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		name, prefix, keyHash, role, tenantID, platform, now)
+	if err != nil {
+		return nil, translateDBError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIKey{
+		ID:        int(id),
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		Role:      role,
+		TenantID:  tenantID,
+		Platform:  platform,
+		CreatedAt: now,
+	}, nil
+}
+
+// GetAPIKeyByID 根据 ID 获取密钥（包括已吊销的）。
+func GetAPIKeyByID(ctx context.Context, db Querier, id int) (*APIKey, error) {
+	key, err := scanAPIKey(db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetAPIKeyByHash 根据哈希查找密钥（包括已吊销的），用于认证；每个请求都会调用，使用缓存的预编译语句。
+func GetAPIKeyByHash(ctx context.Context, db Querier, keyHash string) (*APIKey, error) {
+	key, err := scanAPIKey(queryRowContext(ctx, db, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListAPIKeys 按 ID 升序列出所有密钥。
+func ListAPIKeys(ctx context.Context, db Querier) ([]APIKey, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RotateAPIKey 把密钥替换为新的哈希，旧明文立即失效；已吊销的密钥不能轮换。
+func RotateAPIKey(ctx context.Context, db Querier, id int, prefix, keyHash string) (*APIKey, error) {
+	var key *APIKey
+	err := inTx(ctx, db, func(tx Querier) error {
+		result, err := tx.ExecContext(ctx, `UPDATE api_keys SET prefix = ?, key_hash = ?, rotated_at = ? WHERE id = ? AND revoked_at IS NULL`,
+			prefix, keyHash, time.Now().UTC(), id)
+		if err != nil {
+			return translateDBError(err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			// 区分“不存在”与“已吊销”。
+			if _, err := GetAPIKeyByID(ctx, tx, id); err != nil {
+				return err
+			}
+			return ErrAPIKeyRevoked
+		}
+		key, err = GetAPIKeyByID(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RevokeAPIKey 吊销密钥；重复吊销保持第一次的吊销时间。
+func RevokeAPIKey(ctx context.Context, db Querier, id int) (*APIKey, error) {
+	var key *APIKey
+	err := inTx(ctx, db, func(tx Querier) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now().UTC(), id); err != nil {
+			return err
+		}
+		var err error
+		key, err = GetAPIKeyByID(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// TouchAPIKey 记录密钥最近一次使用时间。
+func TouchAPIKey(ctx context.Context, db Querier, id int, now time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, now, id)
+	return err
+}
+
+// CountActivePlatformKeys 统计未吊销的平台级密钥数量。
+func CountActivePlatformKeys(ctx context.Context, db Querier) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_keys WHERE platform = 1 AND revoked_at IS NULL`).Scan(&count)
+	return count, err
+}
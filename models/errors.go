@@ -0,0 +1,22 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrConflict 表示写入违反了唯一性约束（例如主键或 UNIQUE 索引冲突）。
+var ErrConflict = errors.New("conflict")
+
+// translateDBError 把驱动层的约束冲突错误转换为 ErrConflict，便于上层用 errors.Is 判断；
+// 其他错误原样返回。原始错误仍保留在错误链中，只用于日志排查。
+func translateDBError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// IdempotencyRecord 记录一次带 Idempotency-Key 的请求及其首次响应。
+type IdempotencyRecord struct {
+	TenantID    string
+	Key         string
+	Method      string
+	Path        string
+	RequestHash string
+	// Status：首次响应的 HTTP 状态码；0 表示首次请求仍在处理中。
+	Status      int
+	ContentType string
+	Location    string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Pending 表示首次请求是否仍在处理中。
+func (r *IdempotencyRecord) Pending() bool {
+	return r.Status == 0
+}
+
+var ErrIdempotencyRecordNotFound = errors.New("idempotency record not found")
+
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+// GetIdempotencyRecord 查询未过期的记录；不存在或已过期时返回 ErrIdempotencyRecordNotFound。
+func GetIdempotencyRecord(ctx context.Context, db Querier, tenantID, key, method, path string, now time.Time) (*IdempotencyRecord, error) {
+	query := `
+	SELECT tenant_id, key, method, path, request_hash, status, content_type, location, body, created_at, expires_at
+	FROM idempotency_keys
+	WHERE tenant_id = ? AND key = ? AND method = ? AND path = ? AND expires_at > ?
+	`
+	var record IdempotencyRecord
+	err := db.QueryRowContext(ctx, query, tenantID, key, method, path, now).Scan(&record.TenantID, &record.Key, &record.Method, &record.Path, &record.RequestHash,
+		&record.Status, &record.ContentType, &record.Location, &record.Body, &record.CreatedAt, &record.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrIdempotencyRecordNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CreatePendingIdempotencyRecord 占用一个 key，标记首次请求开始处理。
+// 同一个 key 已被（未过期的）记录占用时返回 ErrIdempotencyKeyExists；已过期的旧记录会被先清理掉。
+func CreatePendingIdempotencyRecord(ctx context.Context, db Querier, tenantID, key, method, path, requestHash string, now time.Time, ttl time.Duration) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE tenant_id = ? AND key = ? AND method = ? AND path = ? AND expires_at <= ?`,
+		tenantID, key, method, path, now); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, `INSERT INTO idempotency_keys (tenant_id, key, method, path, request_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		tenantID, key, method, path, requestHash, now, now.Add(ttl))
+	if err = translateDBError(err); errors.Is(err, ErrConflict) {
+		return ErrIdempotencyKeyExists
+	}
+	return err
+}
+
+// CompleteIdempotencyRecord 保存首次请求的响应，之后的重放直接返回这份响应。
+func CompleteIdempotencyRecord(ctx context.Context, db Querier, tenantID, key, method, path string, status int, contentType, location string, body []byte) error {
+	_, err := db.ExecContext(ctx, `UPDATE idempotency_keys SET status = ?, content_type = ?, location = ?, body = ? WHERE tenant_id = ? AND key = ? AND method = ? AND path = ?`,
+		status, contentType, location, body, tenantID, key, method, path)
+	return err
+}
+
+// DeleteIdempotencyRecord 释放一个 key（例如首次请求失败于服务端错误，允许客户端用同一个 key 重试）。
+func DeleteIdempotencyRecord(ctx context.Context, db Querier, tenantID, key, method, path string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE tenant_id = ? AND key = ? AND method = ? AND path = ?`, tenantID, key, method, path)
+	return err
+}
+
+// DeleteExpiredIdempotencyRecords 清理所有已过期的记录，返回清理条数。
+func DeleteExpiredIdempotencyRecords(ctx context.Context, db Querier, now time.Time) (int64, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
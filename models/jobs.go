@@ -0,0 +1,236 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 任务状态：queued → running → succeeded / failed / cancelled。
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job 异步任务模型。
+type Job struct {
+	ID int `json:"id"`
+	// TenantID：创建任务的租户；任务只能被同一租户查询、取消，执行时也只操作该租户的产品。
+	TenantID string `json:"-"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	// Payload：任务参数（JSON），只在服务端内部使用，不返回给客户端。
+	Payload json.RawMessage `json:"-"`
+	// Progress/Total：已处理数量与总数量；Total 为 0 表示总量未知。
+	Progress int `json:"progress"`
+	Total    int `json:"total"`
+	// Result：任务成功后的结果摘要（JSON）。
+	Result json.RawMessage `json:"result,omitempty"`
+	// ResultFile：结果文件在服务端的路径（例如导出文件），通过下载接口获取，不直接暴露。
+	ResultFile      string `json:"-"`
+	Error           string `json:"error,omitempty"`
+	CancelRequested bool   `json:"cancel_requested"`
+	// Checkpoint：任务自己记录的断点（JSON），与已提交的数据在同一个事务中写入；
+	// 任务被打断后重新执行时从断点继续，而不是从头开始。只在服务端内部使用。
+	Checkpoint json.RawMessage `json:"-"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Finished 表示任务是否已处于终态。
+func (j *Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+var ErrJobNotFound = errors.New("job not found")
+
+var ErrJobFinished = errors.New("job already finished")
+
+const jobColumns = `id, tenant_id, type, status, payload, progress, total, result, result_file, error, cancel_requested, checkpoint, created_at, updated_at, started_at, finished_at`
+
+// scanJob 从一行结果中读取 Job；row 可以是 *sql.Row 或 *sql.Rows。
+func scanJob(row interface{ Scan(dest ...any) error }) (*Job, error) {
+	var job Job
+	var payload, result, checkpoint string
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.TenantID, &job.Type, &job.Status, &payload, &job.Progress, &job.Total, &result, &job.ResultFile,
+		&job.Error, &job.CancelRequested, &checkpoint, &job.CreatedAt, &job.UpdatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if payload != "" {
+		job.Payload = json.RawMessage(payload)
+	}
+	if result != "" {
+		job.Result = json.RawMessage(result)
+	}
+	if checkpoint != "" {
+		job.Checkpoint = json.RawMessage(checkpoint)
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// CreateJob 创建一个排队中的任务。
+func CreateJob(ctx context.Context, db Querier, tenantID string, jobType string, payload json.RawMessage) (*Job, error) {
+	now := time.Now()
+	result, err := db.ExecContext(ctx, `INSERT INTO jobs (tenant_id, type, status, payload, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		tenantID, jobType, JobQueued, string(payload), now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{
+		ID:        int(id),
+		TenantID:  tenantID,
+		Type:      jobType,
+		Status:    JobQueued,
+		Payload:   payload,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// GetJobByID 根据 ID 获取租户的任务；其他租户的任务与不存在的任务一样返回 ErrJobNotFound。
+func GetJobByID(ctx context.Context, db Querier, tenantID string, id int) (*Job, error) {
+	return getJob(db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE tenant_id = ? AND id = ?`, tenantID, id))
+}
+
+// getJob 读取单个任务；worker 领取任务时不区分租户。
+func getJob(row *sql.Row) (*Job, error) {
+	job, err := scanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ClaimNextJob 领取最早排队的任务并标记为 running；没有可领取的任务时返回 (nil, nil)。
+// 查找、标记与读取在同一个写事务中完成，多个 worker（或多个进程）并发领取时同一个任务只会被领取一次。
+func ClaimNextJob(ctx context.Context, db Querier) (*Job, error) {
+	var job *Job
+	err := inTx(ctx, db, func(tx Querier) error {
+		job = nil
+		var id int
+		err := tx.QueryRowContext(ctx, `SELECT id FROM jobs WHERE status = ? ORDER BY id ASC LIMIT 1`, JobQueued).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if _, err := tx.ExecContext(ctx, `UPDATE jobs SET status = ?, started_at = ?, updated_at = ? WHERE id = ? AND status = ?`,
+			JobRunning, now, now, id, JobQueued); err != nil {
+			return err
+		}
+
+		job, err = getJob(tx.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// UpdateJobProgress 更新任务进度，并返回该任务是否已被请求取消（供 worker 及时停止）。
+func UpdateJobProgress(ctx context.Context, db Querier, id int, progress int, total int) (cancelRequested bool, err error) {
+	err = db.QueryRowContext(ctx, `UPDATE jobs SET progress = ?, total = ?, updated_at = ? WHERE id = ? RETURNING cancel_requested`,
+		progress, total, time.Now(), id).Scan(&cancelRequested)
+	return cancelRequested, err
+}
+
+// SaveJobCheckpoint 保存任务的断点并更新进度；应当与断点对应的数据写入在同一个事务中调用（db 传入事务），
+// 这样断点之前的数据一定已经提交，断点之后的数据一定没有提交。
+func SaveJobCheckpoint(ctx context.Context, db Querier, id int, checkpoint json.RawMessage, progress int, total int) error {
+	_, err := db.ExecContext(ctx, `UPDATE jobs SET checkpoint = ?, progress = ?, total = ?, updated_at = ? WHERE id = ?`,
+		string(checkpoint), progress, total, time.Now(), id)
+	return err
+}
+
+// FinishJob 把任务写入终态（succeeded/failed/cancelled），同时记录结果或错误信息。
+func FinishJob(ctx context.Context, db Querier, id int, status string, result json.RawMessage, resultFile string, errMsg string) error {
+	now := time.Now()
+	_, err := db.ExecContext(ctx, `UPDATE jobs SET status = ?, result = ?, result_file = ?, error = ?, updated_at = ?, finished_at = ? WHERE id = ?`,
+		status, string(result), resultFile, errMsg, now, now, id)
+	return err
+}
+
+// requeueProgress：被打断的任务重新排队时，没有断点的从头执行、进度清零；有断点的从断点继续、保留进度。
+const requeueProgress = `progress = CASE WHEN checkpoint = '' THEN 0 ELSE progress END`
+
+// RequeueJob 把执行中被打断（例如进程退出）的任务放回队列，等待重新执行。
+func RequeueJob(ctx context.Context, db Querier, id int) error {
+	_, err := db.ExecContext(ctx, `UPDATE jobs SET status = ?, `+requeueProgress+`, started_at = NULL, updated_at = ? WHERE id = ? AND status = ?`,
+		JobQueued, time.Now(), id, JobRunning)
+	return err
+}
+
+// RecoverJobs 在启动时调用：上次进程退出时仍在 running 的任务，
+// 已请求取消的直接标记为 cancelled，其余重新放回队列。
+func RecoverJobs(ctx context.Context, db Querier) error {
+	now := time.Now()
+	return inTx(ctx, db, func(tx Querier) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE jobs SET status = ?, updated_at = ?, finished_at = ? WHERE status IN (?, ?) AND cancel_requested = 1`,
+			JobCancelled, now, now, JobQueued, JobRunning); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE jobs SET status = ?, `+requeueProgress+`, started_at = NULL, updated_at = ? WHERE status = ?`,
+			JobQueued, now, JobRunning)
+		return err
+	})
+}
+
+// RequestJobCancel 请求取消任务：
+// - 排队中的任务直接标记为 cancelled
+// - 执行中的任务只设置 cancel_requested，由 worker 感知后停止
+// - 已结束的任务返回 ErrJobFinished
+// 检查状态与更新在同一个事务中，不会把 worker 刚刚写入终态的任务再改回 cancelled。
+func RequestJobCancel(ctx context.Context, db Querier, tenantID string, id int) (*Job, error) {
+	var job *Job
+	err := inTx(ctx, db, func(tx Querier) error {
+		current, err := GetJobByID(ctx, tx, tenantID, id)
+		if err != nil {
+			return err
+		}
+		if current.Finished() {
+			return ErrJobFinished
+		}
+
+		now := time.Now()
+		if _, err := tx.ExecContext(ctx, `UPDATE jobs SET cancel_requested = 1, updated_at = ? WHERE id = ?`, now, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE jobs SET status = ?, finished_at = ? WHERE id = ? AND status = ?`,
+			JobCancelled, now, id, JobQueued); err != nil {
+			return err
+		}
+
+		job, err = GetJobByID(ctx, tx, tenantID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
@@ -2,6 +2,7 @@ package models
 
 // import 块：模型/数据访问层依赖。
 import (
+	"context"
 	// database/sql：提供 Query/Exec/Row/Rows 等，用于与具体 driver（sqlite3）交互。
 	"database/sql"
 	"fmt"
@@ -14,15 +15,16 @@ import (
 )
 
 // Product 产品模型
+// validate tag 声明字段的校验规则（见 validation 包），创建、更新、批量创建与 PATCH 共用同一套规则。
 type Product struct {
 	// ID：主键，自增；JSON 输出为 "id"。
 	ID int `json:"id"`
 	// Name：产品名称；JSON 输出为 "name"。
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,max=255"`
 	// Price：产品价格；学习示例用 float64（生产环境常用整数分/decimal 以避免浮点误差）。
-	Price float64 `json:"price"`
+	Price float64 `json:"price" validate:"gt=0"`
 	// Stock：库存数量（非负整数）。
-	Stock int `json:"stock"`
+	Stock int `json:"stock" validate:"min=0"`
 	// CreatedAt：创建时间；time.Time 会被 encoding/json 序列化为 RFC3339 格式字符串。
 	CreatedAt time.Time `json:"created_at"`
 	// UpdatedAt：更新时间。
@@ -33,12 +35,31 @@ var ErrProductNotFound = errors.New("product not found")
 
 var ErrNoFields = errors.New("no fields to update")
 
+// 所有产品查询都按 tenantID 过滤：一个租户读写不到其他租户的产品，
+// 访问其他租户的产品 ID 与访问不存在的 ID 一样返回 ErrProductNotFound。
+
+// 热点查询的 SQL 固定不变，可以预编译后复用（见 statements.go）。
+const (
+	productByIDQuery = `SELECT id, name, price, stock, created_at, updated_at FROM products WHERE tenant_id = ? AND id = ?`
+	// ORDER BY id：保证返回顺序稳定（便于测试与客户端展示）；升序与降序是两条不同的语句。
+	listProductsAscQuery  = `SELECT id, name, price, stock, created_at, updated_at FROM products WHERE tenant_id = ? ORDER BY id ASC LIMIT ? OFFSET ?`
+	listProductsDescQuery = `SELECT id, name, price, stock, created_at, updated_at FROM products WHERE tenant_id = ? ORDER BY id DESC LIMIT ? OFFSET ?`
+	countProductsQuery    = `SELECT COUNT(*) FROM products WHERE tenant_id = ?`
+)
+
+// listProductsQuery 按排序方式选择列表查询；order 只能是 "id_desc" 或升序，不会拼接进 SQL。
+func listProductsQuery(order string) string {
+	if order == "id_desc" {
+		return listProductsDescQuery
+	}
+	return listProductsAscQuery
+}
+
 // GetProductByID 根据 ID 获取产品
-func GetProductByID(db *sql.DB, id int) (*Product, error) {
-	// query：参数化查询；使用 ? 占位符由 driver 安全绑定参数，避免 SQL 注入。
-	query := `SELECT id, name, price, stock, created_at, updated_at FROM products WHERE id = ?`
+func GetProductByID(ctx context.Context, db Querier, tenantID string, id int) (*Product, error) {
+	// 参数化查询：使用 ? 占位符由 driver 安全绑定参数，避免 SQL 注入；热点查询使用缓存的预编译语句，不必每次重新解析 SQL。
 	// QueryRow：预期最多返回一行；没有数据时 Scan 会返回 sql.ErrNoRows。
-	row := db.QueryRow(query, id)
+	row := queryRowContext(ctx, db, productByIDQuery, tenantID, id)
 
 	// product：用于接收扫描结果。
 	var product Product
@@ -63,23 +84,9 @@ type GetAllProductsParams struct {
 }
 
 // GetAllProducts 获取所有产品
-func GetAllProducts(db *sql.DB, params GetAllProductsParams) ([]*Product, error) {
-	// ORDER BY id ASC：保证返回顺序稳定（便于测试与客户端展示）。
-	query := `
-	SELECT id, name, price, stock, created_at, updated_at 
-	FROM products  
-	ORDER BY id %s
-	LIMIT ? OFFSET ?
-	`
-	orderBy := "ASC"
-	if params.Order == "id_desc" {
-		orderBy = "DESC"
-	} else {
-		orderBy = "ASC"
-	}
-	query = fmt.Sprintf(query, orderBy)
+func GetAllProducts(ctx context.Context, db Querier, tenantID string, params GetAllProductsParams) ([]*Product, error) {
 	// Query：返回多行结果集。
-	rows, err := db.Query(query, params.Limit, params.Offset)
+	rows, err := queryContext(ctx, db, listProductsQuery(params.Order), tenantID, params.Limit, params.Offset)
 	if err != nil {
 		// 查询失败：返回错误给上层处理（通常会转成 500）。
 		return nil, err
@@ -110,15 +117,57 @@ func GetAllProducts(db *sql.DB, params GetAllProductsParams) ([]*Product, error)
 	return products, nil
 }
 
-// CreateProduct 创建产品
-func CreateProduct(db *sql.DB, product *Product) (*Product, error) {
-	// INSERT：写入 name/price/stock，同时写入 created_at 与 updated_at。
-	query := `INSERT INTO products (name, price, stock, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
+// StreamProducts 以游标方式逐行读取产品，并对每一行调用 fn。
+// 与 GetAllProducts 不同，它不会把结果累积到切片里，因此内存占用与总行数无关，适合导出海量数据。
+// Limit <= 0 表示不限制条数；fn 返回错误时立即停止迭代并把错误向上返回。
+func StreamProducts(ctx context.Context, db Querier, tenantID string, params GetAllProductsParams, fn func(*Product) error) error {
+	// SQLite 中 LIMIT -1 表示不限制条数。
+	limit := params.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	// 导出很少执行，并且会长时间占用连接，不使用缓存的预编译语句。
+	rows, err := db.QueryContext(ctx, listProductsQuery(params.Order), tenantID, limit, params.Offset)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// product：整个迭代过程复用同一个结构体，fn 不应在返回后继续持有该指针。
+	var product Product
+	for rows.Next() {
+		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &product.CreatedAt, &product.UpdatedAt); err != nil {
+			return err
+		}
+		if err := fn(&product); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// CountProducts 统计租户的产品总数。
+func CountProducts(ctx context.Context, db Querier, tenantID string) (int, error) {
+	var count int
+	err := queryRowContext(ctx, db, countProductsQuery, tenantID).Scan(&count)
+	return count, err
+}
+
+// CreateProduct 创建产品；超出租户配额时返回 ErrQuotaExceeded。
+func CreateProduct(ctx context.Context, db Querier, tenantID string, product *Product) (*Product, error) {
+	// INSERT ... SELECT ... WHERE：配额检查与写入在同一条语句中完成，并发创建也不会超出配额。
+	query := `INSERT INTO products (tenant_id, name, price, stock, created_at, updated_at) SELECT ?, ?, ?, ?, ?, ? WHERE ` + quotaCondition
 	// Exec：执行写操作；返回 sql.Result 可用于获取 LastInsertId/RowsAffected。
-	result, err := db.Exec(query, product.Name, product.Price, product.Stock, time.Now(), time.Now())
+	args := append([]any{tenantID, product.Name, product.Price, product.Stock, time.Now(), time.Now()}, quotaArgs(tenantID, 1)...)
+	result, err := db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkQuota(result); err != nil {
+		return nil, err
+	}
 
 	// LastInsertId：获取 SQLite 自增主键值。
 	id, err := result.LastInsertId()
@@ -137,11 +186,11 @@ func CreateProduct(db *sql.DB, product *Product) (*Product, error) {
 }
 
 // UpdateProduct 更新产品
-func UpdateProduct(db *sql.DB, product *Product) (*Product, error) {
+func UpdateProduct(ctx context.Context, db Querier, tenantID string, product *Product) (*Product, error) {
 	// UPDATE：根据 id 更新 name/price/stock，并更新 updated_at。
-	query := `UPDATE products SET name = ?, price = ?, stock = ?, updated_at = ? WHERE id = ?`
+	query := `UPDATE products SET name = ?, price = ?, stock = ?, updated_at = ? WHERE tenant_id = ? AND id = ?`
 	// Exec：执行更新操作。
-	result, err := db.Exec(query, product.Name, product.Price, product.Stock, time.Now(), product.ID)
+	result, err := db.ExecContext(ctx, query, product.Name, product.Price, product.Stock, time.Now(), tenantID, product.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -164,11 +213,11 @@ func UpdateProduct(db *sql.DB, product *Product) (*Product, error) {
 }
 
 // DeleteProduct 删除产品
-func DeleteProduct(db *sql.DB, id int) error {
+func DeleteProduct(ctx context.Context, db Querier, tenantID string, id int) error {
 	// DELETE：按 id 删除一行。
-	query := `DELETE FROM products WHERE id = ?`
+	query := `DELETE FROM products WHERE tenant_id = ? AND id = ?`
 	// Exec：执行删除操作。
-	result, err := db.Exec(query, id)
+	result, err := db.ExecContext(ctx, query, tenantID, id)
 	if err != nil {
 		return err
 	}
@@ -188,11 +237,11 @@ func DeleteProduct(db *sql.DB, id int) error {
 	return nil
 }
 
-func SearchProduct(db *sql.DB, name string) ([]*Product, error) {
+func SearchProduct(ctx context.Context, db Querier, tenantID string, name string) ([]*Product, error) {
 	// ORDER BY id ASC：保证返回顺序稳定（便于测试与客户端展示）。
-	query := `SELECT id, name, price, stock, created_at, updated_at FROM products WHERE name LIKE ? ORDER BY id ASC`
+	query := `SELECT id, name, price, stock, created_at, updated_at FROM products WHERE tenant_id = ? AND name LIKE ? ORDER BY id ASC`
 	// Query：返回多行结果集。
-	rows, err := db.Query(query, "%"+name+"%")
+	rows, err := db.QueryContext(ctx, query, tenantID, "%"+name+"%")
 	if err != nil {
 		// 查询失败：返回错误给上层处理（通常会转成 500）。
 		return nil, err
@@ -223,46 +272,77 @@ func SearchProduct(db *sql.DB, name string) ([]*Product, error) {
 	return products, nil
 }
 
-func ProductsBulk(db *sql.DB, products []*Product) ([]*Product, error) {
+// bulkInsertRows 是 ProductsBulk 每条 INSERT 写入的最大行数：每行 6 个参数，远低于 SQLite 单条语句的参数上限（32766）。
+const bulkInsertRows = 500
+
+// ProductsBulk 批量创建产品：按 bulkInsertRows 分成多条 INSERT，在同一个事务中执行。
+// 任何一批失败或写入后会超出租户配额时整个事务回滚，一条都不写入，超出配额返回 ErrQuotaExceeded。
+func ProductsBulk(ctx context.Context, db Querier, tenantID string, products []*Product) ([]*Product, error) {
+	time_now := time.Now()
+	created := []*Product{}
+
+	err := inTx(ctx, db, func(tx Querier) error {
+		created = created[:0]
+		for start := 0; start < len(products); start += bulkInsertRows {
+			end := start + bulkInsertRows
+			if end > len(products) {
+				end = len(products)
+			}
+			if err := insertProducts(ctx, tx, tenantID, products[start:end], time_now); err != nil {
+				return err
+			}
+			created = append(created, products[start:end]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// insertProducts 用一条 INSERT 写入一批产品并回填 ID；配额条件统计的产品数包括同一事务中之前写入的批次。
+func insertProducts(ctx context.Context, db Querier, tenantID string, products []*Product, now time.Time) error {
 	query := `
-		INSERT INTO products (name, price, stock, created_at, updated_at) 
-		VALUES 
+		INSERT INTO products (tenant_id, name, price, stock, created_at, updated_at) 
+		SELECT * FROM (VALUES 
 	`
 	args := []any{}
 	placeholders := []string{}
 
-	time_now := time.Now()
-
 	for _, product := range products {
-		placeholders = append(placeholders, "(?,?,?,?,?)")
-		args = append(args, product.Name, product.Price, product.Stock, time_now, time_now)
+		placeholders = append(placeholders, "(?,?,?,?,?,?)")
+		args = append(args, tenantID, product.Name, product.Price, product.Stock, now, now)
 	}
 
-	query += strings.Join(placeholders, ",")
+	query += strings.Join(placeholders, ",") + ") WHERE " + quotaCondition
+	args = append(args, quotaArgs(tenantID, len(products))...)
 
-	result, err := db.Exec(query, args...)
+	result, err := db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := checkQuota(result); err != nil {
+		return err
 	}
 
+	// LastInsertId 是最后一行的 ID，同一条 INSERT 写入的行 ID 连续。
 	ids, err := result.LastInsertId()
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	created := []*Product{}
+	first := int(ids) - len(products) + 1
 
 	for i, product := range products {
-		product.ID = int(ids) + i
-		product.CreatedAt = time_now
-		product.UpdatedAt = time_now
-		created = append(created, product)
+		product.ID = first + i
+		product.CreatedAt = now
+		product.UpdatedAt = now
 	}
-
-	return created, nil
+	return nil
 }
 
-func UpdateLocalProduct(db *sql.DB, id int, fields []string, p Product) (*Product, error) {
+func UpdateLocalProduct(ctx context.Context, db Querier, tenantID string, id int, fields []string, p Product) (*Product, error) {
 	if len(fields) == 0 {
 		return nil, ErrNoFields
 	}
@@ -287,41 +367,44 @@ func UpdateLocalProduct(db *sql.DB, id int, fields []string, p Product) (*Produc
 	}
 
 	setParts = append(setParts, "updated_at = ?")
-	args = append(args, time.Now(), id)
+	args = append(args, time.Now(), tenantID, id)
 
 	query := fmt.Sprintf(
-		"UPDATE products SET %s WHERE id = ?",
+		"UPDATE products SET %s WHERE tenant_id = ? AND id = ?",
 		strings.Join(setParts, ", "),
 	)
 
-	result, err := db.Exec(query, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return nil, err
-	}
-	if rows == 0 {
-		return nil, ErrProductNotFound
-	}
+	// 更新与读取在同一个事务中：返回的一定是本次更新后的数据，不会混入其他写入者之后的修改。
+	var updated Product
+	err := inTx(ctx, db, func(tx Querier) error {
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
 
-	// 查最新数据返回
-	row := db.QueryRow(`
-		SELECT id, name, price, stock, created_at, updated_at
-		FROM products WHERE id = ?
-	`, id)
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrProductNotFound
+		}
 
-	var updated Product
-	err = row.Scan(
-		&updated.ID,
-		&updated.Name,
-		&updated.Price,
-		&updated.Stock,
-		&updated.CreatedAt,
-		&updated.UpdatedAt,
-	)
+		// 查最新数据返回
+		row := tx.QueryRowContext(ctx, `
+			SELECT id, name, price, stock, created_at, updated_at
+			FROM products WHERE tenant_id = ? AND id = ?
+		`, tenantID, id)
+
+		return row.Scan(
+			&updated.ID,
+			&updated.Name,
+			&updated.Price,
+			&updated.Stock,
+			&updated.CreatedAt,
+			&updated.UpdatedAt,
+		)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"golang-starter/utils"
+)
+
+// Querier 是 models 函数执行 SQL 所需的方法，*sql.DB 与 *sql.Tx 都满足：
+// 传入 *sql.Tx 时多个 models 调用属于同一个事务，由调用方（通常是 utils.WithTx）负责提交或回滚。
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+var (
+	_ Querier = (*sql.DB)(nil)
+	_ Querier = (*sql.Tx)(nil)
+)
+
+// inTx 让包含多条语句的操作原子地执行：db 已经是事务时直接在其中执行，
+// 是连接池时开启新事务（遇到 SQLITE_BUSY 会整体重试）。
+func inTx(ctx context.Context, db Querier, fn func(tx Querier) error) error {
+	pool, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	return utils.RunTx(ctx, pool, func(tx *sql.Tx) error {
+		return fn(tx)
+	})
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"golang-starter/utils"
+)
+
+// StatementCache 控制热点查询是否使用缓存的预编译语句；关闭后每次调用都重新解析 SQL（基准测试用于对比）。
+var StatementCache = true
+
+// statements 缓存按连接池与 SQL 文本区分的 *sql.Stmt。
+// *sql.Stmt 由 database/sql 在每个连接上第一次使用时准备一次，之后同一连接上直接复用；
+// 迁移执行后（utils.SchemaGeneration 变化）表结构可能改变，缓存整体作废重建。
+var statements = struct {
+	sync.Mutex
+	generation uint64
+	stmts      map[stmtKey]*cachedStmt
+}{}
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+// cachedStmt 是缓存中的一条语句。refs 是正在使用它的调用数：作废时仍有调用在使用的语句只标记 retired，
+// 由最后一个使用者关闭，避免其他 goroutine 拿到语句后执行时报 "sql: statement is closed"。
+// 已经返回的 *sql.Rows 不需要计数：database/sql 会等 Rows 关闭后才真正关闭语句。
+type cachedStmt struct {
+	stmt    *sql.Stmt
+	refs    int
+	retired bool
+}
+
+// releaseLocked 结束一次使用；语句已作废且没有其他使用者时关闭它。调用方持有 statements 的锁。
+func (c *cachedStmt) releaseLocked() {
+	c.refs--
+	if c.retired && c.refs == 0 {
+		c.stmt.Close()
+	}
+}
+
+// retireLocked 作废所有缓存的语句；没有使用者的立即关闭。调用方持有 statements 的锁。
+func retireLocked(generation uint64) {
+	for _, c := range statements.stmts {
+		c.retired = true
+		if c.refs == 0 {
+			c.stmt.Close()
+		}
+	}
+	statements.stmts = map[stmtKey]*cachedStmt{}
+	statements.generation = generation
+}
+
+// prepared 返回 query 在 db 上的预编译语句，以及使用完毕（语句的 Query 调用返回）后必须调用的 release；
+// db 是事务、缓存关闭或准备失败时返回 nil，由调用方直接执行 SQL。
+// 准备语句需要拿到一个连接，因此不在持有锁时进行，避免一个繁忙的连接池阻塞其他连接池上的查询。
+func prepared(ctx context.Context, db Querier, query string) (*sql.Stmt, func()) {
+	pool, ok := db.(*sql.DB)
+	if !ok || !StatementCache {
+		return nil, nil
+	}
+	key := stmtKey{db: pool, query: query}
+
+	statements.Lock()
+	generation := utils.SchemaGeneration()
+	if statements.generation != generation {
+		retireLocked(generation)
+	}
+	if c := statements.stmts[key]; c != nil {
+		c.refs++
+		statements.Unlock()
+		return c.stmt, release(c)
+	}
+	statements.Unlock()
+
+	stmt, err := pool.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil
+	}
+
+	statements.Lock()
+	defer statements.Unlock()
+	if statements.generation != generation {
+		// 准备期间执行了迁移：语句可能已经过时，丢弃后直接执行 SQL。
+		stmt.Close()
+		return nil, nil
+	}
+	c := statements.stmts[key]
+	if c != nil {
+		stmt.Close()
+	} else {
+		c = &cachedStmt{stmt: stmt}
+		statements.stmts[key] = c
+	}
+	c.refs++
+	return c.stmt, release(c)
+}
+
+func release(c *cachedStmt) func() {
+	return func() {
+		statements.Lock()
+		defer statements.Unlock()
+		c.releaseLocked()
+	}
+}
+
+// CachedStatements 返回当前缓存的预编译语句数量。
+func CachedStatements() int {
+	statements.Lock()
+	defer statements.Unlock()
+	if statements.generation != utils.SchemaGeneration() {
+		return 0
+	}
+	return len(statements.stmts)
+}
+
+// queryRowContext 与 db.QueryRowContext 相同，能用预编译语句时使用预编译语句。
+func queryRowContext(ctx context.Context, db Querier, query string, args ...any) *sql.Row {
+	if stmt, release := prepared(ctx, db, query); stmt != nil {
+		defer release()
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+// queryContext 与 db.QueryContext 相同，能用预编译语句时使用预编译语句。
+func queryContext(ctx context.Context, db Querier, query string, args ...any) (*sql.Rows, error) {
+	if stmt, release := prepared(ctx, db, query); stmt != nil {
+		defer release()
+		return stmt.QueryContext(ctx, args...)
+	}
+	return db.QueryContext(ctx, query, args...)
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+)
+
+// DefaultTenant 是未指定租户的请求（以及多租户之前的存量数据）所属的租户。
+const DefaultTenant = "default"
+
+// DefaultMaxProducts 是没有单独配置配额的租户最多可以拥有的产品数；<= 0 表示不限制。
+var DefaultMaxProducts = 0
+
+var ErrQuotaExceeded = errors.New("tenant product quota exceeded")
+
+// tenantIDPattern：租户 ID 只能是小写字母、数字与连字符，与子域名的字符集一致。
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ValidTenantID 判断 id 是否是合法的租户 ID。
+func ValidTenantID(id string) bool {
+	return tenantIDPattern.MatchString(id)
+}
+
+// Tenant 租户的配额与用量。
+// 租户不需要预先创建：tenants 表中只保存单独配置过配额的租户。
+type Tenant struct {
+	ID string `json:"id"`
+	// MaxProducts：生效的产品配额；0 表示不限制。
+	MaxProducts int `json:"max_products"`
+	// CustomQuota：是否单独配置了配额（否则使用 DefaultMaxProducts）。
+	CustomQuota bool `json:"custom_quota"`
+	// Products：当前产品数。
+	Products int `json:"products"`
+}
+
+// quotaCondition 是写入产品时的配额条件，配合 quotaArgs 使用：
+// 生效配额 <= 0（不限制），或现有产品数 + 本次写入数不超过配额。
+const quotaCondition = `(
+	COALESCE((SELECT max_products FROM tenants WHERE id = ?), ?) <= 0
+	OR (SELECT COUNT(*) FROM products WHERE tenant_id = ?) + ? <= COALESCE((SELECT max_products FROM tenants WHERE id = ?), ?)
+)`
+
+func quotaArgs(tenantID string, n int) []any {
+	return []any{tenantID, DefaultMaxProducts, tenantID, n, tenantID, DefaultMaxProducts}
+}
+
+// checkQuota 把带配额条件的 INSERT 没有写入任何行解释为超出配额。
+func checkQuota(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// GetTenant 返回租户的配额与用量。
+func GetTenant(ctx context.Context, db Querier, id string) (*Tenant, error) {
+	tenant := Tenant{ID: id, MaxProducts: DefaultMaxProducts}
+
+	var maxProducts sql.NullInt64
+	err := db.QueryRowContext(ctx, `SELECT max_products FROM tenants WHERE id = ?`, id).Scan(&maxProducts)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if maxProducts.Valid {
+		tenant.MaxProducts = int(maxProducts.Int64)
+		tenant.CustomQuota = true
+	}
+	if tenant.MaxProducts < 0 {
+		tenant.MaxProducts = 0
+	}
+
+	if tenant.Products, err = CountProducts(ctx, db, id); err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// SetTenantQuota 设置租户的产品配额；maxProducts 为 nil 时恢复为默认配额，0 表示不限制。
+// 配额低于现有产品数时不会删除产品，只是不能再创建。
+func SetTenantQuota(ctx context.Context, db Querier, id string, maxProducts *int) (*Tenant, error) {
+	var value sql.NullInt64
+	if maxProducts != nil {
+		value = sql.NullInt64{Int64: int64(*maxProducts), Valid: true}
+	}
+
+	var tenant *Tenant
+	err := inTx(ctx, db, func(tx Querier) error {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO tenants (id, max_products, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET max_products = excluded.max_products, updated_at = excluded.updated_at
+		`, id, value, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		tenant, err = GetTenant(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
@@ -0,0 +1,257 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Document 是解析后的 OpenAPI 文档，只保留请求校验需要的部分。
+type Document struct {
+	Paths      map[string]*PathItem `json:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema    `json:"schemas"`
+		Parameters map[string]*Parameter `json:"parameters"`
+	} `json:"components"`
+
+	// templates 是所有 path 模板，字面量段多的排在前面，例如 /api/products/search 优先于 /api/products/{id}。
+	templates []string
+}
+
+// PathItem 是一个 path 下的所有操作；Parameters 对其中每个操作都生效。
+type PathItem struct {
+	Parameters []*Parameter `json:"parameters"`
+	Get        *Operation   `json:"get"`
+	Put        *Operation   `json:"put"`
+	Post       *Operation   `json:"post"`
+	Delete     *Operation   `json:"delete"`
+	Patch      *Operation   `json:"patch"`
+}
+
+// Operation 是一个 HTTP 方法上的接口；Parameters 已经合并了 path 级别的参数。
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// Parameter 描述一个 path / query / header 参数。
+// 扩展字段 x-error-code 指定校验失败时使用的错误码（例如 INVALID_PAGINATION），未指定时由调用方决定。
+type Parameter struct {
+	Ref       string  `json:"$ref"`
+	Name      string  `json:"name"`
+	In        string  `json:"in"`
+	Required  bool    `json:"required"`
+	Schema    *Schema `json:"schema"`
+	ErrorCode string  `json:"x-error-code"`
+}
+
+// RequestBody 描述请求体；扩展字段 x-name 是字段错误路径的前缀，例如数组请求体的错误形如 products[1].price。
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+	Name     string                `json:"x-name"`
+}
+
+// MediaType 是某种 Content-Type 的请求体结构。
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Parse 解析 OpenAPI 文档，并把其中的 $ref 指向 components 中的定义。
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+
+	for _, s := range doc.Components.Schemas {
+		if err := doc.resolveSchema(s); err != nil {
+			return nil, err
+		}
+	}
+	for template, item := range doc.Paths {
+		if err := doc.resolvePathItem(item); err != nil {
+			return nil, fmt.Errorf("%w (path %s)", err, template)
+		}
+		doc.templates = append(doc.templates, template)
+	}
+	sort.Slice(doc.templates, func(i, j int) bool {
+		a, b := literalSegments(doc.templates[i]), literalSegments(doc.templates[j])
+		if a != b {
+			return a > b
+		}
+		return doc.templates[i] < doc.templates[j]
+	})
+	return &doc, nil
+}
+
+// Find 按请求方法与路径查找操作，并返回路径参数；HEAD 按 GET 处理。没有匹配的操作时返回 nil。
+func (d *Document) Find(method, path string) (*Operation, map[string]string) {
+	if method == "HEAD" {
+		method = "GET"
+	}
+	for _, template := range d.templates {
+		params, ok := matchPath(template, path)
+		if !ok {
+			continue
+		}
+		op := d.Paths[template].operation(method)
+		if op == nil {
+			return nil, nil
+		}
+		return op, params
+	}
+	return nil, nil
+}
+
+func (item *PathItem) operation(method string) *Operation {
+	switch method {
+	case "GET":
+		return item.Get
+	case "PUT":
+		return item.Put
+	case "POST":
+		return item.Post
+	case "DELETE":
+		return item.Delete
+	case "PATCH":
+		return item.Patch
+	}
+	return nil
+}
+
+func (item *PathItem) operations() []*Operation {
+	var ops []*Operation
+	for _, op := range []*Operation{item.Get, item.Put, item.Post, item.Delete, item.Patch} {
+		if op != nil {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// resolvePathItem 解析参数引用，并把 path 级别的参数合并进每个操作（同名同位置时以操作自身的为准）。
+func (d *Document) resolvePathItem(item *PathItem) error {
+	shared, err := d.resolveParameters(item.Parameters)
+	if err != nil {
+		return err
+	}
+	for _, op := range item.operations() {
+		own, err := d.resolveParameters(op.Parameters)
+		if err != nil {
+			return err
+		}
+		merged := own
+		for _, p := range shared {
+			if !hasParameter(own, p) {
+				merged = append(merged, p)
+			}
+		}
+		op.Parameters = merged
+
+		if op.RequestBody != nil {
+			for _, media := range op.RequestBody.Content {
+				if err := d.resolveSchema(media.Schema); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Document) resolveParameters(params []*Parameter) ([]*Parameter, error) {
+	resolved := make([]*Parameter, 0, len(params))
+	for _, p := range params {
+		if p.Ref != "" {
+			target, ok := d.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+			if !ok || !strings.HasPrefix(p.Ref, "#/components/parameters/") {
+				return nil, fmt.Errorf("openapi: unresolved $ref %q", p.Ref)
+			}
+			p = target
+		}
+		if err := d.resolveSchema(p.Schema); err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, p)
+	}
+	return resolved, nil
+}
+
+func hasParameter(params []*Parameter, p *Parameter) bool {
+	for _, existing := range params {
+		if existing.Name == p.Name && existing.In == p.In {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSchema 递归地把 schema 中的 $ref 指向 components.schemas 中的定义。
+func (d *Document) resolveSchema(s *Schema) error {
+	if s == nil || s.resolved {
+		return nil
+	}
+	s.resolved = true
+
+	if s.Ref != "" {
+		target, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok || !strings.HasPrefix(s.Ref, "#/components/schemas/") {
+			return fmt.Errorf("openapi: unresolved $ref %q", s.Ref)
+		}
+		s.target = target
+		return d.resolveSchema(target)
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("openapi: invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+	for _, name := range s.Properties.names {
+		if err := d.resolveSchema(s.Properties.schemas[name]); err != nil {
+			return err
+		}
+	}
+	for _, sub := range s.AllOf {
+		if err := d.resolveSchema(sub); err != nil {
+			return err
+		}
+	}
+	return d.resolveSchema(s.Items)
+}
+
+// matchPath 按模板匹配路径，{name} 段匹配任意非空段。
+func matchPath(template, path string) (map[string]string, bool) {
+	tparts := strings.Split(strings.Trim(template, "/"), "/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(tparts) != len(parts) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, tp := range tparts {
+		if strings.HasPrefix(tp, "{") && strings.HasSuffix(tp, "}") {
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[tp[1:len(tp)-1]] = parts[i]
+		} else if tp != parts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func literalSegments(template string) int {
+	n := 0
+	for _, part := range strings.Split(strings.Trim(template, "/"), "/") {
+		if !strings.HasPrefix(part, "{") {
+			n++
+		}
+	}
+	return n
+}
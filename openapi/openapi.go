@@ -0,0 +1,10 @@
+// Package openapi 内嵌服务的 OpenAPI 3.1 文档（openapi.json）。
+// 新增或修改路由时需要同步更新该文档；tests 中的用例会检查 RegisterRoutes 注册的每个路由都出现在文档中。
+package openapi
+
+import _ "embed"
+
+// Spec 是 OpenAPI 文档的 JSON 内容。
+//
+//go:embed openapi.json
+var Spec []byte
@@ -0,0 +1,314 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang-starter/validation"
+)
+
+// Schema 是 JSON Schema 中请求校验用到的子集：
+// type、enum、const、数值范围、字符串长度与 pattern、对象的 properties / required / additionalProperties、
+// 数组的 items 与长度、allOf 以及指向 components.schemas 的 $ref。其余关键字（format 等）只用于文档，不参与校验。
+type Schema struct {
+	Ref                  string          `json:"$ref"`
+	Type                 schemaType      `json:"type"`
+	Enum                 []any           `json:"enum"`
+	Const                any             `json:"const"`
+	Minimum              *float64        `json:"minimum"`
+	Maximum              *float64        `json:"maximum"`
+	ExclusiveMinimum     *float64        `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64        `json:"exclusiveMaximum"`
+	MinLength            *int            `json:"minLength"`
+	MaxLength            *int            `json:"maxLength"`
+	Pattern              string          `json:"pattern"`
+	Required             []string        `json:"required"`
+	Properties           properties      `json:"properties"`
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	Items                *Schema         `json:"items"`
+	MinItems             *int            `json:"minItems"`
+	MaxItems             *int            `json:"maxItems"`
+	AllOf                []*Schema       `json:"allOf"`
+
+	resolved bool
+	target   *Schema
+	pattern  *regexp.Regexp
+}
+
+// schemaType 兼容 "type": "string" 与 "type": ["string", "null"] 两种写法。
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*t = multiple
+	return nil
+}
+
+// properties 保留属性在文档中的顺序，字段错误按这个顺序报告。
+type properties struct {
+	names   []string
+	schemas map[string]*Schema
+}
+
+func (p *properties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	p.schemas = map[string]*Schema{}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, _ := token.(string)
+		var s Schema
+		if err := dec.Decode(&s); err != nil {
+			return err
+		}
+		p.names = append(p.names, name)
+		p.schemas[name] = &s
+	}
+	return nil
+}
+
+// Validate 按 schema 校验 value（encoding/json 解码出的通用结构，数字为 json.Number），返回所有字段错误。
+// field 是 value 的字段路径，用于错误信息；错误的规则代码与 validate tag 保持一致（required、min、max、gt、oneof 等）。
+func (s *Schema) Validate(value any, field string) validation.Errors {
+	if s == nil {
+		return nil
+	}
+	if s.target != nil {
+		return s.target.Validate(value, field)
+	}
+
+	var errs validation.Errors
+	for _, sub := range s.AllOf {
+		errs = append(errs, sub.Validate(value, field)...)
+	}
+
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		return append(errs, validation.NewFieldError(field, "type", "validation.type", strings.Join(s.Type, " | ")))
+	}
+	if value == nil {
+		return errs
+	}
+
+	if len(s.Enum) > 0 && !containsValue(s.Enum, value) {
+		errs = append(errs, validation.NewFieldError(field, "oneof", "validation.oneof", joinValues(s.Enum)))
+	}
+	if s.Const != nil && !containsValue([]any{s.Const}, value) {
+		errs = append(errs, validation.NewFieldError(field, "oneof", "validation.oneof", joinValues([]any{s.Const})))
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		errs = append(errs, s.validateNumber(v, field)...)
+	case string:
+		errs = append(errs, s.validateString(v, field)...)
+	case []any:
+		errs = append(errs, s.validateArray(v, field)...)
+	case map[string]any:
+		errs = append(errs, s.validateObject(v, field)...)
+	}
+	return errs
+}
+
+// ParseParameter 把 path / query / header 参数的原始字符串按 schema 的类型转换为 Validate 接受的值：
+// integer / number 转为 json.Number，boolean 转为 bool；无法转换时原样返回字符串，由 Validate 报告类型错误。
+func (s *Schema) ParseParameter(raw string) any {
+	if s == nil {
+		return raw
+	}
+	if s.target != nil {
+		return s.target.ParseParameter(raw)
+	}
+	for _, t := range s.Type {
+		switch t {
+		case "integer", "number":
+			if _, err := strconv.ParseFloat(raw, 64); err == nil {
+				return json.Number(raw)
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(raw); err == nil {
+				return b
+			}
+		}
+	}
+	return raw
+}
+
+func (s *Schema) matchesType(value any) bool {
+	for _, t := range s.Type {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case json.Number:
+			if t == "number" {
+				return true
+			}
+			if t == "integer" {
+				f, err := v.Float64()
+				if err == nil && f == math.Trunc(f) {
+					return true
+				}
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case map[string]any:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Schema) validateNumber(n json.Number, field string) validation.Errors {
+	f, err := n.Float64()
+	if err != nil {
+		return validation.Errors{validation.NewFieldError(field, "type", "validation.type", "number")}
+	}
+
+	var errs validation.Errors
+	if s.Minimum != nil && f < *s.Minimum {
+		key := "validation.min"
+		if *s.Minimum == 0 {
+			key = "validation.min.nonnegative"
+		}
+		errs = append(errs, validation.NewFieldError(field, "min", key, formatNumber(*s.Minimum)))
+	}
+	if s.Maximum != nil && f > *s.Maximum {
+		errs = append(errs, validation.NewFieldError(field, "max", "validation.max", formatNumber(*s.Maximum)))
+	}
+	if s.ExclusiveMinimum != nil && f <= *s.ExclusiveMinimum {
+		errs = append(errs, validation.NewFieldError(field, "gt", "validation.gt", formatNumber(*s.ExclusiveMinimum)))
+	}
+	if s.ExclusiveMaximum != nil && f >= *s.ExclusiveMaximum {
+		errs = append(errs, validation.NewFieldError(field, "lt", "validation.lt", formatNumber(*s.ExclusiveMaximum)))
+	}
+	return errs
+}
+
+func (s *Schema) validateString(v string, field string) validation.Errors {
+	var errs validation.Errors
+	length := utf8.RuneCountInString(v)
+	if s.MinLength != nil && length < *s.MinLength {
+		// minLength: 1 与 validate:"required" 含义相同，按 required 报告。
+		if *s.MinLength == 1 {
+			return append(errs, validation.NewFieldError(field, "required", "validation.required", ""))
+		}
+		errs = append(errs, validation.NewFieldError(field, "min", "validation.min.length", strconv.Itoa(*s.MinLength)))
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		errs = append(errs, validation.NewFieldError(field, "max", "validation.max.length", strconv.Itoa(*s.MaxLength)))
+	}
+	if s.pattern != nil && !s.pattern.MatchString(v) {
+		errs = append(errs, validation.NewFieldError(field, "regex", "validation.regex", s.Pattern))
+	}
+	return errs
+}
+
+func (s *Schema) validateArray(items []any, field string) validation.Errors {
+	var errs validation.Errors
+	if s.MinItems != nil && len(items) < *s.MinItems {
+		errs = append(errs, validation.NewFieldError(field, "min", "validation.min.length", strconv.Itoa(*s.MinItems)))
+	}
+	if s.MaxItems != nil && len(items) > *s.MaxItems {
+		errs = append(errs, validation.NewFieldError(field, "max", "validation.max.length", strconv.Itoa(*s.MaxItems)))
+	}
+	for i, item := range items {
+		errs = append(errs, s.Items.Validate(item, fmt.Sprintf("%s[%d]", field, i))...)
+	}
+	return errs
+}
+
+func (s *Schema) validateObject(obj map[string]any, field string) validation.Errors {
+	var errs validation.Errors
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			errs = append(errs, validation.NewFieldError(joinField(field, name), "required", "validation.required", ""))
+		}
+	}
+	for _, name := range s.Properties.names {
+		if value, ok := obj[name]; ok {
+			errs = append(errs, s.Properties.schemas[name].Validate(value, joinField(field, name))...)
+		}
+	}
+	if string(s.AdditionalProperties) == "false" {
+		unknown := make([]string, 0)
+		for name := range obj {
+			if _, ok := s.Properties.schemas[name]; !ok {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		for _, name := range unknown {
+			errs = append(errs, validation.NewFieldError(joinField(field, name), "unknown", "validation.unknown", ""))
+		}
+	}
+	return errs
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func containsValue(candidates []any, value any) bool {
+	for _, candidate := range candidates {
+		if n, ok := value.(json.Number); ok {
+			if c, ok := candidate.(float64); ok {
+				if f, err := n.Float64(); err == nil && f == c {
+					return true
+				}
+			}
+			continue
+		}
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinValues(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
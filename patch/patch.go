@@ -0,0 +1,373 @@
+// Package patch 实现两种 JSON 文档修补格式：
+// - RFC 7396 JSON Merge Patch（application/merge-patch+json）
+// - RFC 6902 JSON Patch（application/json-patch+json）
+// 两者都作用在通用的 JSON 值（map[string]any / []any / json.Number / string / bool / nil）上，
+// 与具体的业务结构体无关。
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPatch 表示补丁文档本身不合法（不是合法 JSON、缺少字段、op 不认识等）。
+var ErrInvalidPatch = errors.New("invalid patch document")
+
+// ErrCannotApply 表示补丁格式合法，但无法应用到目标文档上（路径不存在、下标越界等）。
+var ErrCannotApply = errors.New("patch cannot be applied")
+
+// ErrTestFailed 表示 JSON Patch 中的 test 操作未通过。
+var ErrTestFailed = errors.New("patch test operation failed")
+
+// Decode 把 JSON 解码为通用值；数字保留为 json.Number，避免大整数丢失精度。
+func Decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after top-level value")
+	}
+	return v, nil
+}
+
+// MergePatch 按 RFC 7396 把 patch 合并到 target 上：
+// patch 中为 null 的成员表示删除，对象递归合并，其余值整体替换。
+func MergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	} else {
+		// 复制一份，避免修改调用方的原始文档。
+		copied := make(map[string]any, len(targetObj))
+		for k, v := range targetObj {
+			copied[k] = v
+		}
+		targetObj = copied
+	}
+
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = MergePatch(targetObj[k], v)
+	}
+	return targetObj
+}
+
+// Operation 是 JSON Patch 中的一条操作。
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// DecodeOperations 解析 JSON Patch 文档（一个操作数组）。
+func DecodeOperations(data []byte) ([]Operation, error) {
+	var ops []Operation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: operation %d (%s) requires value", ErrInvalidPatch, i, op.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: operation %d has unknown op %q", ErrInvalidPatch, i, op.Op)
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+	}
+	return ops, nil
+}
+
+// ApplyOperations 按顺序应用 JSON Patch 操作；任一操作失败则整体失败，doc 不会被修改。
+func ApplyOperations(doc any, ops []Operation) (any, error) {
+	doc = deepCopy(doc)
+	for i, op := range ops {
+		var err error
+		doc, err = applyOperation(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyOperation(doc any, op Operation) (any, error) {
+	path, _ := parsePointer(op.Path)
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := Decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			// 替换根（path 为 ""）即用 value 替换整个文档；remove 不能删除根。
+			if len(path) == 0 {
+				return value, nil
+			}
+			if _, err := get(doc, path); err != nil {
+				return nil, err
+			}
+			doc, err = remove(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, value)
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !Equal(current, value) {
+				return nil, ErrTestFailed
+			}
+			return doc, nil
+		}
+	case "remove":
+		return remove(doc, path)
+	case "move", "copy":
+		from, _ := parsePointer(op.From)
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			// 不能把一个位置移动到它自己的子路径下。
+			if len(path) > len(from) && strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, fmt.Errorf("%w: cannot move a value into itself", ErrCannotApply)
+			}
+			if doc, err = remove(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopy(value)
+		}
+		return add(doc, path, value)
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+}
+
+// parsePointer 按 RFC 6901 解析 JSON Pointer，返回已反转义（~1 → /，~0 → ~）的各段。
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	parts := strings.Split(pointer[1:], "/")
+	for i, p := range parts {
+		parts[i] = strings.ReplaceAll(strings.ReplaceAll(p, "~1", "/"), "~0", "~")
+	}
+	return parts, nil
+}
+
+// arrayIndex 解析数组下标；allowEnd 为 true 时允许 "-" 或 len（表示追加到末尾）。
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrCannotApply, token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrCannotApply, token)
+	}
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if idx > max {
+		return 0, fmt.Errorf("%w: array index %d out of range", ErrCannotApply, idx)
+	}
+	return idx, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	current := doc
+	for _, token := range path {
+		switch node := current.(type) {
+		case map[string]any:
+			v, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: path not found", ErrCannotApply)
+			}
+			current = v
+		case []any:
+			idx, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[idx]
+		default:
+			return nil, fmt.Errorf("%w: path not found", ErrCannotApply)
+		}
+	}
+	return current, nil
+}
+
+// add 在 path 位置写入 value，返回新的根文档（path 为空时直接替换整个文档）。
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+		return doc, nil
+	case []any:
+		idx, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		updated := append(node[:idx:idx], append([]any{value}, node[idx:]...)...)
+		return replaceAt(doc, path[:len(path)-1], updated)
+	}
+	return nil, fmt.Errorf("%w: parent is not a container", ErrCannotApply)
+}
+
+// remove 删除 path 位置的值，返回新的根文档。
+func remove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrCannotApply)
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		if _, ok := node[last]; !ok {
+			return nil, fmt.Errorf("%w: path not found", ErrCannotApply)
+		}
+		delete(node, last)
+		return doc, nil
+	case []any:
+		idx, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		updated := append(node[:idx:idx], node[idx+1:]...)
+		return replaceAt(doc, path[:len(path)-1], updated)
+	}
+	return nil, fmt.Errorf("%w: parent is not a container", ErrCannotApply)
+}
+
+// replaceAt 把 path 位置的值整体替换为 value（用于数组长度变化后回写）。
+func replaceAt(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+	case []any:
+		idx, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = value
+	}
+	return doc, nil
+}
+
+func deepCopy(v any) any {
+	switch node := v.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(node))
+		for k, item := range node {
+			copied[k] = deepCopy(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(node))
+		for i, item := range node {
+			copied[i] = deepCopy(item)
+		}
+		return copied
+	}
+	return v
+}
+
+// Equal 按 JSON 语义比较两个值：数字按数值比较（10 与 10.0 相等），对象不关心成员顺序。
+func Equal(a, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !Equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !Equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		xf, err1 := x.Float64()
+		yf, err2 := y.Float64()
+		return err1 == nil && err2 == nil && xf == yf
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,111 @@
+// Package ratelimit 实现按客户端分桶的令牌桶限流。
+//
+// 每个客户端（key）一个桶：桶容量为 Burst，每秒补充 Rate 个令牌；请求按开销扣除令牌，令牌不足时拒绝。
+// 桶只保存在内存中，进程重启后所有客户端的额度都会重置。
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter 是一组按 key 区分的令牌桶，可以并发使用。
+type Limiter struct {
+	// Rate：每秒补充的令牌数。
+	Rate float64
+	// Burst：桶容量，即短时间内最多可以连续消耗的令牌数。
+	Burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Decision 是一次限流判断的结果，用于填写响应头。
+type Decision struct {
+	Allowed bool
+	// Limit：桶容量。
+	Limit int
+	// Remaining：扣除本次开销后剩余的令牌数（向下取整）。
+	Remaining int
+	// RetryAfter：被拒绝时，需要等待多久才有足够的令牌。
+	RetryAfter time.Duration
+	// Reset：桶重新补满需要的时间。
+	Reset time.Duration
+}
+
+// sweepInterval：清理空闲桶的间隔；补满后的桶与新建的桶等价，可以直接丢弃。
+const sweepInterval = time.Minute
+
+// New 创建限流器。
+func New(rate, burst float64) *Limiter {
+	return &Limiter{
+		Rate:    rate,
+		Burst:   burst,
+		buckets: map[string]*bucket{},
+	}
+}
+
+// Allow 尝试从 key 的桶中扣除 cost 个令牌。
+// cost 大于桶容量时按桶容量计算，否则这类请求永远不会被放行。
+func (l *Limiter) Allow(key string, cost float64) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	if cost > l.Burst {
+		cost = l.Burst
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.Burst, last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.Burst, b.tokens+elapsed*l.Rate)
+	}
+	b.last = now
+
+	d := Decision{Limit: int(l.Burst)}
+	if b.tokens >= cost {
+		b.tokens -= cost
+		d.Allowed = true
+	} else {
+		d.RetryAfter = l.duration(cost - b.tokens)
+	}
+	d.Remaining = int(math.Floor(b.tokens))
+	d.Reset = l.duration(l.Burst - b.tokens)
+	return d
+}
+
+// duration 返回补充 tokens 个令牌需要的时间。
+func (l *Limiter) duration(tokens float64) time.Duration {
+	if tokens <= 0 || l.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// sweep 定期删除已经补满的桶，避免大量一次性客户端（例如扫描器的 IP）让内存无限增长。
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	full := l.duration(l.Burst)
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/handlers"
+	"golang-starter/utils"
+)
+
+// setupAuth 打开认证并清空密钥表；返回的函数恢复为关闭认证。
+func setupAuth(t *testing.T) func() {
+	t.Helper()
+
+	teardown := setupTestDB()
+	if _, err := utils.DB.Exec("DELETE FROM api_keys"); err != nil {
+		t.Fatalf("clear api_keys failed: %v", err)
+	}
+	handlers.AuthEnabled = true
+	return func() {
+		handlers.AuthEnabled = false
+		teardown()
+	}
+}
+
+// createTestKey 创建一把不绑定租户的密钥；admin 密钥是平台级的，可以访问任意租户与管理接口。
+func createTestKey(t *testing.T, role auth.Role) string {
+	t.Helper()
+
+	_, plaintext, err := auth.CreateKey(context.Background(), utils.DB, string(role)+" test key", role, "", role == auth.Admin)
+	if err != nil {
+		t.Fatalf("create key failed: %v", err)
+	}
+	return plaintext
+}
+
+func requestWithKey(method, target, key, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	return req
+}
+
+type apiKeyBody struct {
+	Data struct {
+		ID       int    `json:"id"`
+		Role     string `json:"role"`
+		Prefix   string `json:"prefix"`
+		Platform bool   `json:"platform"`
+		Key      string `json:"key"`
+	} `json:"data"`
+}
+
+func decodeAPIKey(t *testing.T, w *httptest.ResponseRecorder, status int) apiKeyBody {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d. Body: %s", status, w.Code, w.Body.String())
+	}
+	var body apiKeyBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode json: %v", err)
+	}
+	return body
+}
+
+func TestAuthRequired(t *testing.T) {
+	defer setupAuth(t)()
+
+	w := serve(httptest.NewRequest("GET", "/api/products", nil))
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected WWW-Authenticate header")
+	}
+	expectError(t, w, http.StatusUnauthorized, apierror.Unauthorized)
+
+	expectError(t, serve(requestWithKey("GET", "/api/products", auth.KeyPrefix+"not-a-real-key", "")), http.StatusUnauthorized, apierror.Unauthorized)
+
+	// 健康检查不需要认证。
+	if w := serve(httptest.NewRequest("GET", "/api/health", nil)); w.Code != http.StatusOK {
+		t.Fatalf("expected health check to be public, got %d", w.Code)
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	defer setupAuth(t)()
+
+	viewer := createTestKey(t, auth.Viewer)
+	editor := createTestKey(t, auth.Editor)
+	product := `{"name":"Auth Product","price":1,"stock":1}`
+
+	if w := serve(requestWithKey("GET", "/api/products", viewer, "")); w.Code != http.StatusOK {
+		t.Fatalf("viewer should be able to list products, got %d", w.Code)
+	}
+	expectError(t, serve(requestWithKey("POST", "/api/products", viewer, product)), http.StatusForbidden, apierror.Forbidden)
+	expectError(t, serve(requestWithKey("DELETE", "/api/products/1", viewer, "")), http.StatusForbidden, apierror.Forbidden)
+
+	// Authorization: Bearer 与 X-API-Key 等价。
+	req := httptest.NewRequest("POST", "/api/products", strings.NewReader(product))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+editor)
+	if w := serve(req); w.Code != http.StatusCreated {
+		t.Fatalf("editor should be able to create products, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	expectError(t, serve(requestWithKey("GET", "/api/admin/keys", editor, "")), http.StatusForbidden, apierror.Forbidden)
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	defer setupAuth(t)()
+
+	admin := createTestKey(t, auth.Admin)
+
+	created := decodeAPIKey(t, serve(requestWithKey("POST", "/api/admin/keys", admin, `{"name":"ci","role":"editor"}`)), http.StatusCreated)
+	if created.Data.Role != "editor" || !strings.HasPrefix(created.Data.Key, auth.KeyPrefix) || !strings.HasPrefix(created.Data.Key, created.Data.Prefix) {
+		t.Fatalf("unexpected created key: %+v", created.Data)
+	}
+	oldKey := created.Data.Key
+	if w := serve(requestWithKey("GET", "/api/products", oldKey, "")); w.Code != http.StatusOK {
+		t.Fatalf("new key should work, got %d", w.Code)
+	}
+
+	// 列表中不包含明文与哈希。
+	w := serve(requestWithKey("GET", "/api/admin/keys", admin, ""))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), oldKey) || strings.Contains(w.Body.String(), auth.HashKey(oldKey)) {
+		t.Fatalf("list must not expose secrets: %d %s", w.Code, w.Body.String())
+	}
+
+	// 轮换：旧明文立即失效，新明文可用。
+	rotated := decodeAPIKey(t, serve(requestWithKey("POST", fmt.Sprintf("/api/admin/keys/%d/rotate", created.Data.ID), admin, "")), http.StatusOK)
+	if rotated.Data.Key == oldKey {
+		t.Fatalf("rotate should issue a new key")
+	}
+	expectError(t, serve(requestWithKey("GET", "/api/products", oldKey, "")), http.StatusUnauthorized, apierror.Unauthorized)
+	if w := serve(requestWithKey("GET", "/api/products", rotated.Data.Key, "")); w.Code != http.StatusOK {
+		t.Fatalf("rotated key should work, got %d", w.Code)
+	}
+
+	// 吊销：密钥失效，且不能再轮换。
+	if w := serve(requestWithKey("DELETE", fmt.Sprintf("/api/admin/keys/%d", created.Data.ID), admin, "")); w.Code != http.StatusOK {
+		t.Fatalf("expected revoke to succeed, got %d", w.Code)
+	}
+	expectError(t, serve(requestWithKey("GET", "/api/products", rotated.Data.Key, "")), http.StatusUnauthorized, apierror.Unauthorized)
+	expectError(t, serve(requestWithKey("POST", fmt.Sprintf("/api/admin/keys/%d/rotate", created.Data.ID), admin, "")), http.StatusConflict, apierror.APIKeyRevoked)
+
+	expectError(t, serve(requestWithKey("DELETE", "/api/admin/keys/99999999", admin, "")), http.StatusNotFound, apierror.APIKeyNotFound)
+	expectError(t, serve(requestWithKey("POST", "/api/admin/keys", admin, `{"name":"x","role":"root"}`)), http.StatusUnprocessableEntity, apierror.ValidationFailed)
+}
+
+func TestEnsureAdminKey(t *testing.T) {
+	defer setupAuth(t)()
+
+	plaintext, err := auth.EnsureAdminKey(context.Background(), utils.DB, "")
+	if err != nil || !strings.HasPrefix(plaintext, auth.KeyPrefix) {
+		t.Fatalf("expected a generated admin key, got %q, %v", plaintext, err)
+	}
+	if w := serve(requestWithKey("GET", "/api/admin/keys", plaintext, "")); w.Code != http.StatusOK {
+		t.Fatalf("bootstrap key should be admin, got %d", w.Code)
+	}
+
+	// 已有 admin 密钥时不再创建。
+	again, err := auth.EnsureAdminKey(context.Background(), utils.DB, "")
+	if err != nil || again != "" {
+		t.Fatalf("expected no new key, got %q, %v", again, err)
+	}
+}
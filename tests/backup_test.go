@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/backup"
+	"golang-starter/handlers"
+	"golang-starter/utils"
+)
+
+func countProducts(t *testing.T, path string) int {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM products`).Scan(&n); err != nil {
+		t.Fatalf("count products in %s: %v", path, err)
+	}
+	return n
+}
+
+func TestBackupCreateVerifyRestore(t *testing.T) {
+	defer setupTestDB()()
+	seedProducts(t, 3)
+	dir := t.TempDir()
+
+	for _, name := range []string{"plain.db", "compressed.db.gz"} {
+		dest := filepath.Join(dir, name)
+		info, err := backup.Create(context.Background(), utils.DB, dest)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if info.Compressed != strings.HasSuffix(name, ".gz") || info.SchemaVersion != utils.SchemaVersion || len(info.SHA256) != 64 {
+			t.Fatalf("unexpected info for %s: %+v", name, info)
+		}
+		sidecar, err := os.ReadFile(dest + backup.ChecksumSuffix)
+		if err != nil || string(sidecar) != info.SHA256+"  "+name+"\n" {
+			t.Fatalf("unexpected checksum file for %s: %q (%v)", name, sidecar, err)
+		}
+		if _, err := backup.Create(context.Background(), utils.DB, dest); err == nil {
+			t.Fatalf("create must not overwrite %s", name)
+		}
+
+		verified, err := backup.Verify(dest, true)
+		if err != nil || !verified.Verified || verified.SHA256 != info.SHA256 {
+			t.Fatalf("verify %s: %+v %v", name, verified, err)
+		}
+
+		// 恢复到新的位置：压缩的备份会被解压。
+		restored := filepath.Join(dir, "restored-"+strings.TrimSuffix(name, ".gz"))
+		if _, err := backup.Restore(dest, restored, true); err != nil {
+			t.Fatalf("restore %s: %v", name, err)
+		}
+		if n := countProducts(t, restored); n != 3 {
+			t.Fatalf("expected 3 products restored from %s, got %d", name, n)
+		}
+	}
+}
+
+func TestBackupRestoreRejectsBadBackups(t *testing.T) {
+	defer setupTestDB()()
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "products.db")
+	if err := os.WriteFile(dest, []byte("current database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 备份被修改后与校验文件不一致。
+	tampered := filepath.Join(dir, "tampered.db")
+	if _, err := backup.Create(context.Background(), utils.DB, tampered); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(tampered, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0})
+	f.Close()
+	if _, err := backup.Restore(tampered, dest, false); !errors.Is(err, backup.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	// 没有校验文件：默认拒绝恢复。
+	missing := filepath.Join(dir, "missing.db")
+	if _, err := backup.Create(context.Background(), utils.DB, missing); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(missing + backup.ChecksumSuffix); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backup.Verify(missing, true); !errors.Is(err, backup.ErrChecksumMissing) {
+		t.Fatalf("expected missing checksum on verify, got %v", err)
+	}
+	if _, err := backup.Restore(missing, dest, true); !errors.Is(err, backup.ErrChecksumMissing) {
+		t.Fatalf("expected missing checksum, got %v", err)
+	}
+
+	// 以下备份都没有校验文件，不要求校验，检查的是其他规则。
+	// 由更新版本的程序创建的数据库（schema 版本更高）。
+	newer := filepath.Join(dir, "newer.db")
+	db, err := sql.Open("sqlite3", newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE products (id INTEGER PRIMARY KEY); PRAGMA user_version = 999`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backup.Restore(newer, dest, false); err == nil || !strings.Contains(err.Error(), "schema version 999") {
+		t.Fatalf("expected schema version error, got %v", err)
+	}
+
+	// 不是 gzip 文件。
+	notGzip := filepath.Join(dir, "broken.db.gz")
+	os.WriteFile(notGzip, []byte("not gzip"), 0o644)
+	if _, err := backup.Restore(notGzip, dest, false); err == nil {
+		t.Fatal("expected error for a broken gzip backup")
+	}
+
+	// 校验失败时 dest 保持不变。
+	if data, _ := os.ReadFile(dest); string(data) != "current database" {
+		t.Fatalf("dest must not change when restore fails, got %q", data)
+	}
+}
+
+func TestBackupSchedulerRetention(t *testing.T) {
+	defer setupTestDB()()
+	s := &backup.Scheduler{Dir: filepath.Join(t.TempDir(), "backups"), Keep: 2, Compress: true}
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		info, err := s.Snapshot(context.Background(), utils.DB)
+		if err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+		names = append(names, info.Name)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	backups, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].Name != names[2] || backups[1].Name != names[1] || !backups[0].Compressed || backups[0].SHA256 == "" {
+		t.Fatalf("expected the 2 newest backups, got %+v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(s.Dir, names[0]+backup.ChecksumSuffix)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("checksum file of a pruned backup should be removed: %v", err)
+	}
+}
+
+func TestAdminBackupsAPI(t *testing.T) {
+	defer setupAuth(t)()
+	admin := createTestKey(t, auth.Admin)
+
+	handlers.Backups = nil
+	expectError(t, serve(requestWithKey("POST", "/api/admin/backups", admin, "")), http.StatusConflict, apierror.BackupNotConfigured)
+
+	handlers.Backups = &backup.Scheduler{Dir: t.TempDir(), Keep: 5}
+	defer func() { handlers.Backups = nil }()
+
+	expectError(t, serve(requestWithKey("POST", "/api/admin/backups", createTestKey(t, auth.Editor), "")), http.StatusForbidden, apierror.Forbidden)
+
+	w := serve(requestWithKey("POST", "/api/admin/backups", admin, ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Data backup.Info `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.Data.Name == "" || created.Data.SHA256 == "" {
+		t.Fatalf("unexpected backup: %s", w.Body.String())
+	}
+
+	w = serve(requestWithKey("GET", "/api/admin/backups", admin, ""))
+	var list struct {
+		Data []backup.Info `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Data) != 1 || list.Data[0].SHA256 != created.Data.SHA256 {
+		t.Fatalf("unexpected backup list: %s", w.Body.String())
+	}
+	if _, err := backup.Verify(filepath.Join(handlers.Backups.Dir, created.Data.Name), true); err != nil {
+		t.Fatalf("backup written by the API should verify: %v", err)
+	}
+}
+
+// TestBackupRestoreWithoutChecksum 显式不要求校验时，没有校验文件的备份也可以恢复，但不算已校验。
+func TestBackupRestoreWithoutChecksum(t *testing.T) {
+	defer setupTestDB()()
+	seedProducts(t, 2)
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "unchecked.db.gz")
+	if _, err := backup.Create(context.Background(), utils.DB, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(src + backup.ChecksumSuffix); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := filepath.Join(dir, "restored.db")
+	info, err := backup.Restore(src, restored, false)
+	if err != nil || info.Verified {
+		t.Fatalf("expected an unverified restore, got %+v %v", info, err)
+	}
+	if n := countProducts(t, restored); n != 2 {
+		t.Fatalf("expected 2 products restored, got %d", n)
+	}
+}
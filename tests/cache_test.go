@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-starter/cache"
+	"golang-starter/handlers"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+func TestLRUEvictionAndTTL(t *testing.T) {
+	c := cache.New[string, int](2, 50*time.Millisecond)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a=1, got %d (%v)", v, ok)
+	}
+	// b 是最久没有被访问的条目。
+	c.Set("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected a to expire")
+	}
+
+	stats := c.Stats()
+	if stats.Entries != 1 || stats.Hits != 1 || stats.Misses != 2 || stats.Evictions != 1 || stats.Expirations != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+// getCached 发送 GET 请求并返回响应与 X-Cache 头。
+func getCached(t *testing.T, target, tenant string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	w := serve(tenantRequest("GET", target, tenant, ""))
+	return w, w.Header().Get("X-Cache")
+}
+
+func TestProductCacheInvalidation(t *testing.T) {
+	defer setupTestDB()()
+
+	ids := []int{createTenantProduct(t, "", "First"), createTenantProduct(t, "", "Second"), createTenantProduct(t, "", "Third")}
+	product := fmt.Sprintf("/api/products/%d", ids[0])
+	firstPage := "/api/products?limit=2"
+	lastPage := "/api/products?limit=1&offset=2"
+
+	for _, target := range []string{product, firstPage, lastPage} {
+		if w, cached := getCached(t, target, ""); w.Code != http.StatusOK || cached != "MISS" {
+			t.Fatalf("first GET %s: expected 200 MISS, got %d %q", target, w.Code, cached)
+		}
+		w, cached := getCached(t, target, "")
+		if cached != "HIT" {
+			t.Fatalf("second GET %s: expected HIT, got %q", target, cached)
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "private, no-cache" {
+			t.Fatalf("unexpected Cache-Control for %s: %q", target, cc)
+		}
+		if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), handlers.TenantHeader) {
+			t.Fatalf("expected Vary to include %s, got %v", handlers.TenantHeader, w.Header().Values("Vary"))
+		}
+	}
+
+	// 其他租户不会读到缓存中的产品。
+	if w, _ := getCached(t, product, "acme"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another tenant, got %d", w.Code)
+	}
+
+	// PATCH：该产品与包含它的列表页失效，不包含它的列表页仍然命中。
+	w := patchProduct(ids[0], "application/merge-patch+json", `{"stock":42}`)
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected 200 with Cache-Control: no-store, got %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	w, cached := getCached(t, product, "")
+	if cached != "MISS" || !strings.Contains(w.Body.String(), `"stock":42`) {
+		t.Fatalf("expected a fresh product after PATCH, got %q %s", cached, w.Body.String())
+	}
+	if w, cached := getCached(t, firstPage, ""); cached != "MISS" || !strings.Contains(w.Body.String(), `"stock":42`) {
+		t.Fatalf("expected the page containing the product to be refreshed, got %q %s", cached, w.Body.String())
+	}
+	if _, cached := getCached(t, lastPage, ""); cached != "HIT" {
+		t.Fatalf("a page without the product should stay cached, got %q", cached)
+	}
+
+	// PUT 与 PATCH 相同。
+	if w := serve(tenantRequest("PUT", product, "", `{"name":"Replaced","price":2,"stock":1}`)); w.Code != http.StatusOK {
+		t.Fatalf("PUT failed: %d %s", w.Code, w.Body.String())
+	}
+	if w, cached := getCached(t, product, ""); cached != "MISS" || !strings.Contains(w.Body.String(), "Replaced") {
+		t.Fatalf("expected a fresh product after PUT, got %q %s", cached, w.Body.String())
+	}
+
+	// 创建与批量创建：租户的所有列表页失效。
+	getCached(t, lastPage, "")
+	createTenantProduct(t, "", "Fourth")
+	if _, cached := getCached(t, lastPage, ""); cached != "MISS" {
+		t.Fatalf("expected list pages to be invalidated by a create, got %q", cached)
+	}
+	if w := serve(tenantRequest("POST", "/api/products/bulk", "", `[{"name":"Bulk","price":1}]`)); w.Code != http.StatusCreated {
+		t.Fatalf("bulk create failed: %d %s", w.Code, w.Body.String())
+	}
+	if _, cached := getCached(t, lastPage, ""); cached != "MISS" {
+		t.Fatalf("expected list pages to be invalidated by a bulk create, got %q", cached)
+	}
+
+	// 删除：产品与所有列表页失效。
+	if w := serve(tenantRequest("DELETE", product, "", "")); w.Code != http.StatusOK {
+		t.Fatalf("DELETE failed: %d %s", w.Code, w.Body.String())
+	}
+	if w, _ := getCached(t, product, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after DELETE, got %d", w.Code)
+	}
+	if w, cached := getCached(t, firstPage, ""); cached != "MISS" || strings.Contains(w.Body.String(), "Replaced") {
+		t.Fatalf("expected the deleted product to disappear from the list, got %q %s", cached, w.Body.String())
+	}
+}
+
+// TestProductCacheAdmin 直接写数据库（绕过服务）时缓存仍返回旧数据，清空缓存后读到新数据；统计接口反映命中情况。
+func TestProductCacheAdmin(t *testing.T) {
+	defer setupTestDB()()
+
+	id := createTenantProduct(t, "", "Cached")
+	target := fmt.Sprintf("/api/products/%d", id)
+	getCached(t, target, "")
+	getCached(t, target, "")
+
+	if _, err := models.UpdateLocalProduct(context.Background(), utils.DB, models.DefaultTenant, id, []string{"name"}, models.Product{Name: "Changed"}); err != nil {
+		t.Fatal(err)
+	}
+	if w, cached := getCached(t, target, ""); cached != "HIT" || !strings.Contains(w.Body.String(), `"Cached"`) {
+		t.Fatalf("expected the stale cached product, got %q %s", cached, w.Body.String())
+	}
+
+	var stats struct {
+		Data struct {
+			Enabled  bool        `json:"enabled"`
+			Products cache.Stats `json:"products"`
+		} `json:"data"`
+	}
+	w := serve(tenantRequest("GET", "/api/admin/cache", "", ""))
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &stats) != nil {
+		t.Fatalf("unexpected stats response: %d %s", w.Code, w.Body.String())
+	}
+	if !stats.Data.Enabled || stats.Data.Products.Hits < 2 || stats.Data.Products.Entries != 1 || stats.Data.Products.HitRatio <= 0 {
+		t.Fatalf("unexpected product cache stats: %+v", stats.Data)
+	}
+
+	w = serve(tenantRequest("DELETE", "/api/admin/cache", "", ""))
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &stats) != nil || stats.Data.Products.Entries != 0 {
+		t.Fatalf("expected an empty cache after purge: %d %s", w.Code, w.Body.String())
+	}
+	if w, cached := getCached(t, target, ""); cached != "MISS" || !strings.Contains(w.Body.String(), `"Changed"`) {
+		t.Fatalf("expected the fresh product after purge, got %q %s", cached, w.Body.String())
+	}
+
+	// 关闭缓存后每次都查询数据库，不输出 X-Cache；max-age 按配置输出。
+	defer func(c *handlers.ProductCacheStore, maxAge time.Duration) {
+		handlers.ProductCache, handlers.ProductCacheMaxAge = c, maxAge
+	}(handlers.ProductCache, handlers.ProductCacheMaxAge)
+	handlers.ProductCache, handlers.ProductCacheMaxAge = nil, 5*time.Second
+	w, cached := getCached(t, target, "")
+	if cached != "" || w.Header().Get("Cache-Control") != "private, max-age=5" {
+		t.Fatalf("unexpected headers with the cache disabled: X-Cache=%q Cache-Control=%q", cached, w.Header().Get("Cache-Control"))
+	}
+	if w := serve(tenantRequest("GET", "/api/admin/cache", "", "")); !strings.Contains(w.Body.String(), `"enabled":false`) {
+		t.Fatalf("expected enabled=false, got %s", w.Body.String())
+	}
+}
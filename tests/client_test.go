@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/client"
+	"golang-starter/handlers"
+	"golang-starter/models"
+)
+
+// newTestServer 启动一个挂载了全部路由的 httptest 服务；wrap 不为 nil 时用它包装路由，用于注入故障。
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux)
+	var h http.Handler = mux
+	if wrap != nil {
+		h = wrap(mux)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestClient(baseURL, apiKey string) *client.Client {
+	c := client.New(baseURL, apiKey)
+	c.Backoff = time.Millisecond
+	return c
+}
+
+func TestClientProductLifecycle(t *testing.T) {
+	defer setupTestDB()()
+	c := newTestClient(newTestServer(t, nil).URL, "")
+	ctx := context.Background()
+
+	created, err := c.CreateProduct(ctx, models.Product{Name: "Keyboard", Price: 49.9, Stock: 5})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.ID == 0 || created.Name != "Keyboard" || created.CreatedAt.IsZero() {
+		t.Fatalf("unexpected created product: %+v", created)
+	}
+
+	bulk, err := c.BulkCreate(ctx, []models.Product{{Name: "Mouse", Price: 19.9, Stock: 10}, {Name: "Monitor", Price: 199, Stock: 2}})
+	if err != nil || len(bulk) != 2 {
+		t.Fatalf("bulk create: %v %+v", err, bulk)
+	}
+
+	got, err := c.GetProduct(ctx, created.ID)
+	if err != nil || got.Name != "Keyboard" {
+		t.Fatalf("get: %v %+v", err, got)
+	}
+
+	list, err := c.ListProducts(ctx, client.ListParams{Limit: 2, Order: "id_desc"})
+	if err != nil || len(list) != 2 || list[0].Name != "Monitor" {
+		t.Fatalf("list: %v %+v", err, list)
+	}
+
+	found, err := c.SearchProducts(ctx, "Mo")
+	if err != nil || len(found) != 2 {
+		t.Fatalf("search: %v %+v", err, found)
+	}
+
+	// merge-patch 只修改传入的字段，0 也是有效值。
+	stock := 0
+	patched, err := c.PatchProduct(ctx, created.ID, client.ProductPatch{Stock: &stock})
+	if err != nil || patched.Stock != 0 || patched.Price != 49.9 {
+		t.Fatalf("patch: %v %+v", err, patched)
+	}
+
+	updated, err := c.UpdateProduct(ctx, created.ID, models.Product{Name: "Keyboard Pro", Price: 79, Stock: 1})
+	if err != nil || updated.Name != "Keyboard Pro" {
+		t.Fatalf("update: %v %+v", err, updated)
+	}
+
+	if err := c.DeleteProduct(ctx, created.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	_, err = c.GetProduct(ctx, created.ID)
+	var apiErr *client.Error
+	if !errors.Is(err, client.ErrNotFound) || !errors.As(err, &apiErr) || apiErr.Code != apierror.ProductNotFound {
+		t.Fatalf("expected PRODUCT_NOT_FOUND, got %v", err)
+	}
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	defer setupTestDB()()
+	c := newTestClient(newTestServer(t, nil).URL, "")
+	ctx := context.Background()
+
+	_, err := c.ListProducts(ctx, client.ListParams{Limit: 101})
+	var apiErr *client.Error
+	if !errors.Is(err, client.ErrBadRequest) || !errors.As(err, &apiErr) || apiErr.Code != apierror.InvalidPagination {
+		t.Fatalf("expected INVALID_PAGINATION, got %v", err)
+	}
+
+	_, err = c.CreateProduct(ctx, models.Product{Name: "", Price: 0})
+	if !errors.Is(err, client.ErrValidation) || !errors.As(err, &apiErr) || len(apiErr.Fields) != 2 {
+		t.Fatalf("expected validation error with 2 fields, got %v", err)
+	}
+	if errors.Is(err, client.ErrNotFound) {
+		t.Fatal("validation error must not match ErrNotFound")
+	}
+}
+
+func TestClientAuthAndTenant(t *testing.T) {
+	defer setupAuth(t)()
+	srv := newTestServer(t, nil)
+	ctx := context.Background()
+
+	_, err := newTestClient(srv.URL, "").ListProducts(ctx, client.ListParams{})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != apierror.Unauthorized {
+		t.Fatalf("expected 401, got %v", err)
+	}
+
+	// 平台级密钥通过 Tenant 选择租户。
+	admin := newTestClient(srv.URL, createTestKey(t, auth.Admin))
+	admin.Tenant = "acme"
+	if _, err := admin.CreateProduct(ctx, models.Product{Name: "Acme Widget", Price: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if list, err := admin.ListProducts(ctx, client.ListParams{}); err != nil || len(list) != 1 {
+		t.Fatalf("expected 1 product in tenant acme: %v %+v", err, list)
+	}
+	admin.Tenant = ""
+	if list, err := admin.ListProducts(ctx, client.ListParams{}); err != nil || len(list) != 0 {
+		t.Fatalf("expected no products in default tenant: %v %+v", err, list)
+	}
+}
+
+// flaky 让前 failures 个请求返回 503，并记录每个请求的方法与 Idempotency-Key。
+type flaky struct {
+	mu       sync.Mutex
+	failures int
+	seen     []string
+}
+
+func (f *flaky) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.seen = append(f.seen, r.Method+" "+r.Header.Get("Idempotency-Key"))
+		fail := f.failures > 0
+		if fail {
+			f.failures--
+		}
+		f.mu.Unlock()
+
+		if fail {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestClientRetriesIdempotentRequests(t *testing.T) {
+	defer setupTestDB()()
+	f := &flaky{failures: 2}
+	c := newTestClient(newTestServer(t, f.wrap).URL, "")
+	ctx := context.Background()
+
+	if _, err := c.ListProducts(ctx, client.ListParams{}); err != nil {
+		t.Fatalf("list should succeed after retries: %v", err)
+	}
+	if len(f.seen) != 3 {
+		t.Fatalf("expected 3 attempts, got %v", f.seen)
+	}
+
+	// POST 携带 Idempotency-Key，重试时复用同一个 key，产品只创建一次。
+	f.failures, f.seen = 1, nil
+	if _, err := c.CreateProduct(ctx, models.Product{Name: "Retry", Price: 1}); err != nil {
+		t.Fatalf("create should succeed after retry: %v", err)
+	}
+	if len(f.seen) != 2 || f.seen[0] != f.seen[1] || f.seen[0] == "POST " {
+		t.Fatalf("expected two POSTs with the same Idempotency-Key, got %v", f.seen)
+	}
+
+	// 重试次数用完后返回最后一次的错误。
+	f.failures, f.seen = 10, nil
+	c.MaxRetries = 1
+	_, err := c.GetProduct(ctx, 1)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || len(f.seen) != 2 {
+		t.Fatalf("expected 503 after 2 attempts, got %v (%v)", err, f.seen)
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	defer setupTestDB()()
+	f := &flaky{}
+	c := newTestClient(newTestServer(t, f.wrap).URL, "")
+
+	if _, err := c.GetProduct(context.Background(), 12345); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if len(f.seen) != 1 {
+		t.Fatalf("4xx must not be retried, got %v", f.seen)
+	}
+}
+
+func TestClientRetryHonoursContext(t *testing.T) {
+	defer setupTestDB()()
+	f := &flaky{failures: 100}
+	c := newTestClient(newTestServer(t, f.wrap).URL, "")
+	c.Backoff = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListProducts(ctx, client.ListParams{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"golang-starter/apierror"
+	"golang-starter/handlers"
+)
+
+// seedProducts 批量创建 n 个产品，让列表响应超过压缩阈值。
+func seedProducts(t *testing.T, n int) {
+	t.Helper()
+
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"name":"Compressible product %d","price":9.5,"stock":%d}`, i, i)
+	}
+	if w := serve(tenantRequest("POST", "/api/products/bulk", "", "["+strings.Join(items, ",")+"]")); w.Code != http.StatusCreated {
+		t.Fatalf("seed products failed: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func decompress(t *testing.T, encoding string, body io.Reader) []byte {
+	t.Helper()
+
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatalf("gzip reader: %v", err)
+		}
+		r = gz
+	case "br":
+		r = brotli.NewReader(body)
+	case "zstd":
+		zr, err := zstd.NewReader(body)
+		if err != nil {
+			t.Fatalf("zstd reader: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		t.Fatalf("unexpected encoding %q", encoding)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompress %s: %v", encoding, err)
+	}
+	return data
+}
+
+func TestResponseCompression(t *testing.T) {
+	defer setupTestDB()()
+	seedProducts(t, 50)
+
+	for _, tc := range []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"gzip", "gzip"},
+		{"br", "br"},
+		{"zstd", "zstd"},
+		// q 值相同时按服务端偏好选择。
+		{"gzip, br, zstd", "zstd"},
+		{"gzip;q=1, br;q=0.8, zstd;q=0.5", "gzip"},
+		{"*;q=0.5, zstd;q=0", "br"},
+	} {
+		req := httptest.NewRequest("GET", "/api/products?limit=100", nil)
+		req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		w := serve(req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != tc.want {
+			t.Fatalf("Accept-Encoding %q: expected %q, got %q", tc.acceptEncoding, tc.want, got)
+		}
+		if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Accept-Encoding") {
+			t.Fatalf("expected Vary: Accept-Encoding, got %v", w.Header().Values("Vary"))
+		}
+
+		var result struct {
+			Code int               `json:"code"`
+			Data []json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(decompress(t, tc.want, w.Body), &result); err != nil {
+			t.Fatalf("decode %s body: %v", tc.want, err)
+		}
+		if result.Code != http.StatusOK || len(result.Data) != 50 {
+			t.Fatalf("unexpected body: code=%d items=%d", result.Code, len(result.Data))
+		}
+	}
+
+	// 不接受任何支持的算法时原样返回。
+	req := httptest.NewRequest("GET", "/api/products", nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	if w := serve(req); w.Header().Get("Content-Encoding") != "" || !json.Valid(w.Body.Bytes()) {
+		t.Fatalf("expected uncompressed response, got %q", w.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestSmallResponseNotCompressed(t *testing.T) {
+	defer setupTestDB()()
+
+	req := httptest.NewRequest("GET", "/api/health", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := serve(req)
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("small response should not be compressed")
+	}
+	if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Accept-Encoding") {
+		t.Fatalf("expected Vary: Accept-Encoding even when not compressed")
+	}
+
+	// 错误响应同样不压缩，结构不变。
+	req = httptest.NewRequest("GET", "/api/products/999999", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	expectError(t, serve(req), http.StatusNotFound, apierror.ProductNotFound)
+}
+
+func TestExportCompressed(t *testing.T) {
+	defer setupTestDB()()
+	seedProducts(t, 50)
+
+	req := httptest.NewRequest("GET", "/api/products/export?format=csv", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := serve(req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip export, got %d %v", w.Code, w.Header())
+	}
+	lines := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(decompress(t, "gzip", w.Body))))
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != 51 {
+		t.Fatalf("expected header + 50 rows, got %d lines", lines)
+	}
+}
+
+func TestBinaryResponseFormats(t *testing.T) {
+	defer setupTestDB()()
+	seedProducts(t, 3)
+
+	type product struct {
+		ID    int64   `msgpack:"id" cbor:"id"`
+		Name  string  `msgpack:"name" cbor:"name"`
+		Price float64 `msgpack:"price" cbor:"price"`
+		Stock int64   `msgpack:"stock" cbor:"stock"`
+	}
+	type envelope struct {
+		Code    int       `msgpack:"code" cbor:"code"`
+		Message string    `msgpack:"message" cbor:"message"`
+		Data    []product `msgpack:"data" cbor:"data"`
+	}
+
+	for _, tc := range []struct {
+		accept      string
+		contentType string
+		unmarshal   func([]byte, any) error
+	}{
+		{"application/msgpack", "application/msgpack", msgpack.Unmarshal},
+		{"application/x-msgpack, application/json;q=0.5", "application/msgpack", msgpack.Unmarshal},
+		{"application/cbor", "application/cbor", cbor.Unmarshal},
+	} {
+		req := httptest.NewRequest("GET", "/api/products", nil)
+		req.Header.Set("Accept", tc.accept)
+		w := serve(req)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tc.contentType {
+			t.Fatalf("Accept %q: expected %s, got %d %q", tc.accept, tc.contentType, w.Code, w.Header().Get("Content-Type"))
+		}
+		var result envelope
+		if err := tc.unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decode %s: %v", tc.contentType, err)
+		}
+		if result.Code != http.StatusOK || result.Message != "success" || len(result.Data) != 3 ||
+			result.Data[0].ID == 0 || result.Data[0].Price != 9.5 || !strings.HasPrefix(result.Data[0].Name, "Compressible product") {
+			t.Fatalf("unexpected %s body: %+v", tc.contentType, result)
+		}
+	}
+
+	// 错误响应使用同样的格式。
+	req := httptest.NewRequest("GET", "/api/products/999999", nil)
+	req.Header.Set("Accept", "application/cbor")
+	w := serve(req)
+	var apiErr struct {
+		Code      int    `cbor:"code"`
+		ErrorCode string `cbor:"error_code"`
+	}
+	if err := cbor.Unmarshal(w.Body.Bytes(), &apiErr); err != nil || w.Code != http.StatusNotFound || apiErr.ErrorCode != string(apierror.ProductNotFound) {
+		t.Fatalf("unexpected cbor error: %d %+v %v", w.Code, apiErr, err)
+	}
+
+	// JSON 优先或只接受通配符时仍返回 JSON。
+	for _, accept := range []string{"*/*", "application/json, application/msgpack;q=0.5"} {
+		req := httptest.NewRequest("GET", "/api/products", nil)
+		req.Header.Set("Accept", accept)
+		if w := serve(req); !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			t.Fatalf("Accept %q: expected JSON, got %q", accept, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+// TestCompressionDisabled CompressionEncodings 为空时不压缩。
+func TestCompressionDisabled(t *testing.T) {
+	defer setupTestDB()()
+	seedProducts(t, 50)
+	encodings := handlers.CompressionEncodings
+	defer func() { handlers.CompressionEncodings = encodings }()
+	handlers.CompressionEncodings = nil
+
+	req := httptest.NewRequest("GET", "/api/products", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if w := serve(req); w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("compression should be disabled")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang-starter/auth"
+	"golang-starter/backup"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+func TestDatabaseSettings(t *testing.T) {
+	defer setupTestDB()()
+
+	var mode string
+	if err := utils.DB.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("expected WAL journal mode, got %q (%v)", mode, err)
+	}
+	var foreignKeys int
+	if err := utils.ReadDB.QueryRow(`PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil || foreignKeys != 1 {
+		t.Fatalf("expected foreign keys to be enabled, got %d (%v)", foreignKeys, err)
+	}
+	if _, err := utils.ReadDB.Exec(`DELETE FROM products`); err == nil {
+		t.Fatal("the read pool must reject writes")
+	}
+	if stats := utils.DB.Stats(); stats.MaxOpenConnections != 1 {
+		t.Fatalf("expected a single writer connection, got %d", stats.MaxOpenConnections)
+	}
+}
+
+// TestConcurrentCreateAndPatch 并发创建、PATCH 与读取产品，同时另一个连接（相当于另一个进程）也在写入：
+// 所有请求都应该成功，不会出现 "database is locked"。
+func TestConcurrentCreateAndPatch(t *testing.T) {
+	defer setupTestDB()()
+
+	const workers, perWorker = 16, 15
+	errs := make(chan string, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				w := serve(tenantRequest("POST", "/api/products", "", fmt.Sprintf(`{"name":"Concurrent %d-%d","price":1,"stock":1}`, i, j)))
+				if w.Code != http.StatusCreated {
+					errs <- fmt.Sprintf("create: %d %s", w.Code, w.Body.String())
+					return
+				}
+				var created struct {
+					Data models.Product `json:"data"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+					errs <- fmt.Sprintf("decode: %v", err)
+					return
+				}
+				if w := patchProduct(created.Data.ID, "application/merge-patch+json", `{"stock":100}`); w.Code != http.StatusOK {
+					errs <- fmt.Sprintf("patch: %d %s", w.Code, w.Body.String())
+					return
+				}
+				if w := serve(httptest.NewRequest("GET", fmt.Sprintf("/api/products/%d", created.Data.ID), nil)); w.Code != http.StatusOK {
+					errs <- fmt.Sprintf("get: %d %s", w.Code, w.Body.String())
+					return
+				}
+			}
+		}(i)
+	}
+
+	// 另一个连接池中的写事务：服务的写连接要靠 busy_timeout 等待它释放锁。
+	other, err := sql.Open("sqlite3", "file:"+utils.DBPath+"?_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 20; k++ {
+			tx, err := other.Begin()
+			if err != nil {
+				errs <- fmt.Sprintf("external begin: %v", err)
+				return
+			}
+			if _, err := tx.Exec(`INSERT INTO products (tenant_id, name, price, stock, created_at, updated_at) VALUES ('external', 'External', 1, 0, ?, ?)`, time.Now(), time.Now()); err != nil {
+				tx.Rollback()
+				errs <- fmt.Sprintf("external insert: %v", err)
+				return
+			}
+			time.Sleep(2 * time.Millisecond)
+			if err := tx.Commit(); err != nil {
+				errs <- fmt.Sprintf("external commit: %v", err)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var patched int
+	if err := utils.ReadDB.QueryRow(`SELECT COUNT(*) FROM products WHERE tenant_id = ? AND stock = 100`, models.DefaultTenant).Scan(&patched); err != nil {
+		t.Fatal(err)
+	}
+	if patched != workers*perWorker {
+		t.Fatalf("expected %d patched products, got %d", workers*perWorker, patched)
+	}
+}
+
+// TestReadsAndBackupsDoNotWaitForWriter 写连接被一个长事务占用时，API 密钥认证、幂等重放与备份都不需要等待它。
+func TestReadsAndBackupsDoNotWaitForWriter(t *testing.T) {
+	defer setupAuth(t)()
+	viewer := createTestKey(t, auth.Viewer)
+	editor := createTestKey(t, auth.Editor)
+
+	// 先各用一次：last_used_at 刚更新过，认证时不需要写入。
+	if w := serve(requestWithKey("GET", "/api/products", viewer, "")); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	create := func() *httptest.ResponseRecorder {
+		req := requestWithKey("POST", "/api/products", editor, `{"name":"Idempotent","price":1,"stock":1}`)
+		req.Header.Set("Idempotency-Key", "writer-busy")
+		return serve(req)
+	}
+	if w := create(); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	done := make(chan error, 1)
+	go func() {
+		if w := serve(requestWithKey("GET", "/api/products", viewer, "")); w.Code != http.StatusOK {
+			done <- fmt.Errorf("authenticated read: %d %s", w.Code, w.Body.String())
+			return
+		}
+		if w := create(); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "true" {
+			done <- fmt.Errorf("idempotent replay: %d %v", w.Code, w.Header())
+			return
+		}
+		_, err := backup.Create(context.Background(), utils.BackupDB, filepath.Join(t.TempDir(), "busy.db"))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		tx.Rollback()
+		<-done
+		t.Fatal("requests waited for the writer connection")
+	}
+}
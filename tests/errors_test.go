@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-starter/apierror"
+	"golang-starter/utils"
+)
+
+type apiErrorBody struct {
+	Code      int           `json:"code"`
+	ErrorCode apierror.Code `json:"error_code"`
+	Message   string        `json:"message"`
+}
+
+// expectError 断言响应的 HTTP 状态码与错误码，并返回解码后的错误响应。
+func expectError(t *testing.T, w *httptest.ResponseRecorder, status int, code apierror.Code) apiErrorBody {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d. Body: %s", status, w.Code, w.Body.String())
+	}
+	var body apiErrorBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode error json: %v", err)
+	}
+	if body.Code != status || body.ErrorCode != code {
+		t.Fatalf("expected code %d / error_code %s, got %d / %s", status, code, body.Code, body.ErrorCode)
+	}
+	return body
+}
+
+func TestErrorCodes(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		code   apierror.Code
+	}{
+		{"product not found", "GET", "/api/products/99999999", "", http.StatusNotFound, apierror.ProductNotFound},
+		{"invalid id", "GET", "/api/products/abc", "", http.StatusBadRequest, apierror.InvalidID},
+		{"invalid limit", "GET", "/api/products?limit=-1", "", http.StatusBadRequest, apierror.InvalidPagination},
+		{"invalid order", "GET", "/api/products?order=bogus", "", http.StatusBadRequest, apierror.InvalidPagination},
+		{"invalid json", "POST", "/api/products", `{"name":`, http.StatusBadRequest, apierror.InvalidJSON},
+		{"validation failed", "POST", "/api/products", `{"name":"","price":1}`, http.StatusUnprocessableEntity, apierror.ValidationFailed},
+		{"method not allowed", "DELETE", "/api/products/search?name=x", "", http.StatusMethodNotAllowed, apierror.MethodNotAllowed},
+		{"job not found", "GET", "/api/jobs/99999999", "", http.StatusNotFound, apierror.JobNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			expectError(t, serve(req), tc.status, tc.code)
+		})
+	}
+}
+
+// TestInternalErrorIsNotLeaked 数据库错误只返回固定文案，不暴露 SQL 细节。
+func TestInternalErrorIsNotLeaked(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	if _, err := utils.DB.Exec("ALTER TABLE products RENAME TO products_hidden"); err != nil {
+		t.Fatalf("rename table failed: %v", err)
+	}
+	defer func() {
+		if _, err := utils.DB.Exec("ALTER TABLE products_hidden RENAME TO products"); err != nil {
+			t.Fatalf("restore table failed: %v", err)
+		}
+	}()
+
+	body := expectError(t, serve(httptest.NewRequest("GET", "/api/products/1", nil)), http.StatusInternalServerError, apierror.Internal)
+	if body.Message != "internal server error" {
+		t.Fatalf("expected generic message, got %q", body.Message)
+	}
+}
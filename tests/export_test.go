@@ -0,0 +1,226 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/handlers"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+func TestExportProductsCSV(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	createProductWithName(t, "Apple")
+	createProductWithName(t, "Banana, \"ripe\"")
+
+	req := httptest.NewRequest("GET", "/api/products/export?format=csv&order=id_desc", nil)
+	w := httptest.NewRecorder()
+
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux)
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") || !strings.Contains(cd, ".csv") {
+		t.Fatalf("unexpected Content-Disposition: %q", cd)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse csv: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header + 2 rows, got %d", len(records))
+	}
+	if records[0][0] != "id" || records[0][1] != "name" {
+		t.Fatalf("unexpected header: %v", records[0])
+	}
+	// order=id_desc：后创建的产品排在前面。
+	if records[1][1] != "Banana, \"ripe\"" || records[2][1] != "Apple" {
+		t.Fatalf("unexpected rows: %v", records[1:])
+	}
+}
+
+func TestExportProductsNDJSONWithLimit(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	createProductWithName(t, "P1")
+	createProductWithName(t, "P2")
+	createProductWithName(t, "P3")
+
+	req := httptest.NewRequest("GET", "/api/products/export?format=ndjson&limit=2&offset=1", nil)
+	w := httptest.NewRecorder()
+
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux)
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var item map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("invalid ndjson line %q: %v", scanner.Text(), err)
+		}
+		names = append(names, item["name"].(string))
+	}
+	if len(names) != 2 || names[0] != "P2" || names[1] != "P3" {
+		t.Fatalf("expected [P2 P3], got %v", names)
+	}
+}
+
+func TestExportProductsXLSX(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	createProductWithName(t, "<Apple & Co>")
+
+	req := httptest.NewRequest("GET", "/api/products/export?format=xlsx", nil)
+	w := httptest.NewRecorder()
+
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux)
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	body := w.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("xlsx is not a valid zip: %v", err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if files[name] == nil {
+			t.Fatalf("xlsx missing part %s", name)
+		}
+	}
+
+	rc, err := files["xl/worksheets/sheet1.xml"].Open()
+	if err != nil {
+		t.Fatalf("failed to open sheet: %v", err)
+	}
+	defer rc.Close()
+	sheet, _ := io.ReadAll(rc)
+	if !bytes.Contains(sheet, []byte("&lt;Apple &amp; Co&gt;")) {
+		t.Fatalf("expected escaped product name in sheet, got %s", sheet)
+	}
+}
+
+func TestExportProductsInvalidFormatReturns400(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	req := httptest.NewRequest("GET", "/api/products/export?format=pdf", nil)
+	w := httptest.NewRecorder()
+
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux)
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+// flushRecorder 记录每次 Flush 时已经写到响应体的数据长度。
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushed = append(f.flushed, f.Body.Len())
+	f.ResponseRecorder.Flush()
+}
+
+// TestExportFlushWritesBufferedRows 定期 Flush 时，writer 内部缓冲（csv 的 bufio、xlsx 的工作表缓冲与压缩器）中的行也要一并发出。
+func TestExportFlushWritesBufferedRows(t *testing.T) {
+	defer setupTestDB()()
+
+	products := make([]*models.Product, 1500)
+	for i := range products {
+		products[i] = &models.Product{Name: fmt.Sprintf("P%04d", i+1), Price: 1, Stock: 1}
+	}
+	if _, err := models.ProductsBulk(context.Background(), utils.DB, models.DefaultTenant, products); err != nil {
+		t.Fatalf("bulk create failed: %v", err)
+	}
+
+	export := func(format string) []byte {
+		t.Helper()
+		w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		mux := http.NewServeMux()
+		handlers.RegisterRoutes(mux)
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/products/export?format="+format, nil))
+		if w.Code != http.StatusOK || len(w.flushed) == 0 {
+			t.Fatalf("expected a flushed export, got %d with %d flushes", w.Code, len(w.flushed))
+		}
+		return w.Body.Bytes()[:w.flushed[0]]
+	}
+
+	// 第一次 Flush 时，表头与前 1000 行都已经发出。
+	records, err := csv.NewReader(bytes.NewReader(export("csv"))).ReadAll()
+	if err != nil || len(records) != 1001 || records[1000][1] != "P1000" {
+		t.Fatalf("expected header + 1000 rows at the first flush, got %d records: %v", len(records), err)
+	}
+
+	// xlsx：第一个 zip 条目是工作表，跳过本地文件头后解压已发出的部分。
+	partial := export("xlsx")
+	if len(partial) < 30 || !bytes.HasPrefix(partial, []byte("PK\x03\x04")) {
+		t.Fatalf("expected a zip local file header, got %d bytes", len(partial))
+	}
+	offset := 30 + int(binary.LittleEndian.Uint16(partial[26:])) + int(binary.LittleEndian.Uint16(partial[28:]))
+	sheet, _ := io.ReadAll(flate.NewReader(bytes.NewReader(partial[offset:])))
+	if !bytes.Contains(sheet, []byte(">P1000<")) {
+		t.Fatalf("expected the first 1000 rows at the first flush, got %d bytes of sheet", len(sheet))
+	}
+}
+
+// TestExportFailureBeforeOutput 打开游标时就失败（超过时限、客户端断开）：还没有发出任何数据，按普通错误响应。
+func TestExportFailureBeforeOutput(t *testing.T) {
+	defer setupTestDB()()
+	createProductWithName(t, "Exported")
+	defer func(previous time.Duration) { handlers.RequestTimeouts["export"] = previous }(handlers.RequestTimeouts["export"])
+
+	handlers.RequestTimeouts["export"] = time.Nanosecond
+	w := serve(httptest.NewRequest("GET", "/api/products/export?format=xlsx", nil))
+	expectError(t, w, http.StatusServiceUnavailable, apierror.RequestTimeout)
+	if cd := w.Header().Get("Content-Disposition"); cd != "" {
+		t.Fatalf("error response must not be an attachment, got %q", cd)
+	}
+
+	handlers.RequestTimeouts["export"] = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	expectError(t, serve(httptest.NewRequest("GET", "/api/products/export", nil).WithContext(ctx)), handlers.StatusClientClosedRequest, apierror.RequestCanceled)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-starter/apierror"
+	"golang-starter/i18n"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	tests := []struct {
+		target         string
+		acceptLanguage string
+		want           i18n.Lang
+	}{
+		{"/api/products", "", i18n.En},
+		{"/api/products", "zh-CN,zh;q=0.9,en;q=0.8", i18n.ZhCN},
+		{"/api/products", "zh-Hans", i18n.ZhCN},
+		{"/api/products", "fr-FR, en;q=0.5, zh;q=0.4", i18n.En},
+		{"/api/products", "en;q=0.3, zh-TW;q=0.8", i18n.ZhCN},
+		{"/api/products", "fr, de", i18n.En},
+		{"/api/products?lang=zh-CN", "en-US", i18n.ZhCN},
+		{"/api/products?lang=en", "zh-CN", i18n.En},
+		{"/api/products?lang=fr", "zh-CN", i18n.ZhCN},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", tc.target, nil)
+		if tc.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+		}
+		if got := i18n.Negotiate(req); got != tc.want {
+			t.Errorf("Negotiate(%s, %q) = %s, want %s", tc.target, tc.acceptLanguage, got, tc.want)
+		}
+	}
+}
+
+func TestLocalizedErrorMessage(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	req := httptest.NewRequest("GET", "/api/products/99999999", nil)
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
+	w := serve(req)
+
+	if got := w.Header().Get("Content-Language"); got != "zh-CN" {
+		t.Fatalf("expected Content-Language zh-CN, got %q", got)
+	}
+	// 错误码与语言无关，只有 message 被翻译。
+	body := expectError(t, w, http.StatusNotFound, apierror.ProductNotFound)
+	if body.Message != "产品不存在" {
+		t.Fatalf("expected localized message, got %q", body.Message)
+	}
+
+	// 带参数的消息：参数原样保留。
+	body = expectError(t, serve(httptest.NewRequest("GET", "/api/products?limit=-1&lang=zh-CN", nil)), http.StatusBadRequest, apierror.InvalidPagination)
+	if body.Message != "分页参数 limit 不合法" {
+		t.Fatalf("unexpected message: %q", body.Message)
+	}
+
+	// 默认语言保持原有英文文案。
+	body = expectError(t, serve(httptest.NewRequest("GET", "/api/products?limit=-1", nil)), http.StatusBadRequest, apierror.InvalidPagination)
+	if body.Message != "invalid limit" {
+		t.Fatalf("unexpected message: %q", body.Message)
+	}
+}
+
+func TestLocalizedValidationMessages(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	payload := `[{"name":"ok","price":1,"stock":1},{"name":"","price":-2,"stock":1}]`
+	req := httptest.NewRequest("POST", "/api/products/bulk?lang=zh-CN", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	body := decodeValidationError(t, serve(req))
+
+	if body.Message != "参数校验失败" {
+		t.Fatalf("unexpected message: %q", body.Message)
+	}
+	want := []string{"products[1].name 不能为空", "products[1].price 必须大于 0"}
+	if len(body.Errors) != len(want) {
+		t.Fatalf("expected %d errors, got %+v", len(want), body.Errors)
+	}
+	for i, fe := range body.Errors {
+		if fe.Message != want[i] {
+			t.Errorf("errors[%d]: expected %q, got %q", i, want[i], fe.Message)
+		}
+	}
+}
+
+func TestLocalizedSuccessMessage(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	req := httptest.NewRequest("GET", "/api/products", nil)
+	req.Header.Set("Accept-Language", "zh")
+	w := serve(req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode json: %v", err)
+	}
+	if body.Message != "成功" {
+		t.Fatalf("expected localized success message, got %q", body.Message)
+	}
+}
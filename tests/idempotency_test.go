@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-starter/handlers"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+func clearIdempotencyKeys(t *testing.T) {
+	t.Helper()
+
+	if _, err := utils.DB.Exec("DELETE FROM idempotency_keys"); err != nil {
+		t.Fatalf("failed to clear idempotency_keys table: %v", err)
+	}
+}
+
+func postWithIdempotencyKey(path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	return serve(req)
+}
+
+func TestIdempotencyKeyReplaysCreate(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+	clearIdempotencyKeys(t)
+
+	body := `{"name": "Once", "price": 9.9, "stock": 1}`
+	first := postWithIdempotencyKey("/api/products", "create-1", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusCreated, first.Code, first.Body.String())
+	}
+
+	second := postWithIdempotencyKey("/api/products", "create-1", body)
+	if second.Code != http.StatusCreated {
+		t.Fatalf("expected replayed status %d, got %d. Body: %s", http.StatusCreated, second.Code, second.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected Idempotent-Replayed header on replay")
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("expected identical replayed body, got %s vs %s", second.Body.String(), first.Body.String())
+	}
+
+	count, err := models.CountProducts(context.Background(), utils.DB, models.DefaultTenant)
+	if err != nil {
+		t.Fatalf("count products failed: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected exactly 1 product, got %d", count)
+	}
+}
+
+func TestIdempotencyKeyDifferentBodyReturns422(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+	clearIdempotencyKeys(t)
+
+	first := postWithIdempotencyKey("/api/products/bulk", "bulk-1", `[{"name":"A","price":1,"stock":1}]`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusCreated, first.Code, first.Body.String())
+	}
+
+	second := postWithIdempotencyKey("/api/products/bulk", "bulk-1", `[{"name":"B","price":1,"stock":1}]`)
+	if second.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusUnprocessableEntity, second.Code, second.Body.String())
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(second.Body).Decode(&result); err != nil {
+		t.Fatalf("expected json error response, decode failed: %v", err)
+	}
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+	clearIdempotencyKeys(t)
+
+	ttl := handlers.IdempotencyTTL
+	handlers.IdempotencyTTL = time.Millisecond
+	defer func() { handlers.IdempotencyTTL = ttl }()
+
+	body := `{"name": "Twice", "price": 9.9, "stock": 1}`
+	if w := postWithIdempotencyKey("/api/products", "expiring", body); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	w := postWithIdempotencyKey("/api/products", "expiring", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected expired key to be treated as a new request")
+	}
+
+	count, err := models.CountProducts(context.Background(), utils.DB, models.DefaultTenant)
+	if err != nil {
+		t.Fatalf("count products failed: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 products after key expiry, got %d", count)
+	}
+}
+
+func TestIdempotencyKeyReplaysClientError(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+	clearIdempotencyKeys(t)
+
+	// 4xx 同样会被保存并重放：同样的错误请求重试得到同样的结果。
+	body := `{"name": "", "price": 9.9, "stock": 1}`
+	first := postWithIdempotencyKey("/api/products", "invalid", body)
+	second := postWithIdempotencyKey("/api/products", "invalid", body)
+	if first.Code != http.StatusUnprocessableEntity || second.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected both 422, got %d and %d", first.Code, second.Code)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected 4xx response to be replayed")
+	}
+}
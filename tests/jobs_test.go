@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-starter/handlers"
+	"golang-starter/jobs"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+// setupJobs 清空任务表并启动 worker；返回的函数会停止 worker。
+func setupJobs(t *testing.T) func() {
+	t.Helper()
+
+	if _, err := utils.DB.Exec("DELETE FROM jobs"); err != nil {
+		t.Fatalf("failed to clear jobs table: %v", err)
+	}
+	return setupJobsKeepingQueue(t)
+}
+
+// serve 通过完整路由分发一个请求。
+func serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux)
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+// acceptedJobID 断言响应为 202 并取出任务 id。
+func acceptedJobID(t *testing.T, w *httptest.ResponseRecorder) int {
+	t.Helper()
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var result struct {
+		Data models.Job `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response json: %v", err)
+	}
+	if w.Header().Get("Location") != fmt.Sprintf("/api/jobs/%d", result.Data.ID) {
+		t.Fatalf("unexpected Location header: %q", w.Header().Get("Location"))
+	}
+	return result.Data.ID
+}
+
+// waitForJob 轮询任务直到进入终态。
+func waitForJob(t *testing.T, id int) models.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		w := serve(httptest.NewRequest("GET", fmt.Sprintf("/api/jobs/%d", id), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var result struct {
+			Data models.Job `json:"data"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("failed to decode job json: %v", err)
+		}
+		if result.Data.Finished() {
+			return result.Data
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not finish in time", id)
+	return models.Job{}
+}
+
+func TestAsyncExportJob(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+	stopJobs := setupJobs(t)
+	defer stopJobs()
+
+	createProductWithName(t, "P1")
+	createProductWithName(t, "P2")
+
+	id := acceptedJobID(t, serve(httptest.NewRequest("GET", "/api/products/export?format=csv&async=true", nil)))
+
+	job := waitForJob(t, id)
+	if job.Status != models.JobSucceeded {
+		t.Fatalf("expected job succeeded, got %s (%s)", job.Status, job.Error)
+	}
+	if job.Progress != 2 || job.Total != 2 {
+		t.Fatalf("expected progress 2/2, got %d/%d", job.Progress, job.Total)
+	}
+
+	w := serve(httptest.NewRequest("GET", fmt.Sprintf("/api/jobs/%d/result", id), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), ".csv") {
+		t.Fatalf("unexpected Content-Disposition: %q", w.Header().Get("Content-Disposition"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse csv: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header + 2 rows, got %d", len(records))
+	}
+}
+
+func TestAsyncBulkCreateJob(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+	stopJobs := setupJobs(t)
+	defer stopJobs()
+
+	payload := `[{"name":"A","price":10.0,"stock":1},{"name":"B","price":20.0,"stock":0}]`
+	req := httptest.NewRequest("POST", "/api/products/bulk?async=true", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	id := acceptedJobID(t, serve(req))
+
+	job := waitForJob(t, id)
+	if job.Status != models.JobSucceeded {
+		t.Fatalf("expected job succeeded, got %s (%s)", job.Status, job.Error)
+	}
+
+	count, err := models.CountProducts(context.Background(), utils.DB, models.DefaultTenant)
+	if err != nil {
+		t.Fatalf("count products failed: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 products, got %d", count)
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	// 不启动 worker：任务会一直处于 queued，便于验证取消。
+	if _, err := utils.DB.Exec("DELETE FROM jobs"); err != nil {
+		t.Fatalf("failed to clear jobs table: %v", err)
+	}
+	id := acceptedJobID(t, serve(httptest.NewRequest("GET", "/api/products/export?async=true", nil)))
+
+	w := serve(httptest.NewRequest("POST", fmt.Sprintf("/api/jobs/%d/cancel", id), nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	job, err := models.GetJobByID(context.Background(), utils.DB, models.DefaultTenant, id)
+	if err != nil {
+		t.Fatalf("get job failed: %v", err)
+	}
+	if job.Status != models.JobCancelled {
+		t.Fatalf("expected job cancelled, got %s", job.Status)
+	}
+
+	// 已结束的任务再次取消返回 409，结果下载同样返回 409。
+	if w := serve(httptest.NewRequest("POST", fmt.Sprintf("/api/jobs/%d/cancel", id), nil)); w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if w := serve(httptest.NewRequest("GET", fmt.Sprintf("/api/jobs/%d/result", id), nil)); w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestJobsRecoveredAfterRestart(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	if _, err := utils.DB.Exec("DELETE FROM jobs"); err != nil {
+		t.Fatalf("failed to clear jobs table: %v", err)
+	}
+	createProductWithName(t, "P1")
+
+	// 模拟上次进程退出时正在执行的任务。
+	job, err := models.CreateJob(context.Background(), utils.DB, models.DefaultTenant, "export", json.RawMessage(`{"format":"ndjson","params":{"order":"id_asc"}}`))
+	if err != nil {
+		t.Fatalf("create job failed: %v", err)
+	}
+	if _, err := utils.DB.Exec("UPDATE jobs SET status = ?, progress = 7 WHERE id = ?", models.JobRunning, job.ID); err != nil {
+		t.Fatalf("mark job running failed: %v", err)
+	}
+
+	stopJobs := setupJobsKeepingQueue(t)
+	defer stopJobs()
+
+	finished := waitForJob(t, job.ID)
+	if finished.Status != models.JobSucceeded {
+		t.Fatalf("expected recovered job succeeded, got %s (%s)", finished.Status, finished.Error)
+	}
+	if finished.Progress != 1 {
+		t.Fatalf("expected progress restarted from 0 and reach 1, got %d", finished.Progress)
+	}
+}
+
+// TestBulkCreateJobResumesFromCheckpoint 批量创建任务在第一批提交后被打断，重新执行时从断点继续，不重复插入。
+func TestBulkCreateJobResumesFromCheckpoint(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+	ctx := context.Background()
+
+	if _, err := utils.DB.Exec("DELETE FROM jobs"); err != nil {
+		t.Fatalf("failed to clear jobs table: %v", err)
+	}
+	products := make([]models.Product, 1200)
+	for i := range products {
+		products[i] = models.Product{Name: fmt.Sprintf("Resume %d", i), Price: 1}
+	}
+	payload, _ := json.Marshal(map[string]any{"products": products})
+	job, err := models.CreateJob(ctx, utils.DB, models.DefaultTenant, "bulk_create", payload)
+	if err != nil {
+		t.Fatalf("create job failed: %v", err)
+	}
+
+	// 配额只够第一批：第一批提交后第二批失败，相当于在第一批之后被打断。
+	quota := 500
+	if _, err := models.SetTenantQuota(ctx, utils.DB, models.DefaultTenant, &quota); err != nil {
+		t.Fatal(err)
+	}
+	defer models.SetTenantQuota(ctx, utils.DB, models.DefaultTenant, nil)
+
+	stopJobs := setupJobsKeepingQueue(t)
+	if failed := waitForJob(t, job.ID); failed.Status != models.JobFailed || failed.Progress != 500 {
+		stopJobs()
+		t.Fatalf("expected the job to fail after the first chunk, got %s %d/%d (%s)", failed.Status, failed.Progress, failed.Total, failed.Error)
+	}
+	stopJobs()
+
+	// 模拟进程在执行中退出：任务仍是 running，重启后从断点继续。
+	if _, err := models.SetTenantQuota(ctx, utils.DB, models.DefaultTenant, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.DB.Exec("UPDATE jobs SET status = ?, error = '', finished_at = NULL WHERE id = ?", models.JobRunning, job.ID); err != nil {
+		t.Fatalf("mark job running failed: %v", err)
+	}
+	if err := models.RecoverJobs(ctx, utils.DB); err != nil {
+		t.Fatal(err)
+	}
+	if requeued, err := models.GetJobByID(ctx, utils.DB, models.DefaultTenant, job.ID); err != nil || requeued.Progress != 500 {
+		t.Fatalf("expected progress to be kept on requeue, got %+v (%v)", requeued, err)
+	}
+
+	stopJobs = setupJobsKeepingQueue(t)
+	defer stopJobs()
+
+	finished := waitForJob(t, job.ID)
+	if finished.Status != models.JobSucceeded || finished.Progress != 1200 {
+		t.Fatalf("expected resumed job succeeded with 1200/1200, got %s %d (%s)", finished.Status, finished.Progress, finished.Error)
+	}
+	var result struct {
+		Created int   `json:"created"`
+		IDs     []int `json:"ids"`
+	}
+	if err := json.Unmarshal(finished.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 1200 || len(result.IDs) != 1200 {
+		t.Fatalf("expected 1200 created ids, got %d (%d ids)", result.Created, len(result.IDs))
+	}
+
+	var rows, names int
+	if err := utils.DB.QueryRow("SELECT COUNT(*), COUNT(DISTINCT name) FROM products WHERE tenant_id = ?", models.DefaultTenant).Scan(&rows, &names); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1200 || names != 1200 {
+		t.Fatalf("expected 1200 distinct products, got %d rows with %d names", rows, names)
+	}
+	seen := map[int]bool{}
+	for _, id := range result.IDs {
+		if seen[id] {
+			t.Fatalf("duplicate id %d in the result", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSweepJobResults(t *testing.T) {
+	jobs.ResultDir = t.TempDir()
+	old := filepath.Join(jobs.ResultDir, "job-1.csv")
+	fresh := filepath.Join(jobs.ResultDir, "job-2.csv")
+	for _, path := range []string{old, fresh} {
+		if err := os.WriteFile(path, []byte("id\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(old, time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := jobs.SweepResults(time.Now().Add(-time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("expected one expired file removed, got %d (%v)", n, err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expected the expired file to be removed, got %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatalf("expected the fresh file to be kept, got %v", err)
+	}
+}
+
+func TestGetJobNotFoundReturns404(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	if w := serve(httptest.NewRequest("GET", "/api/jobs/99999999", nil)); w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// setupJobsKeepingQueue 与 setupJobs 相同，但保留任务表中已有的任务。
+func setupJobsKeepingQueue(t *testing.T) func() {
+	t.Helper()
+
+	jobs.ResultDir = t.TempDir()
+	jobs.PollInterval = 10 * time.Millisecond
+	if err := jobs.Start(context.Background(), utils.DB, 2); err != nil {
+		t.Fatalf("failed to start job workers: %v", err)
+	}
+	return jobs.Stop
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/handlers"
+)
+
+// testSigner 持有本地生成的签名密钥，用于签发测试 token 并导出对应的 JWKS。
+type testSigner struct {
+	rsaKey  *rsa.PrivateKey
+	ecKey   *ecdsa.PrivateKey
+	hmacKey []byte
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key failed: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key failed: %v", err)
+	}
+	hmacKey := make([]byte, 32)
+	if _, err := rand.Read(hmacKey); err != nil {
+		t.Fatalf("generate hmac key failed: %v", err)
+	}
+	return &testSigner{rsaKey: rsaKey, ecKey: ecKey, hmacKey: hmacKey}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// jwks 导出公钥（以及 HMAC 共享密钥）；kidSuffix 用于模拟签发方轮换密钥。
+func (s *testSigner) jwks(kidSuffix string) []byte {
+	pad := func(n *big.Int) []byte {
+		b := make([]byte, 32)
+		return n.FillBytes(b)
+	}
+	set := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa" + kidSuffix, "use": "sig", "alg": "RS256",
+			"n": b64(s.rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(s.rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec" + kidSuffix, "crv": "P-256",
+			"x": b64(pad(s.ecKey.X)), "y": b64(pad(s.ecKey.Y))},
+		{"kty": "oct", "kid": "hmac" + kidSuffix, "alg": "HS256", "k": b64(s.hmacKey)},
+	}}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+func (s *testSigner) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, s.rsaKey, crypto.SHA256, digest[:])
+	case "ES256":
+		var r, ss *big.Int
+		r, ss, err = ecdsa.Sign(rand.Reader, s.ecKey, digest[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+		}
+	case "HS256":
+		mac := hmac.New(sha256.New, s.hmacKey)
+		mac.Write([]byte(signingInput))
+		sig = mac.Sum(nil)
+	case "none":
+	default:
+		t.Fatalf("unsupported alg %s", alg)
+	}
+	if err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	return signingInput + "." + b64(sig)
+}
+
+func validClaims(roles ...string) map[string]any {
+	return map[string]any{
+		"sub":   "user-1",
+		"iss":   "https://sso.example.test",
+		"aud":   []string{"product-api"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"roles": roles,
+	}
+}
+
+// setupJWT 用本地 JWKS 文件配置 JWT 校验，并打开认证。
+func setupJWT(t *testing.T, signer *testSigner) func() {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, signer.jwks(""), 0o600); err != nil {
+		t.Fatalf("write jwks failed: %v", err)
+	}
+	keys, err := auth.LoadKeySet(path)
+	if err != nil {
+		t.Fatalf("load jwks failed: %v", err)
+	}
+
+	restore := setupAuth(t)
+	handlers.JWTVerifier = &auth.JWTVerifier{
+		Keys:     keys,
+		Issuer:   "https://sso.example.test",
+		Audience: "product-api",
+	}
+	return func() {
+		handlers.JWTVerifier = nil
+		restore()
+	}
+}
+
+func bearer(method, target, token, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWTAlgorithmsAndRoles(t *testing.T) {
+	signer := newTestSigner(t)
+	defer setupJWT(t, signer)()
+
+	product := `{"name":"JWT Product","price":1,"stock":1}`
+
+	tests := []struct {
+		alg, kid string
+		roles    []string
+		status   int
+	}{
+		{"RS256", "rsa", []string{"editor"}, http.StatusCreated},
+		{"ES256", "ec", []string{"viewer", "admin"}, http.StatusCreated},
+		{"HS256", "hmac", []string{"editor"}, http.StatusCreated},
+		{"RS256", "rsa", []string{"viewer"}, http.StatusForbidden},
+		{"RS256", "rsa", nil, http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		token := signer.sign(t, tc.alg, tc.kid, validClaims(tc.roles...))
+		if w := serve(bearer("POST", "/api/products", token, product)); w.Code != tc.status {
+			t.Errorf("%s %v: expected %d, got %d. Body: %s", tc.alg, tc.roles, tc.status, w.Code, w.Body.String())
+		}
+	}
+
+	viewer := signer.sign(t, "ES256", "ec", validClaims("viewer"))
+	if w := serve(bearer("GET", "/api/products", viewer, "")); w.Code != http.StatusOK {
+		t.Fatalf("viewer token should be able to read, got %d", w.Code)
+	}
+}
+
+func TestJWTRejectsInvalidTokens(t *testing.T) {
+	signer := newTestSigner(t)
+	defer setupJWT(t, signer)()
+
+	expired := validClaims("admin")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	wrongIssuer := validClaims("admin")
+	wrongIssuer["iss"] = "https://evil.example.test"
+
+	wrongAudience := validClaims("admin")
+	wrongAudience["aud"] = "other-api"
+
+	notYetValid := validClaims("admin")
+	notYetValid["nbf"] = time.Now().Add(time.Hour).Unix()
+
+	noExp := validClaims("admin")
+	delete(noExp, "exp")
+
+	valid := signer.sign(t, "RS256", "rsa", validClaims("admin"))
+	tampered := valid[:strings.LastIndex(valid, ".")-2] + "xx" + valid[strings.LastIndex(valid, "."):]
+
+	tokens := map[string]string{
+		"expired":        signer.sign(t, "RS256", "rsa", expired),
+		"wrong issuer":   signer.sign(t, "RS256", "rsa", wrongIssuer),
+		"wrong audience": signer.sign(t, "RS256", "rsa", wrongAudience),
+		"not yet valid":  signer.sign(t, "RS256", "rsa", notYetValid),
+		"missing exp":    signer.sign(t, "RS256", "rsa", noExp),
+		"alg none":       signer.sign(t, "none", "rsa", validClaims("admin")),
+		"unknown kid":    signer.sign(t, "RS256", "missing", validClaims("admin")),
+		// 用 RSA 的 kid 声明 ES256：算法与密钥类型不一致。
+		"alg mismatch": signer.sign(t, "ES256", "rsa", validClaims("admin")),
+		"tampered":     tampered,
+	}
+	for name, token := range tokens {
+		w := serve(bearer("GET", "/api/products", token, ""))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, w.Code)
+			continue
+		}
+		expectError(t, w, http.StatusUnauthorized, apierror.Unauthorized)
+	}
+}
+
+func TestJWTRoleMapping(t *testing.T) {
+	signer := newTestSigner(t)
+	defer setupJWT(t, signer)()
+
+	handlers.JWTVerifier.RoleClaim = "groups"
+	handlers.JWTVerifier.RoleMapping = map[string]auth.Role{"catalog-writers": auth.Editor}
+
+	claims := validClaims()
+	claims["groups"] = []string{"everyone", "catalog-writers"}
+	token := signer.sign(t, "RS256", "rsa", claims)
+
+	if w := serve(bearer("POST", "/api/products", token, `{"name":"Mapped","price":1,"stock":1}`)); w.Code != http.StatusCreated {
+		t.Fatalf("mapped editor should be able to create, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	expectError(t, serve(bearer("GET", "/api/admin/keys", token, "")), http.StatusForbidden, apierror.Forbidden)
+}
+
+// TestJWKSFromURLRefreshesOnUnknownKid 签发方轮换密钥后，遇到新的 kid 会重新拉取 JWKS。
+func TestJWKSFromURLRefreshesOnUnknownKid(t *testing.T) {
+	signer := newTestSigner(t)
+
+	var mu sync.Mutex
+	suffix := "-v1"
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write(signer.jwks(suffix))
+	}))
+	defer server.Close()
+
+	keys, err := auth.LoadKeySet(server.URL)
+	if err != nil {
+		t.Fatalf("load jwks failed: %v", err)
+	}
+	keys.MinRefreshInterval = 0
+	verifier := &auth.JWTVerifier{Keys: keys}
+
+	if _, err := verifier.Verify(signer.sign(t, "RS256", "rsa-v1", validClaims("viewer"))); err != nil {
+		t.Fatalf("verify with cached key failed: %v", err)
+	}
+	if fetches != 1 {
+		t.Fatalf("expected cached keys to be reused, got %d fetches", fetches)
+	}
+
+	mu.Lock()
+	suffix = "-v2"
+	mu.Unlock()
+	principal, err := verifier.Verify(signer.sign(t, "RS256", "rsa-v2", validClaims("editor")))
+	if err != nil {
+		t.Fatalf("verify with rotated key failed: %v", err)
+	}
+	if principal.Role != auth.Editor || principal.Subject != "jwt:user-1" || fetches != 2 {
+		t.Fatalf("unexpected principal %+v after %d fetches", principal, fetches)
+	}
+}
+
+// TestJWKSRefreshDoesNotBlockCachedKeys 拉取 JWKS 期间，使用已缓存密钥的请求不被阻塞；
+// 并发的未知 kid 共享同一次拉取，之后的未知 kid 受 MinRefreshInterval 限制。
+func TestJWKSRefreshDoesNotBlockCachedKeys(t *testing.T) {
+	signer := newTestSigner(t)
+
+	var mu sync.Mutex
+	suffix := "-v1"
+	fetches := 0
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		current := suffix
+		mu.Unlock()
+		if current != "-v1" {
+			started <- struct{}{}
+			<-release
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write(signer.jwks(current))
+	}))
+	defer server.Close()
+
+	keys, err := auth.LoadKeySet(server.URL)
+	if err != nil {
+		t.Fatalf("load jwks failed: %v", err)
+	}
+	keys.MinRefreshInterval = 0
+	verifier := &auth.JWTVerifier{Keys: keys}
+
+	mu.Lock()
+	suffix = "-v2"
+	mu.Unlock()
+	rotated := signer.sign(t, "RS256", "rsa-v2", validClaims("viewer"))
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	verify := func() {
+		defer wg.Done()
+		_, err := verifier.Verify(rotated)
+		errs <- err
+	}
+	wg.Add(1)
+	go verify()
+	<-started
+
+	// 拉取仍在进行：已缓存的密钥照常可用。
+	done := make(chan error, 1)
+	go func() {
+		_, err := verifier.Verify(signer.sign(t, "ES256", "ec-v1", validClaims("viewer")))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("verify with cached key failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		close(release)
+		t.Fatal("verify with a cached key was blocked by the JWKS fetch")
+	}
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go verify()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("verify with rotated key failed: %v", err)
+		}
+	}
+	if fetches != 2 {
+		t.Fatalf("concurrent unknown kids should share one fetch, got %d fetches", fetches)
+	}
+
+	keys.MinRefreshInterval = time.Hour
+	if _, err := verifier.Verify(signer.sign(t, "RS256", "rsa-v3", validClaims("viewer"))); err == nil {
+		t.Fatal("expected an unknown kid to be rejected")
+	}
+	if fetches != 2 {
+		t.Fatalf("refreshes for unknown kids should be rate limited, got %d fetches", fetches)
+	}
+}
+
+// TestJWKSExpiredCacheRefreshesInBackground 缓存过期后在后台重新加载：即使 JWKS 地址响应很慢，
+// 使用已缓存密钥的请求也不等待加载，加载期间的请求不会重复拉取。
+func TestJWKSExpiredCacheRefreshesInBackground(t *testing.T) {
+	signer := newTestSigner(t)
+
+	var mu sync.Mutex
+	slow := false
+	fetches := 0
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		wait := slow
+		mu.Unlock()
+		if wait {
+			started <- struct{}{}
+			<-release
+		}
+		w.Write(signer.jwks("-v1"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	keys, err := auth.LoadKeySet(server.URL)
+	if err != nil {
+		t.Fatalf("load jwks failed: %v", err)
+	}
+	// 重新加载一次，使缓存立即过期。
+	keys.RefreshInterval = time.Nanosecond
+	if err := keys.Refresh(); err != nil {
+		t.Fatalf("refresh jwks failed: %v", err)
+	}
+	keys.MinRefreshInterval = 0
+	verifier := &auth.JWTVerifier{Keys: keys}
+
+	mu.Lock()
+	slow = true
+	mu.Unlock()
+	token := signer.sign(t, "RS256", "rsa-v1", validClaims("viewer"))
+	for i := 0; i < 3; i++ {
+		done := make(chan error, 1)
+		go func() {
+			_, err := verifier.Verify(token)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("verify with an expired cached key failed: %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("verify with an expired cached key waited for the JWKS fetch")
+		}
+	}
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the expired cache to be refreshed in the background")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fetches != 3 {
+		t.Fatalf("requests during a refresh should share it, got %d fetches", fetches)
+	}
+}
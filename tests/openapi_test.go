@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/handlers"
+)
+
+type openAPIDoc struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadOpenAPI(t *testing.T) (openAPIDoc, []byte) {
+	t.Helper()
+
+	w := serve(httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("expected JSON spec, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var doc openAPIDoc
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.1") {
+		t.Fatalf("expected OpenAPI 3.1, got %q", doc.OpenAPI)
+	}
+	return doc, w.Body.Bytes()
+}
+
+// TestOpenAPICoversRoutes RegisterRoutes 注册的每个路由都必须出现在 OpenAPI 文档中：
+// 精确路由要有同名的 path，以 "/" 结尾的前缀路由要至少有一个带路径参数的 path。
+func TestOpenAPICoversRoutes(t *testing.T) {
+	defer setupAuth(t)()
+	doc, _ := loadOpenAPI(t)
+
+	for _, pattern := range handlers.Routes() {
+		if !strings.HasSuffix(pattern, "/") {
+			if _, ok := doc.Paths[pattern]; !ok {
+				t.Errorf("route %s is missing from the OpenAPI spec", pattern)
+			}
+			continue
+		}
+		covered := false
+		for path := range doc.Paths {
+			if strings.HasPrefix(path, pattern+"{") {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Errorf("route %s{...} is missing from the OpenAPI spec", pattern)
+		}
+	}
+}
+
+// TestOpenAPIPathsAreRouted 文档中的每个 path 都要落到已注册的路由上，不能描述不存在的接口。
+func TestOpenAPIPathsAreRouted(t *testing.T) {
+	defer setupAuth(t)()
+	doc, _ := loadOpenAPI(t)
+
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux)
+	registered := map[string]bool{}
+	for _, pattern := range handlers.Routes() {
+		registered[pattern] = true
+	}
+
+	param := regexp.MustCompile(`\{[^}]+\}`)
+	for path, operations := range doc.Paths {
+		target := param.ReplaceAllString(path, "1")
+		if _, pattern := mux.Handler(httptest.NewRequest("GET", target, nil)); !registered[pattern] {
+			t.Errorf("spec path %s is not handled by any API route (matched %q)", path, pattern)
+		}
+		for method := range operations {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "parameters":
+			default:
+				t.Errorf("unexpected key %q under %s", method, path)
+			}
+		}
+	}
+}
+
+// TestOpenAPIRefsResolve 文档中的 $ref 都要指向 components 中已定义的对象。
+func TestOpenAPIRefsResolve(t *testing.T) {
+	defer setupAuth(t)()
+	_, raw := loadOpenAPI(t)
+
+	var spec map[string]any
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	components, _ := spec["components"].(map[string]any)
+
+	for _, match := range regexp.MustCompile(`"\$ref":\s*"#/components/([^/"]+)/([^"]+)"`).FindAllStringSubmatch(string(raw), -1) {
+		section, _ := components[match[1]].(map[string]any)
+		if _, ok := section[match[2]]; !ok {
+			t.Errorf("unresolved $ref #/components/%s/%s", match[1], match[2])
+		}
+	}
+}
+
+func TestSwaggerUI(t *testing.T) {
+	defer setupAuth(t)()
+
+	if w := serve(httptest.NewRequest("GET", "/docs", nil)); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/docs/" {
+		t.Fatalf("expected redirect to /docs/, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// 文档页面不需要认证；CSP 放宽到允许同源脚本。
+	w := serve(httptest.NewRequest("GET", "/docs/", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "swagger-ui") {
+		t.Fatalf("expected Swagger UI page, got %d", w.Code)
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'self'") {
+		t.Fatalf("unexpected CSP for docs: %q", csp)
+	}
+
+	w = serve(httptest.NewRequest("GET", "/docs/swagger-initializer.js", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/api/openapi.json") {
+		t.Fatalf("initializer should point at the spec, got %d %s", w.Code, w.Body.String())
+	}
+
+	// API 路由仍然使用严格的 CSP。
+	if csp := serve(httptest.NewRequest("GET", "/api/health", nil)).Header().Get("Content-Security-Policy"); strings.Contains(csp, "script-src") {
+		t.Fatalf("API responses must keep the strict CSP, got %q", csp)
+	}
+}
+
+// TestRequestValidationParameters 参数按 OpenAPI 文档校验，错误码取参数的 x-error-code。
+func TestRequestValidationParameters(t *testing.T) {
+	defer setupTestDB()()
+
+	cases := []struct {
+		target string
+		status int
+		code   apierror.Code
+	}{
+		{"/api/products?limit=abc", http.StatusBadRequest, apierror.InvalidPagination},
+		{"/api/products?limit=101", http.StatusBadRequest, apierror.InvalidPagination},
+		{"/api/products?offset=1.5", http.StatusBadRequest, apierror.InvalidPagination},
+		{"/api/products/search", http.StatusBadRequest, apierror.InvalidParameter},
+		{"/api/products/export?format=pdf", http.StatusBadRequest, apierror.InvalidParameter},
+		{"/api/products/export?offset=-1", http.StatusBadRequest, apierror.InvalidPagination},
+		{"/api/products/export?async=maybe", http.StatusBadRequest, apierror.InvalidParameter},
+		{"/api/jobs/abc/cancel", http.StatusBadRequest, apierror.InvalidID},
+	}
+	for _, tc := range cases {
+		method := "GET"
+		if strings.HasSuffix(tc.target, "/cancel") {
+			method = "POST"
+		}
+		body := expectError(t, serve(httptest.NewRequest(method, tc.target, nil)), tc.status, tc.code)
+		if tc.target == "/api/products/search" && body.Message != "name is required" {
+			t.Fatalf("unexpected message for missing parameter: %q", body.Message)
+		}
+	}
+
+	// 未在文档中声明的 query 参数（例如 lang）不受影响。
+	if w := serve(httptest.NewRequest("GET", "/api/products?limit=5&lang=en&foo=bar", nil)); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestRequestValidationBody 请求体按 schema 校验，类型错误也以字段错误的形式返回。
+func TestRequestValidationBody(t *testing.T) {
+	defer setupTestDB()()
+
+	body := decodeValidationError(t, serve(tenantRequest("POST", "/api/products", "", `{"name":"A","price":"cheap","stock":1.5}`)))
+	got := map[string]string{}
+	for _, fe := range body.Errors {
+		got[fe.Field] = fe.Code
+	}
+	if got["price"] != "type" || got["stock"] != "type" || len(got) != 2 {
+		t.Fatalf("unexpected field errors: %+v", body.Errors)
+	}
+
+	// 非法 JSON 仍然是 400。
+	expectError(t, serve(tenantRequest("POST", "/api/products/bulk", "", `[{"name":`)), http.StatusBadRequest, apierror.InvalidJSON)
+}
+
+func TestRequestValidationAdminBodies(t *testing.T) {
+	defer setupAuth(t)()
+	admin := createTestKey(t, auth.Admin)
+
+	body := decodeValidationError(t, serve(requestWithKey("POST", "/api/admin/keys", admin, `{"name":"ci","role":"root","scope":"all"}`)))
+	got := []string{}
+	for _, fe := range body.Errors {
+		got = append(got, fe.Field+":"+fe.Code)
+	}
+	if strings.Join(got, ",") != "role:oneof,scope:unknown" {
+		t.Fatalf("unexpected field errors: %v", got)
+	}
+
+	expectError(t, serve(requestWithKey("GET", "/api/admin/tenants/Not_Valid", admin, "")), http.StatusBadRequest, apierror.InvalidTenant)
+	decodeValidationError(t, serve(requestWithKey("PUT", "/api/admin/tenants/acme", admin, `{"max_products":-1}`)))
+}
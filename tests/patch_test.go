@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-starter/models"
+	"golang-starter/patch"
+	"golang-starter/utils"
+)
+
+func patchProduct(id int, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PATCH", fmt.Sprintf("/api/products/%d", id), strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return serve(req)
+}
+
+func TestMergePatchStockToZero(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	id := createProductWithName(t, "SellOut")
+
+	w := patchProduct(id, "application/merge-patch+json", `{"stock":0}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	product, err := models.GetProductByID(context.Background(), utils.DB, models.DefaultTenant, id)
+	if err != nil {
+		t.Fatalf("get product failed: %v", err)
+	}
+	if product.Stock != 0 || product.Name != "SellOut" || product.Price != 99.99 {
+		t.Fatalf("expected only stock changed to 0, got %+v", product)
+	}
+}
+
+func TestMergePatchInvalidResultRejected(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	id := createProductWithName(t, "Named")
+
+	if w := patchProduct(id, "application/merge-patch+json", `{"name":null}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	if w := patchProduct(id, "application/merge-patch+json", `{"price":-1}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	if w := patchProduct(id, "application/merge-patch+json", `{"id":12345}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for read-only field, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	if w := patchProduct(id, "application/merge-patch+json", `{"color":"red"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for unknown field, got %d. Body: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+func TestJSONPatchWithTest(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	id := createProductWithName(t, "Guarded")
+
+	ops := `[
+		{"op":"test","path":"/stock","value":10},
+		{"op":"replace","path":"/stock","value":0},
+		{"op":"replace","path":"/name","value":"Sold out"}
+	]`
+	w := patchProduct(id, "application/json-patch+json", ops)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var result struct {
+		Data models.Product `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response json: %v", err)
+	}
+	if result.Data.Stock != 0 || result.Data.Name != "Sold out" {
+		t.Fatalf("unexpected patched product: %+v", result.Data)
+	}
+
+	// test 失败：整个补丁不生效，返回 409。
+	w = patchProduct(id, "application/json-patch+json", `[{"op":"test","path":"/stock","value":10},{"op":"replace","path":"/stock","value":5}]`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	product, err := models.GetProductByID(context.Background(), utils.DB, models.DefaultTenant, id)
+	if err != nil {
+		t.Fatalf("get product failed: %v", err)
+	}
+	if product.Stock != 0 {
+		t.Fatalf("expected stock unchanged after failed test, got %d", product.Stock)
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	id := createProductWithName(t, "Target")
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"unknown op", `[{"op":"frobnicate","path":"/stock"}]`, http.StatusBadRequest},
+		{"missing path", `[{"op":"replace","path":"/missing/x","value":1}]`, http.StatusUnprocessableEntity},
+		{"remove required field", `[{"op":"remove","path":"/name"}]`, http.StatusUnprocessableEntity},
+		{"not an array", `{"op":"remove","path":"/name"}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		if w := patchProduct(id, "application/json-patch+json", tc.body); w.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d. Body: %s", tc.name, tc.status, w.Code, w.Body.String())
+		}
+	}
+
+	if w := patchProduct(id, "text/plain", `{"stock":1}`); w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected status %d, got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+}
+
+func TestPatchPackageOperations(t *testing.T) {
+	doc, err := patch.Decode([]byte(`{"a":{"b":[1,2,3]},"c":"x"}`))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	ops, err := patch.DecodeOperations([]byte(`[
+		{"op":"add","path":"/a/b/-","value":4},
+		{"op":"remove","path":"/a/b/0"},
+		{"op":"copy","from":"/c","path":"/d"},
+		{"op":"move","from":"/c","path":"/a~1e"},
+		{"op":"test","path":"/a/b","value":[2,3,4.0]}
+	]`))
+	if err != nil {
+		t.Fatalf("decode operations failed: %v", err)
+	}
+
+	got, err := patch.ApplyOperations(doc, ops)
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	want, _ := patch.Decode([]byte(`{"a":{"b":[2,3,4]},"d":"x","a/e":"x"}`))
+	if !patch.Equal(got, want) {
+		t.Fatalf("unexpected result: %v", got)
+	}
+
+	merged := patch.MergePatch(want, map[string]any{"a": map[string]any{"b": nil}, "d": nil, "z": json.Number("1")})
+	wantMerged, _ := patch.Decode([]byte(`{"a":{},"a/e":"x","z":1}`))
+	if !patch.Equal(merged, wantMerged) {
+		t.Fatalf("unexpected merge result: %v", merged)
+	}
+
+	// path 为 "" 的 replace 替换整个文档。
+	ops, err = patch.DecodeOperations([]byte(`[{"op":"replace","path":"","value":{"root":true}}]`))
+	if err != nil {
+		t.Fatalf("decode operations failed: %v", err)
+	}
+	replaced, err := patch.ApplyOperations(doc, ops)
+	if err != nil {
+		t.Fatalf("replace root failed: %v", err)
+	}
+	if wantRoot, _ := patch.Decode([]byte(`{"root":true}`)); !patch.Equal(replaced, wantRoot) {
+		t.Fatalf("unexpected root replacement: %v", replaced)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-starter/apierror"
+	"golang-starter/validation"
+)
+
+type problemBody struct {
+	Type      string                  `json:"type"`
+	Title     string                  `json:"title"`
+	Status    int                     `json:"status"`
+	Detail    string                  `json:"detail"`
+	Instance  string                  `json:"instance"`
+	ErrorCode apierror.Code           `json:"error_code"`
+	Errors    []validation.FieldError `json:"errors"`
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder, status int) problemBody {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d. Body: %s", status, w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+		t.Fatalf("expected problem+json content type, got %q", ct)
+	}
+	var body problemBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode problem json: %v", err)
+	}
+	return body
+}
+
+func TestProblemJSONNotFound(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	req := httptest.NewRequest("GET", "/api/products/99999999", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	body := decodeProblem(t, serve(req), http.StatusNotFound)
+
+	if body.Type != "/problems/product-not-found" || body.Title != "Not Found" || body.Status != http.StatusNotFound {
+		t.Fatalf("unexpected problem: %+v", body)
+	}
+	if body.Detail != "product not found" || body.Instance != "/api/products/99999999" || body.ErrorCode != apierror.ProductNotFound {
+		t.Fatalf("unexpected problem: %+v", body)
+	}
+}
+
+func TestProblemJSONValidationErrors(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	req := httptest.NewRequest("POST", "/api/products", strings.NewReader(`{"name":"","price":0,"stock":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/problem+json, application/json;q=0.9")
+	body := decodeProblem(t, serve(req), http.StatusUnprocessableEntity)
+
+	if body.ErrorCode != apierror.ValidationFailed || len(body.Errors) != 2 {
+		t.Fatalf("unexpected problem: %+v", body)
+	}
+	if body.Errors[0].Field != "name" || body.Errors[1].Field != "price" {
+		t.Fatalf("unexpected field errors: %+v", body.Errors)
+	}
+}
+
+// TestProblemJSONIsOptIn 未明确要求 problem+json 时保持默认错误格式。
+func TestProblemJSONIsOptIn(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	for _, accept := range []string{"", "*/*", "application/json", "application/json, application/problem+json;q=0.5", "application/problem+json;q=0"} {
+		req := httptest.NewRequest("GET", "/api/products/99999999", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := serve(req)
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Fatalf("Accept %q: expected application/json, got %q", accept, ct)
+		}
+		expectError(t, w, http.StatusNotFound, apierror.ProductNotFound)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang-starter/auth"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+var (
+	binariesMu sync.Mutex
+	binaries   = map[string]string{}
+)
+
+// buildBinary 编译 cmd/ 下的命令（每个包只编译一次，供所有用例共用）；二进制放在系统临时目录。
+func buildBinary(t *testing.T, name string) string {
+	t.Helper()
+
+	binariesMu.Lock()
+	defer binariesMu.Unlock()
+	if path, ok := binaries[name]; ok {
+		return path
+	}
+	dir, err := os.MkdirTemp("", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if out, err := exec.Command("go", "build", "-o", path, "golang-starter/cmd/"+name).CombinedOutput(); err != nil {
+		t.Fatalf("build %s: %v: %s", name, err, out)
+	}
+	binaries[name] = path
+	return path
+}
+
+// runBinary 运行命令，返回 stdout、stderr 与退出码。
+func runBinary(t *testing.T, cmd *exec.Cmd, stdin string) (string, string, int) {
+	t.Helper()
+
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("run %s: %v", cmd.Path, err)
+	}
+	return stdout.String(), stderr.String(), code
+}
+
+// productctl 运行 productctl，返回 stdout、stderr 与退出码。
+func productctl(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+
+	cmd := exec.Command(buildBinary(t, "productctl"), args...)
+	cmd.Env = append(os.Environ(), "PRODUCTCTL_SERVER=", "PRODUCTCTL_API_KEY=", "PRODUCTCTL_DB=")
+	return runBinary(t, cmd, stdin)
+}
+
+func decodeProducts(t *testing.T, out string) []models.Product {
+	t.Helper()
+
+	var products []models.Product
+	if err := json.Unmarshal([]byte(out), &products); err != nil {
+		t.Fatalf("decode products: %v\n%s", err, out)
+	}
+	return products
+}
+
+func TestProductctlLocal(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "products.db")
+	run := func(stdin string, args ...string) (string, string, int) {
+		return productctl(t, stdin, append([]string{"-db", db}, args...)...)
+	}
+
+	if _, stderr, code := run("", "migrate"); code != 0 || !strings.Contains(stderr, "up to date") {
+		t.Fatalf("migrate failed (%d): %s", code, stderr)
+	}
+
+	out, stderr, code := run("", "create", "-name", "Widget", "-price", "9.5", "-stock", "3", "-o", "json")
+	var created models.Product
+	if code != 0 || json.Unmarshal([]byte(out), &created) != nil || created.ID == 0 {
+		t.Fatalf("create failed (%d): %s %s", code, out, stderr)
+	}
+
+	// 导入 CSV：表头大小写与列顺序不敏感，多余的列忽略。
+	csvInput := "Stock,Name,Price,ID\n5,Gadget,20,99\n0,\"Widget, large\",12.5,\n"
+	if _, stderr, code := run(csvInput, "import", "-"); code != 0 || !strings.Contains(stderr, "imported 2 products") {
+		t.Fatalf("import failed (%d): %s", code, stderr)
+	}
+
+	out, _, _ = run("", "list", "-o", "json", "-order", "id_desc")
+	if products := decodeProducts(t, out); len(products) != 3 || products[0].Name != "Widget, large" {
+		t.Fatalf("unexpected list: %+v", products)
+	}
+
+	// update 只修改传入的字段；flag 可以写在 id 之后。
+	out, _, code = run("", "update", "1", "-stock", "0", "-o", "yaml")
+	if code != 0 || !strings.Contains(out, "stock: 0") || !strings.Contains(out, "price: 9.5") {
+		t.Fatalf("unexpected update output (%d): %s", code, out)
+	}
+
+	out, _, _ = run("", "search", "Widget")
+	if !strings.HasPrefix(out, "ID") || strings.Count(out, "\n") != 3 {
+		t.Fatalf("unexpected table output:\n%s", out)
+	}
+
+	out, _, code = run("", "export")
+	if code != 0 || !strings.HasPrefix(out, "id,name,price,stock,created_at,updated_at\n") || !strings.Contains(out, `"Widget, large",12.5,0`) {
+		t.Fatalf("unexpected export (%d):\n%s", code, out)
+	}
+
+	backup := filepath.Join(t.TempDir(), "backup.db")
+	if _, stderr, code := run("", "backup", backup); code != 0 {
+		t.Fatalf("backup failed (%d): %s", code, stderr)
+	}
+	out, _, _ = productctl(t, "", "-db", backup, "-o", "json", "list")
+	if products := decodeProducts(t, out); len(products) != 3 {
+		t.Fatalf("backup should contain 3 products, got %d", len(products))
+	}
+
+	if _, _, code := run("", "delete", "1"); code != 0 {
+		t.Fatalf("delete failed (%d)", code)
+	}
+	if _, stderr, code := run("", "get", "1"); code != 1 || !strings.Contains(stderr, "product not found") {
+		t.Fatalf("expected not found (%d): %s", code, stderr)
+	}
+}
+
+func TestProductctlErrors(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "products.db")
+
+	cases := []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{[]string{"bogus"}, 2, `unknown command "bogus"`},
+		{[]string{"get"}, 2, "Usage: productctl"},
+		{[]string{"get", "abc"}, 1, `invalid product id "abc"`},
+		{[]string{"-o", "xml", "list"}, 2, `unknown output format "xml"`},
+		{[]string{"create", "-price", "0"}, 1, "name is required"},
+		{[]string{"update", "1"}, 1, "no fields to update"},
+		{[]string{"-tenant", "Not_Valid", "list"}, 2, "invalid tenant"},
+	}
+	for _, tc := range cases {
+		_, stderr, code := productctl(t, "", append([]string{"-db", db}, tc.args...)...)
+		if code != tc.code || !strings.Contains(stderr, tc.stderr) {
+			t.Errorf("%v: expected exit %d with %q, got %d: %s", tc.args, tc.code, tc.stderr, code, stderr)
+		}
+	}
+
+	// CSV 中任何一行不合法都不会写入，错误带行号。
+	_, stderr, code := productctl(t, "name,price\nOK,1\nBad,-1\n", "-db", db, "import", "-")
+	if code != 1 || !strings.Contains(stderr, "line 3:") {
+		t.Fatalf("expected line error, got %d: %s", code, stderr)
+	}
+	if out, _, _ := productctl(t, "", "-db", db, "-o", "json", "list"); len(decodeProducts(t, out)) != 0 {
+		t.Fatalf("invalid import must not write anything: %s", out)
+	}
+}
+
+func TestProductctlRemote(t *testing.T) {
+	defer setupAuth(t)()
+	srv := newTestServer(t, nil)
+	_, key, err := auth.CreateKey(context.Background(), utils.DB, "acme editor", auth.Editor, "acme", false)
+	if err != nil {
+		t.Fatalf("create key failed: %v", err)
+	}
+	remote := []string{"-server", srv.URL, "-api-key", key, "-tenant", "acme"}
+
+	if _, stderr, code := productctl(t, "name,price,stock\nRemote A,1,1\nRemote B,2,2\n", append(remote, "import", "-")...); code != 0 {
+		t.Fatalf("remote import failed (%d): %s", code, stderr)
+	}
+	out, stderr, code := productctl(t, "", append(remote, "list", "-o", "json")...)
+	if code != 0 || len(decodeProducts(t, out)) != 2 {
+		t.Fatalf("remote list failed (%d): %s %s", code, out, stderr)
+	}
+
+	// 错误码来自服务端。
+	if _, stderr, code := productctl(t, "", "-server", srv.URL, "list"); code != 1 || !strings.Contains(stderr, "UNAUTHORIZED") {
+		t.Fatalf("expected UNAUTHORIZED, got %d: %s", code, stderr)
+	}
+	if _, stderr, code := productctl(t, "", append(remote, "backup", "x.db")...); code != 1 || !strings.Contains(stderr, "direct database access") {
+		t.Fatalf("backup must be local only, got %d: %s", code, stderr)
+	}
+}
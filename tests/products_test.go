@@ -35,6 +35,8 @@ func clearProductsTable(t *testing.T) {
 func setupTestDB() func() {
 	// 初始化全局 DB（打开 SQLite 文件并建表）。
 	utils.InitDB()
+	// 大部分用例关注业务逻辑本身，不携带凭证；认证相关的用例会单独打开 AuthEnabled。
+	handlers.AuthEnabled = false
 	// 清空 products 表，避免不同测试/不同运行之间互相污染导致用例不稳定。
 	// 这里必须传入当前测试的 *testing.T 才能在失败时正确终止用例；
 	// setupTestDB 没有拿到 t，因此用 panic 的方式暴露错误（比静默忽略更安全）。
@@ -621,7 +623,7 @@ func TestBulkCreateProductsRollbackOnInvalidItem(t *testing.T) {
 	// 先插入一条基准数据，方便验证回滚不会改变总数（除了这条基准数据）。
 	createProductWithName(t, "Baseline")
 
-	// 第二个元素非法（price<=0），期望整体 400，且不会插入第一条合法数据。
+	// 第二个元素非法（price<=0），期望整体 422，且不会插入第一条合法数据。
 	payload := `[{"name":"Good","price":10.0,"stock":1},{"name":"Bad","price":0,"stock":1}]`
 	req := httptest.NewRequest("POST", "/api/products/bulk", strings.NewReader(payload))
 	req.Header.Set("Content-Type", "application/json")
@@ -634,9 +636,9 @@ func TestBulkCreateProductsRollbackOnInvalidItem(t *testing.T) {
 	resp := w.Result()
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusBadRequest {
+	if resp.StatusCode != http.StatusUnprocessableEntity {
 		body, _ := io.ReadAll(resp.Body)
-		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusBadRequest, resp.StatusCode, body)
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusUnprocessableEntity, resp.StatusCode, body)
 	}
 
 	// 再拉全量列表，确保只存在 Baseline，不存在 Good。
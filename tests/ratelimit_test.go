@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/handlers"
+	"golang-starter/ratelimit"
+)
+
+// setupRateLimit 打开限流；返回的函数恢复为不限流。
+func setupRateLimit(rate, burst float64) func() {
+	handlers.RateLimiter = ratelimit.New(rate, burst)
+	return func() {
+		handlers.RateLimiter = nil
+	}
+}
+
+func requestFrom(method, target, remoteAddr, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func TestRateLimitByClientIP(t *testing.T) {
+	defer setupTestDB()()
+	defer setupRateLimit(1, 3)()
+
+	for _, remaining := range []string{"2", "1", "0"} {
+		w := serve(requestFrom("GET", "/api/products", "192.0.2.1:1234", ""))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if w.Header().Get("X-RateLimit-Limit") != "3" || w.Header().Get("X-RateLimit-Remaining") != remaining {
+			t.Fatalf("unexpected rate limit headers: %v", w.Header())
+		}
+	}
+
+	w := serve(requestFrom("GET", "/api/products", "192.0.2.1:5678", ""))
+	expectError(t, w, http.StatusTooManyRequests, apierror.RateLimited)
+	if w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected Retry-After 1, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// 其他客户端有自己的额度；健康检查不限流。
+	if w := serve(requestFrom("GET", "/api/products", "192.0.2.2:1234", "")); w.Code != http.StatusOK {
+		t.Fatalf("other client should not be limited, got %d", w.Code)
+	}
+	if w := serve(requestFrom("GET", "/api/health", "192.0.2.1:1234", "")); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatalf("health check should not be rate limited, got %d", w.Code)
+	}
+}
+
+func TestRateLimitRouteCosts(t *testing.T) {
+	defer setupTestDB()()
+	defer setupRateLimit(1, 20)()
+
+	bulk := `[{"name":"A","price":1,"stock":1}]`
+	if w := serve(requestFrom("POST", "/api/products/bulk", "192.0.2.1:1", bulk)); w.Code != http.StatusCreated || w.Header().Get("X-RateLimit-Remaining") != "10" {
+		t.Fatalf("bulk should cost 10 tokens: %d %v", w.Code, w.Header())
+	}
+	if w := serve(requestFrom("GET", "/api/products/export?format=csv", "192.0.2.1:1", "")); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != "5" {
+		t.Fatalf("export should cost 5 tokens: %d %v", w.Code, w.Header())
+	}
+
+	w := serve(requestFrom("POST", "/api/products/bulk", "192.0.2.1:1", bulk))
+	expectError(t, w, http.StatusTooManyRequests, apierror.RateLimited)
+	if w.Header().Get("Retry-After") != "5" {
+		t.Fatalf("expected Retry-After 5, got %q", w.Header().Get("Retry-After"))
+	}
+	// 便宜的请求仍然可以通过。
+	if w := serve(requestFrom("GET", "/api/products", "192.0.2.1:1", "")); w.Code != http.StatusOK {
+		t.Fatalf("cheap request should still pass, got %d", w.Code)
+	}
+}
+
+// TestRateLimitByAPIKey 已认证的请求按密钥计数，同一个 IP 上的不同密钥互不影响。
+func TestRateLimitByAPIKey(t *testing.T) {
+	defer setupAuth(t)()
+	defer setupRateLimit(1, 1)()
+
+	first := createTestKey(t, auth.Viewer)
+	second := createTestKey(t, auth.Viewer)
+
+	if w := serve(requestWithKey("GET", "/api/products", first, "")); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	expectError(t, serve(requestWithKey("GET", "/api/products", first, "")), http.StatusTooManyRequests, apierror.RateLimited)
+	if w := serve(requestWithKey("GET", "/api/products", second, "")); w.Code != http.StatusOK {
+		t.Fatalf("second key should have its own bucket, got %d", w.Code)
+	}
+}
+
+// TestIPRateLimitBeforeAuth 错误的凭证在认证之前按 IP 限流；开启 TrustProxyHeaders 时按 X-Forwarded-For 区分客户端。
+func TestIPRateLimitBeforeAuth(t *testing.T) {
+	defer setupAuth(t)()
+	handlers.IPRateLimiter = ratelimit.New(1, 2)
+	defer func() { handlers.IPRateLimiter = nil }()
+
+	badKey := func(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		req := requestFrom("GET", "/api/products", remoteAddr, "")
+		req.Header.Set("X-API-Key", "not-a-valid-key")
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return serve(req)
+	}
+
+	for i := 0; i < 2; i++ {
+		expectError(t, badKey("192.0.2.1:1", ""), http.StatusUnauthorized, apierror.Unauthorized)
+	}
+	w := badKey("192.0.2.1:2", "")
+	expectError(t, w, http.StatusTooManyRequests, apierror.RateLimited)
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Fatalf("unexpected rate limit headers: %v", w.Header())
+	}
+	// 同一个 IP 上的有效凭证同样被拒绝：限流发生在认证之前。
+	key := createTestKey(t, auth.Viewer)
+	req := requestWithKey("GET", "/api/products", key, "")
+	req.RemoteAddr = "192.0.2.1:3"
+	expectError(t, serve(req), http.StatusTooManyRequests, apierror.RateLimited)
+
+	// 不信任代理头时，伪造 X-Forwarded-For 不能换一个桶。
+	expectError(t, badKey("192.0.2.1:4", "198.51.100.7"), http.StatusTooManyRequests, apierror.RateLimited)
+
+	handlers.TrustProxyHeaders = true
+	defer func() { handlers.TrustProxyHeaders = false }()
+	expectError(t, badKey("192.0.2.1:5", "198.51.100.7"), http.StatusUnauthorized, apierror.Unauthorized)
+}
+
+func TestLimiterRefills(t *testing.T) {
+	limiter := ratelimit.New(1000, 1)
+
+	if !limiter.Allow("client", 1).Allowed {
+		t.Fatalf("first request should be allowed")
+	}
+	if d := limiter.Allow("client", 1); d.Allowed || d.RetryAfter <= 0 {
+		t.Fatalf("bucket should be empty, got %+v", d)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !limiter.Allow("client", 1).Allowed {
+		t.Fatalf("bucket should have refilled")
+	}
+}
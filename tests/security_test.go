@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-starter/apierror"
+	"golang-starter/handlers"
+)
+
+// setupCORS 允许 origin 跨域访问；返回的函数恢复为不允许跨域。
+func setupCORS(origins ...string) func() {
+	handlers.CORSAllowedOrigins = origins
+	return func() {
+		handlers.CORSAllowedOrigins = nil
+		handlers.CORSAllowCredentials = false
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	defer setupTestDB()()
+
+	w := serve(httptest.NewRequest("GET", "/api/products", nil))
+	for header, want := range map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        "no-referrer",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s: expected %q, got %q", header, want, got)
+		}
+	}
+	if w.Header().Get("Content-Security-Policy") == "" {
+		t.Errorf("expected Content-Security-Policy header")
+	}
+	// 明文 HTTP 不输出 HSTS。
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("HSTS must only be sent over HTTPS")
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	defer setupAuth(t)()
+	defer setupCORS("https://admin.example.test")()
+
+	// 预检请求不需要认证。
+	req := httptest.NewRequest("OPTIONS", "/api/products", nil)
+	req.Header.Set("Origin", "https://admin.example.test")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type, x-api-key")
+	w := serve(req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://admin.example.test" ||
+		!strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), "PATCH") ||
+		!strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "X-API-Key") ||
+		w.Header().Get("Access-Control-Max-Age") == "" {
+		t.Fatalf("unexpected preflight headers: %v", w.Header())
+	}
+
+	// 不在允许列表中的来源拿不到 CORS 头。
+	req = httptest.NewRequest("OPTIONS", "/api/products", nil)
+	req.Header.Set("Origin", "https://evil.example.test")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	if w := serve(req); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("origin should not be allowed: %v", w.Header())
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	defer setupTestDB()()
+	defer setupCORS("*")()
+
+	req := httptest.NewRequest("GET", "/api/products", nil)
+	req.Header.Set("Origin", "https://anywhere.example.test")
+	w := serve(req)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("expected wildcard origin, got %d %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "X-RateLimit-Remaining") {
+		t.Fatalf("expected exposed headers, got %q", w.Header().Get("Access-Control-Expose-Headers"))
+	}
+
+	// 允许携带凭证时回显具体来源，不能使用 "*"。
+	handlers.CORSAllowCredentials = true
+	w = serve(req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://anywhere.example.test" || w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("expected echoed origin with credentials, got %v", w.Header())
+	}
+}
+
+func TestRequestBodyTooLarge(t *testing.T) {
+	defer setupTestDB()()
+	defaultLimit, bulkLimit := handlers.BodyLimits["default"], handlers.BodyLimits["bulk"]
+	defer func() {
+		handlers.BodyLimits["default"], handlers.BodyLimits["bulk"] = defaultLimit, bulkLimit
+	}()
+	handlers.BodyLimits["default"] = 64
+	handlers.BodyLimits["bulk"] = 256
+
+	large := `{"name":"` + strings.Repeat("x", 100) + `","price":1,"stock":1}`
+	// 声明了 Content-Length 的请求直接拒绝。
+	expectError(t, serve(tenantRequest("POST", "/api/products", "", large)), http.StatusRequestEntityTooLarge, apierror.PayloadTooLarge)
+
+	// 未声明长度（分块传输）的请求在读取时超出上限。
+	req := tenantRequest("POST", "/api/products", "", "")
+	req.Body = io.NopCloser(strings.NewReader(large))
+	req.ContentLength = -1
+	expectError(t, serve(req), http.StatusRequestEntityTooLarge, apierror.PayloadTooLarge)
+
+	// 批量创建的上限更高；幂等中间件读取请求体时同样受限。
+	if w := serve(tenantRequest("POST", "/api/products/bulk", "", "["+large+"]")); w.Code != http.StatusCreated {
+		t.Fatalf("bulk body under its limit should pass, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	req = tenantRequest("POST", "/api/products/bulk", "", "")
+	req.Body = io.NopCloser(strings.NewReader("[" + large + "," + large + "," + large + "]"))
+	req.ContentLength = -1
+	req.Header.Set("Idempotency-Key", "too-large")
+	expectError(t, serve(req), http.StatusRequestEntityTooLarge, apierror.PayloadTooLarge)
+}
+
+func TestRejectUnknownFields(t *testing.T) {
+	defer setupTestDB()()
+	defer func() { handlers.RejectUnknownFields = false }()
+
+	body := `{"name":"Strict","price":1,"stock":1,"colour":"red"}`
+	if w := serve(tenantRequest("POST", "/api/products", "", body)); w.Code != http.StatusCreated {
+		t.Fatalf("unknown fields are ignored by default, got %d", w.Code)
+	}
+
+	handlers.RejectUnknownFields = true
+	expectError(t, serve(tenantRequest("POST", "/api/products", "", body)), http.StatusBadRequest, apierror.InvalidJSON)
+	expectError(t, serve(tenantRequest("POST", "/api/products/bulk", "", "["+body+"]")), http.StatusBadRequest, apierror.InvalidJSON)
+}
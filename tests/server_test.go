@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang-starter/version"
+)
+
+// TestVersionEndpoint /api/version 不需要认证，返回构建信息。
+func TestVersionEndpoint(t *testing.T) {
+	defer setupAuth(t)()
+
+	w := serve(httptest.NewRequest("GET", "/api/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data version.Info `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.Data.Version != version.Version || body.Data.GoVersion == "" {
+		t.Fatalf("unexpected version info: %+v", body.Data)
+	}
+}
+
+// server 运行 cmd/server，环境变量只保留 PATH / HOME 与 env 中给出的值，避免受外部配置影响。
+func server(t *testing.T, env []string, args ...string) (string, string, int) {
+	t.Helper()
+
+	cmd := exec.Command(buildBinary(t, "server"), args...)
+	cmd.Env = append([]string{"PATH=" + os.Getenv("PATH"), "HOME=" + os.Getenv("HOME")}, env...)
+	return runBinary(t, cmd, "")
+}
+
+func TestServerCommandExitCodes(t *testing.T) {
+	out, _, code := server(t, nil, "version", "-json")
+	var info version.Info
+	if code != 0 || json.Unmarshal([]byte(out), &info) != nil || info.Version != "dev" {
+		t.Fatalf("unexpected version output (%d): %s", code, out)
+	}
+
+	if out, _, code := server(t, nil, "config-check"); code != 0 || !strings.Contains(out, "configuration OK") {
+		t.Fatalf("expected valid configuration (%d): %s", code, out)
+	}
+	// 所有无效的配置项一次性列出，退出码 2。
+	_, stderr, code := server(t, []string{"RATE_LIMIT_RPS=fast", "IDEMPOTENCY_TTL=-1s"}, "config-check")
+	if code != 2 || !strings.Contains(stderr, "RATE_LIMIT_RPS") || !strings.Contains(stderr, "IDEMPOTENCY_TTL") {
+		t.Fatalf("expected config error listing both variables (%d): %s", code, stderr)
+	}
+	if _, _, code := server(t, []string{"COMPRESSION_ENCODINGS=lz4"}, "serve"); code != 2 {
+		t.Fatalf("serve with invalid config should exit 2, got %d", code)
+	}
+
+	if _, _, code := server(t, nil, "bogus"); code != 2 {
+		t.Fatalf("unknown command should exit 2, got %d", code)
+	}
+	if _, _, code := server(t, nil, "backup"); code != 2 {
+		t.Fatalf("missing argument should exit 2, got %d", code)
+	}
+	if _, stderr, code := server(t, []string{"BACKUP_DIR=/tmp", "BACKUP_INTERVAL=daily"}, "config-check"); code != 2 || !strings.Contains(stderr, "BACKUP_INTERVAL") {
+		t.Fatalf("expected BACKUP_INTERVAL error (%d): %s", code, stderr)
+	}
+	if _, stderr, code := server(t, []string{"PRODUCT_CACHE_SIZE=-1", "PRODUCT_CACHE_TTL=0s"}, "config-check"); code != 2 || !strings.Contains(stderr, "PRODUCT_CACHE_SIZE") || !strings.Contains(stderr, "PRODUCT_CACHE_TTL") {
+		t.Fatalf("expected product cache configuration errors (%d): %s", code, stderr)
+	}
+	if _, stderr, code := server(t, []string{"DB_JOURNAL_MODE=fast", "DB_MAX_READ_CONNS=0"}, "migrate", "-db", filepath.Join(t.TempDir(), "products.db")); code != 2 || !strings.Contains(stderr, "journal mode") || !strings.Contains(stderr, "DB_MAX_READ_CONNS") {
+		t.Fatalf("expected database configuration error (%d): %s", code, stderr)
+	}
+
+	// 运行时失败（备份文件不是数据库）退出码 1。
+	db := filepath.Join(t.TempDir(), "products.db")
+	if _, _, code := server(t, nil, "restore", "-db", db, "-no-verify", buildBinary(t, "server")); code != 1 {
+		t.Fatalf("restoring an invalid backup should exit 1, got %d", code)
+	}
+}
+
+func TestServerSeedBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	db, backup := filepath.Join(dir, "products.db"), filepath.Join(dir, "backup.db.gz")
+	env := []string{"DB_PATH=" + db}
+
+	if out, stderr, code := server(t, env, "seed", "-count", "5"); code != 0 || !strings.Contains(out, "inserted 5 products") {
+		t.Fatalf("seed failed (%d): %s %s", code, out, stderr)
+	}
+	if _, stderr, code := server(t, env, "backup", backup); code != 0 {
+		t.Fatalf("backup failed (%d): %s", code, stderr)
+	}
+	if _, _, code := server(t, env, "backup", backup); code != 1 {
+		t.Fatalf("backup must not overwrite an existing file, got %d", code)
+	}
+	if _, stderr, code := server(t, env, "seed", "-count", "3"); code != 0 {
+		t.Fatalf("seed failed (%d): %s", code, stderr)
+	}
+
+	if out, _, code := server(t, nil, "verify", backup); code != 0 || !strings.Contains(out, "checksum OK") {
+		t.Fatalf("verify failed (%d): %s", code, out)
+	}
+	if out, stderr, code := server(t, env, "restore", backup); code != 0 || !strings.Contains(out, "restored") {
+		t.Fatalf("restore failed (%d): %s %s", code, out, stderr)
+	}
+	out, _, _ := productctl(t, "", "-db", db, "-o", "json", "list", "-limit", "100")
+	if products := decodeProducts(t, out); len(products) != 5 {
+		t.Fatalf("expected 5 products after restore, got %d", len(products))
+	}
+
+	// 校验文件丢失时默认拒绝恢复，-no-verify 显式跳过。
+	if err := os.Remove(backup + ".sha256"); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, code := server(t, env, "restore", backup); code != 1 || !strings.Contains(stderr, "checksum file not found") {
+		t.Fatalf("restore without a checksum file should fail (%d): %s", code, stderr)
+	}
+	if out, stderr, code := server(t, env, "restore", "-no-verify", backup); code != 0 || !strings.Contains(out, "checksum not verified") {
+		t.Fatalf("restore -no-verify failed (%d): %s %s", code, out, stderr)
+	}
+}
+
+// TestServerServeShutdown serve 启动后可以访问 /api/version，收到 SIGTERM 后优雅退出（退出码 0）。
+func TestServerServeShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cmd := exec.Command(buildBinary(t, "server"), "serve", "-addr", addr, "-db", filepath.Join(t.TempDir(), "products.db"))
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "ADMIN_API_KEY=gsk_serve_test_admin_key_0123456789"}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	var resp *http.Response
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if resp, err = http.Get("http://" + addr + "/api/version"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("server did not start: %v\n%s", err, stderr.String())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from /api/version, got %d", resp.StatusCode)
+	}
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("expected clean shutdown, got %v", err)
+	}
+}
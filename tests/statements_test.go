@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+func TestStatementCache(t *testing.T) {
+	defer setupTestDB()()
+	ctx := context.Background()
+
+	created, err := models.CreateProduct(ctx, utils.DB, models.DefaultTenant, &models.Product{Name: "Cached", Price: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := models.CachedStatements(); n != 0 {
+		t.Fatalf("expected an empty cache after InitDB, got %d statements", n)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := models.GetProductByID(ctx, utils.ReadDB, models.DefaultTenant, created.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := models.CachedStatements(); n != 1 {
+		t.Fatalf("repeated lookups should share one statement, got %d", n)
+	}
+	for _, order := range []string{"id_asc", "id_desc", "id_asc"} {
+		if _, err := models.GetAllProducts(ctx, utils.ReadDB, models.DefaultTenant, models.GetAllProductsParams{Limit: 10, Order: order}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := models.CachedStatements(); n != 3 {
+		t.Fatalf("expected one statement per list order, got %d", n)
+	}
+
+	// 事务中的查询不使用连接池上的缓存语句。
+	if err := utils.WithTx(ctx, func(tx *sql.Tx) error {
+		_, err := models.GetProductByID(ctx, tx, models.DefaultTenant, created.ID)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n := models.CachedStatements(); n != 3 {
+		t.Fatalf("transactions must not add cached statements, got %d", n)
+	}
+
+	// 迁移之后缓存失效，重新准备的语句照常工作。
+	utils.Migrate()
+	if n := models.CachedStatements(); n != 0 {
+		t.Fatalf("expected migration to invalidate the cache, got %d statements", n)
+	}
+	product, err := models.GetProductByID(ctx, utils.ReadDB, models.DefaultTenant, created.ID)
+	if err != nil || product.Name != "Cached" {
+		t.Fatalf("lookup after migration failed: %+v, %v", product, err)
+	}
+	if n := models.CachedStatements(); n != 1 {
+		t.Fatalf("expected the statement to be prepared again, got %d", n)
+	}
+}
+
+// TestStatementCacheConcurrentMigrate 迁移与查询并发执行：迁移作废缓存时，其他 goroutine 正在使用的语句不会被关闭。
+func TestStatementCacheConcurrentMigrate(t *testing.T) {
+	defer setupTestDB()()
+	ctx := context.Background()
+
+	created, err := models.CreateProduct(ctx, utils.DB, models.DefaultTenant, &models.Product{Name: "Concurrent", Price: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	errs := make(chan error, 16)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				var err error
+				if i%2 == 0 {
+					_, err = models.GetProductByID(ctx, utils.ReadDB, models.DefaultTenant, created.ID)
+				} else {
+					_, err = models.GetAllProducts(ctx, utils.ReadDB, models.DefaultTenant, models.GetAllProductsParams{Limit: 10, Order: "id_desc"})
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+
+	for i := 0; i < 1000; i++ {
+		utils.Migrate()
+	}
+	close(stop)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("query failed during migration: %v", err)
+	}
+}
+
+// benchmarkStatements 在 100 个产品上并发执行 query，分别使用与不使用预编译语句缓存：
+//
+//	go test ./tests -run '^$' -bench Statements -benchmem
+func benchmarkStatements(b *testing.B, query func(ctx context.Context, i int) error) {
+	defer setupTestDB()()
+	ctx := context.Background()
+
+	products := make([]*models.Product, 100)
+	for i := range products {
+		products[i] = &models.Product{Name: fmt.Sprintf("Bench %d", i), Price: 1}
+	}
+	created, err := models.ProductsBulk(ctx, utils.DB, models.DefaultTenant, products)
+	if err != nil {
+		b.Fatal(err)
+	}
+	first := created[0].ID
+
+	defer func(previous bool) { models.StatementCache = previous }(models.StatementCache)
+	for _, cached := range []bool{true, false} {
+		name := "prepared"
+		if !cached {
+			name = "unprepared"
+		}
+		b.Run(name, func(b *testing.B) {
+			models.StatementCache = cached
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if err := query(ctx, first+i%len(created)); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkStatementsGetProductByID(b *testing.B) {
+	benchmarkStatements(b, func(ctx context.Context, id int) error {
+		_, err := models.GetProductByID(ctx, utils.ReadDB, models.DefaultTenant, id)
+		return err
+	})
+}
+
+func BenchmarkStatementsGetAllProducts(b *testing.B) {
+	benchmarkStatements(b, func(ctx context.Context, id int) error {
+		_, err := models.GetAllProducts(ctx, utils.ReadDB, models.DefaultTenant, models.GetAllProductsParams{Limit: 20, Order: "id_desc"})
+		return err
+	})
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/auth"
+	"golang-starter/handlers"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+// setupTenants 在 setupTestDB 的基础上清空租户配额，并恢复默认配额。
+func setupTenants(t *testing.T) func() {
+	t.Helper()
+
+	teardown := setupTestDB()
+	if _, err := utils.DB.Exec("DELETE FROM tenants"); err != nil {
+		t.Fatalf("clear tenants failed: %v", err)
+	}
+	models.DefaultMaxProducts = 0
+	return func() {
+		models.DefaultMaxProducts = 0
+		handlers.TenantBaseDomain = ""
+		teardown()
+	}
+}
+
+func tenantRequest(method, target, tenant, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if tenant != "" {
+		req.Header.Set(handlers.TenantHeader, tenant)
+	}
+	return req
+}
+
+func createTenantProduct(t *testing.T, tenant, name string) int {
+	t.Helper()
+
+	w := serve(tenantRequest("POST", "/api/products", tenant, fmt.Sprintf(`{"name":%q,"price":1,"stock":1}`, name)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create product for %s failed: %d %s", tenant, w.Code, w.Body.String())
+	}
+	var result struct {
+		Data models.Product `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response json: %v", err)
+	}
+	return result.Data.ID
+}
+
+// TestTenantCannotAccessOtherTenantProducts 其他租户的产品对当前租户来说等同于不存在。
+func TestTenantCannotAccessOtherTenantProducts(t *testing.T) {
+	defer setupTenants(t)()
+
+	id := createTenantProduct(t, "acme", "Acme Widget")
+	target := fmt.Sprintf("/api/products/%d", id)
+
+	expectError(t, serve(tenantRequest("GET", target, "globex", "")), http.StatusNotFound, apierror.ProductNotFound)
+	expectError(t, serve(tenantRequest("PUT", target, "globex", `{"name":"Hijacked","price":1,"stock":1}`)), http.StatusNotFound, apierror.ProductNotFound)
+	expectError(t, serve(tenantRequest("DELETE", target, "globex", "")), http.StatusNotFound, apierror.ProductNotFound)
+	patch := tenantRequest("PATCH", target, "globex", `{"stock":0}`)
+	patch.Header.Set("Content-Type", "application/merge-patch+json")
+	expectError(t, serve(patch), http.StatusNotFound, apierror.ProductNotFound)
+	// 不带租户时属于默认租户，同样看不到。
+	expectError(t, serve(tenantRequest("GET", target, "", "")), http.StatusNotFound, apierror.ProductNotFound)
+
+	for _, target := range []string{"/api/products", "/api/products/search?name=Acme", "/api/products/export?format=ndjson"} {
+		w := serve(tenantRequest("GET", target, "globex", ""))
+		if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "Acme Widget") {
+			t.Fatalf("%s leaked another tenant's product: %d %s", target, w.Code, w.Body.String())
+		}
+	}
+
+	// 其他租户的写操作都没有生效。
+	w := serve(tenantRequest("GET", target, "acme", ""))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Acme Widget") || !strings.Contains(w.Body.String(), `"stock":1`) {
+		t.Fatalf("owner should still see the original product: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTenantBulkCreateAndIdempotencyAreScoped(t *testing.T) {
+	defer setupTenants(t)()
+	clearIdempotencyKeys(t)
+
+	// 两个租户使用同一个 Idempotency-Key 互不影响。
+	for _, tenant := range []string{"acme", "globex"} {
+		req := tenantRequest("POST", "/api/products/bulk", tenant, fmt.Sprintf(`[{"name":"%s bulk","price":1,"stock":1}]`, tenant))
+		req.Header.Set("Idempotency-Key", "shared-key")
+		if w := serve(req); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatalf("%s: expected a fresh create, got %d %s", tenant, w.Code, w.Body.String())
+		}
+	}
+
+	for tenant, other := range map[string]string{"acme": "globex", "globex": "acme"} {
+		count, err := models.CountProducts(context.Background(), utils.DB, tenant)
+		if err != nil || count != 1 {
+			t.Fatalf("%s: expected 1 product, got %d, %v", tenant, count, err)
+		}
+		if w := serve(tenantRequest("GET", "/api/products", tenant, "")); strings.Contains(w.Body.String(), other+" bulk") {
+			t.Fatalf("%s can see %s's products: %s", tenant, other, w.Body.String())
+		}
+	}
+}
+
+func TestTenantJobsAreScoped(t *testing.T) {
+	defer setupTenants(t)()
+	defer setupJobs(t)()
+
+	createTenantProduct(t, "acme", "Acme Export")
+	createTenantProduct(t, "globex", "Globex Export")
+
+	id := acceptedJobID(t, serve(tenantRequest("GET", "/api/products/export?format=ndjson&async=true", "acme", "")))
+
+	expectError(t, serve(tenantRequest("GET", fmt.Sprintf("/api/jobs/%d", id), "globex", "")), http.StatusNotFound, apierror.JobNotFound)
+	expectError(t, serve(tenantRequest("GET", fmt.Sprintf("/api/jobs/%d/result", id), "globex", "")), http.StatusNotFound, apierror.JobNotFound)
+	expectError(t, serve(tenantRequest("POST", fmt.Sprintf("/api/jobs/%d/cancel", id), "globex", "")), http.StatusNotFound, apierror.JobNotFound)
+
+	// 任务只导出创建它的租户的产品。
+	job, err := models.GetJobByID(context.Background(), utils.DB, "acme", id)
+	for deadline := time.Now().Add(5 * time.Second); err == nil && !job.Finished() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		job, err = models.GetJobByID(context.Background(), utils.DB, "acme", id)
+	}
+	if err != nil || job.Status != models.JobSucceeded {
+		t.Fatalf("expected job to succeed, got %+v, %v", job, err)
+	}
+	w := serve(tenantRequest("GET", fmt.Sprintf("/api/jobs/%d/result", id), "acme", ""))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Acme Export") || strings.Contains(w.Body.String(), "Globex Export") {
+		t.Fatalf("unexpected export result: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	defer setupTenants(t)()
+
+	w := serve(tenantRequest("PUT", "/api/admin/tenants/acme", "", `{"max_products":2}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("set quota failed: %d %s", w.Code, w.Body.String())
+	}
+
+	createTenantProduct(t, "acme", "A1")
+	// 批量创建会超出配额时整批拒绝。
+	expectError(t, serve(tenantRequest("POST", "/api/products/bulk", "acme", `[{"name":"B1","price":1,"stock":1},{"name":"B2","price":1,"stock":1}]`)),
+		http.StatusForbidden, apierror.QuotaExceeded)
+	createTenantProduct(t, "acme", "A2")
+	expectError(t, serve(tenantRequest("POST", "/api/products", "acme", `{"name":"A3","price":1,"stock":1}`)), http.StatusForbidden, apierror.QuotaExceeded)
+
+	// 配额只影响该租户。
+	createTenantProduct(t, "globex", "G1")
+	createTenantProduct(t, "globex", "G2")
+	createTenantProduct(t, "globex", "G3")
+
+	var result struct {
+		Data models.Tenant `json:"data"`
+	}
+	w = serve(tenantRequest("GET", "/api/admin/tenants/acme", "", ""))
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response json: %v", err)
+	}
+	if result.Data.Products != 2 || result.Data.MaxProducts != 2 || !result.Data.CustomQuota {
+		t.Fatalf("unexpected tenant usage: %+v", result.Data)
+	}
+
+	// 默认配额对没有单独配置的租户生效；删除单独配置后恢复为默认配额。
+	models.DefaultMaxProducts = 3
+	expectError(t, serve(tenantRequest("POST", "/api/products", "globex", `{"name":"G4","price":1,"stock":1}`)), http.StatusForbidden, apierror.QuotaExceeded)
+	if w := serve(tenantRequest("DELETE", "/api/admin/tenants/acme", "", "")); w.Code != http.StatusOK {
+		t.Fatalf("reset quota failed: %d %s", w.Code, w.Body.String())
+	}
+	createTenantProduct(t, "acme", "A3")
+
+	expectError(t, serve(tenantRequest("PUT", "/api/admin/tenants/acme", "", `{"max_products":-1}`)), http.StatusUnprocessableEntity, apierror.ValidationFailed)
+}
+
+func TestTenantResolution(t *testing.T) {
+	defer setupTenants(t)()
+
+	handlers.TenantBaseDomain = "shop.example.test"
+	req := tenantRequest("POST", "/api/products", "", `{"name":"Subdomain Product","price":1,"stock":1}`)
+	req.Host = "acme.shop.example.test:8080"
+	if w := serve(req); w.Code != http.StatusCreated {
+		t.Fatalf("create via subdomain failed: %d %s", w.Code, w.Body.String())
+	}
+	if count, _ := models.CountProducts(context.Background(), utils.DB, "acme"); count != 1 {
+		t.Fatalf("expected product to belong to tenant acme, got %d", count)
+	}
+
+	expectError(t, serve(tenantRequest("GET", "/api/products", "Not_Valid", "")), http.StatusBadRequest, apierror.InvalidTenant)
+}
+
+// TestTenantBoundCredentials 绑定租户的凭证不能通过请求头切换到其他租户，也不能管理密钥与租户。
+func TestTenantBoundCredentials(t *testing.T) {
+	defer setupTenants(t)()
+	defer setupAuth(t)()
+
+	_, acmeKey, err := auth.CreateKey(context.Background(), utils.DB, "acme editor", auth.Editor, "acme", false)
+	if err != nil {
+		t.Fatalf("create key failed: %v", err)
+	}
+	_, acmeAdmin, err := auth.CreateKey(context.Background(), utils.DB, "acme admin", auth.Admin, "acme", false)
+	if err != nil {
+		t.Fatalf("create key failed: %v", err)
+	}
+
+	if w := serve(requestWithKey("POST", "/api/products", acmeKey, `{"name":"Bound","price":1,"stock":1}`)); w.Code != http.StatusCreated {
+		t.Fatalf("bound key create failed: %d %s", w.Code, w.Body.String())
+	}
+	if count, _ := models.CountProducts(context.Background(), utils.DB, "acme"); count != 1 {
+		t.Fatalf("expected product to belong to tenant acme, got %d", count)
+	}
+
+	req := requestWithKey("GET", "/api/products", acmeKey, "")
+	req.Header.Set(handlers.TenantHeader, "globex")
+	expectError(t, serve(req), http.StatusForbidden, apierror.TenantMismatch)
+
+	expectError(t, serve(requestWithKey("GET", "/api/admin/keys", acmeAdmin, "")), http.StatusForbidden, apierror.Forbidden)
+	expectError(t, serve(requestWithKey("PUT", "/api/admin/tenants/acme", acmeAdmin, `{"max_products":0}`)), http.StatusForbidden, apierror.Forbidden)
+
+	// 平台级 admin 可以创建绑定租户的密钥。
+	admin := createTestKey(t, auth.Admin)
+	created := decodeAPIKey(t, serve(requestWithKey("POST", "/api/admin/keys", admin, `{"name":"globex","role":"viewer","tenant":"globex"}`)), http.StatusCreated)
+	req = requestWithKey("GET", "/api/products", created.Data.Key, "")
+	req.Header.Set(handlers.TenantHeader, "acme")
+	expectError(t, serve(req), http.StatusForbidden, apierror.TenantMismatch)
+}
+
+// TestUnboundCredentials 不绑定租户的凭证不是平台级的：只能访问默认租户，也不能管理密钥与租户；平台级密钥必须显式创建。
+func TestUnboundCredentials(t *testing.T) {
+	defer setupTenants(t)()
+	defer setupAuth(t)()
+
+	editor := createTestKey(t, auth.Editor)
+	req := requestWithKey("POST", "/api/products", editor, `{"name":"Spoofed","price":1,"stock":1}`)
+	req.Header.Set(handlers.TenantHeader, "globex")
+	expectError(t, serve(req), http.StatusForbidden, apierror.TenantMismatch)
+	if count, _ := models.CountProducts(context.Background(), utils.DB, "globex"); count != 0 {
+		t.Fatalf("unbound key must not write to tenant globex, got %d", count)
+	}
+	req = requestWithKey("GET", "/api/products", editor, "")
+	req.Header.Set(handlers.TenantHeader, models.DefaultTenant)
+	if w := serve(req); w.Code != http.StatusOK {
+		t.Fatalf("unbound key should access the default tenant, got %d", w.Code)
+	}
+
+	admin := createTestKey(t, auth.Admin)
+	unbound := decodeAPIKey(t, serve(requestWithKey("POST", "/api/admin/keys", admin, `{"name":"unbound admin","role":"admin"}`)), http.StatusCreated)
+	if unbound.Data.Platform {
+		t.Fatal("keys must not be platform keys unless requested")
+	}
+	expectError(t, serve(requestWithKey("GET", "/api/admin/keys", unbound.Data.Key, "")), http.StatusForbidden, apierror.Forbidden)
+	expectError(t, serve(requestWithKey("GET", "/api/admin/tenants/globex", unbound.Data.Key, "")), http.StatusForbidden, apierror.Forbidden)
+	req = requestWithKey("GET", "/api/products", unbound.Data.Key, "")
+	req.Header.Set(handlers.TenantHeader, "globex")
+	expectError(t, serve(req), http.StatusForbidden, apierror.TenantMismatch)
+
+	platform := decodeAPIKey(t, serve(requestWithKey("POST", "/api/admin/keys", admin, `{"name":"platform","role":"admin","platform":true}`)), http.StatusCreated)
+	if !platform.Data.Platform {
+		t.Fatal("expected a platform key")
+	}
+	if w := serve(requestWithKey("GET", "/api/admin/tenants/globex", platform.Data.Key, "")); w.Code != http.StatusOK {
+		t.Fatalf("platform key should manage tenants, got %d", w.Code)
+	}
+
+	// 平台级密钥只能是不绑定租户的 admin。
+	expectError(t, serve(requestWithKey("POST", "/api/admin/keys", admin, `{"name":"x","role":"editor","platform":true}`)), http.StatusUnprocessableEntity, apierror.ValidationFailed)
+	expectError(t, serve(requestWithKey("POST", "/api/admin/keys", admin, `{"name":"x","role":"admin","tenant":"acme","platform":true}`)), http.StatusUnprocessableEntity, apierror.ValidationFailed)
+}
+
+func TestJWTTenantClaim(t *testing.T) {
+	signer := newTestSigner(t)
+	defer setupTenants(t)()
+	defer setupJWT(t, signer)()
+
+	handlers.JWTVerifier.TenantClaim = "tenant"
+	claims := validClaims("editor")
+	claims["tenant"] = "acme"
+	token := signer.sign(t, "RS256", "rsa", claims)
+
+	if w := serve(bearer("POST", "/api/products", token, `{"name":"JWT Tenant","price":1,"stock":1}`)); w.Code != http.StatusCreated {
+		t.Fatalf("create failed: %d %s", w.Code, w.Body.String())
+	}
+	if count, _ := models.CountProducts(context.Background(), utils.DB, "acme"); count != 1 {
+		t.Fatalf("expected product to belong to tenant acme, got %d", count)
+	}
+
+	req := bearer("GET", "/api/products", token, "")
+	req.Header.Set(handlers.TenantHeader, "globex")
+	expectError(t, serve(req), http.StatusForbidden, apierror.TenantMismatch)
+}
+
+// TestJWTMissingTenantClaim 设置了 JWT_TENANT_CLAIM 时，缺少租户 claim 的 token 不能通过请求头选择租户，而是认证失败。
+func TestJWTMissingTenantClaim(t *testing.T) {
+	signer := newTestSigner(t)
+	defer setupTenants(t)()
+	defer setupJWT(t, signer)()
+
+	handlers.JWTVerifier.TenantClaim = "tenant"
+	handlers.JWTVerifier.PlatformClaim = "platform"
+
+	for _, tc := range []struct {
+		name  string
+		role  string
+		extra map[string]any
+	}{
+		{"missing", "admin", nil},
+		{"empty", "admin", map[string]any{"tenant": ""}},
+		{"editor", "editor", nil},
+		// 只有 admin 的平台 claim 才有效。
+		{"non-admin platform", "editor", map[string]any{"platform": true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := validClaims(tc.role)
+			for k, v := range tc.extra {
+				claims[k] = v
+			}
+			token := signer.sign(t, "RS256", "rsa", claims)
+
+			req := bearer("POST", "/api/products", token, `{"name":"Spoofed","price":1,"stock":1}`)
+			req.Header.Set(handlers.TenantHeader, "globex")
+			expectError(t, serve(req), http.StatusUnauthorized, apierror.Unauthorized)
+			expectError(t, serve(bearer("GET", "/api/admin/tenants/globex", token, "")), http.StatusUnauthorized, apierror.Unauthorized)
+		})
+	}
+	if count, _ := models.CountProducts(context.Background(), utils.DB, "globex"); count != 0 {
+		t.Fatalf("token without tenant claim must not write to tenant globex, got %d", count)
+	}
+
+	// 带平台 claim 的 admin token 可以选择租户并访问平台级接口。
+	claims := validClaims("admin")
+	claims["platform"] = true
+	token := signer.sign(t, "RS256", "rsa", claims)
+	req := bearer("POST", "/api/products", token, `{"name":"Platform","price":1,"stock":1}`)
+	req.Header.Set(handlers.TenantHeader, "globex")
+	if w := serve(req); w.Code != http.StatusCreated {
+		t.Fatalf("platform token create failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(bearer("GET", "/api/admin/tenants/globex", token, "")); w.Code != http.StatusOK {
+		t.Fatalf("platform token should manage tenants, got %d", w.Code)
+	}
+
+	// 没有配置租户 claim 时，不带平台 claim 的 admin token 只能访问默认租户，也不是平台级调用方。
+	handlers.JWTVerifier.TenantClaim = ""
+	token = signer.sign(t, "RS256", "rsa", validClaims("admin"))
+	req = bearer("GET", "/api/products", token, "")
+	req.Header.Set(handlers.TenantHeader, "globex")
+	expectError(t, serve(req), http.StatusForbidden, apierror.TenantMismatch)
+	expectError(t, serve(bearer("GET", "/api/admin/keys", token, "")), http.StatusForbidden, apierror.Forbidden)
+}
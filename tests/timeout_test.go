@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang-starter/apierror"
+	"golang-starter/handlers"
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+func TestModelsHonorContext(t *testing.T) {
+	defer setupTestDB()()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := models.GetAllProducts(ctx, utils.ReadDB, models.DefaultTenant, models.GetAllProductsParams{Limit: 10}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from a read, got %v", err)
+	}
+	if _, err := models.CreateProduct(ctx, utils.DB, models.DefaultTenant, &models.Product{Name: "Never", Price: 1}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from a write, got %v", err)
+	}
+	if count, _ := models.CountProducts(context.Background(), utils.ReadDB, models.DefaultTenant); count != 0 {
+		t.Fatalf("a canceled write must not insert anything, got %d products", count)
+	}
+}
+
+// TestRequestDeadlineReturns503 超过路由的处理时限时，数据库查询被中断，返回 503。
+func TestRequestDeadlineReturns503(t *testing.T) {
+	defer setupTestDB()()
+	defer func(previous time.Duration) { handlers.RequestTimeouts["default"] = previous }(handlers.RequestTimeouts["default"])
+	handlers.RequestTimeouts["default"] = time.Nanosecond
+
+	expectError(t, serve(httptest.NewRequest("GET", "/api/products", nil)), http.StatusServiceUnavailable, apierror.RequestTimeout)
+
+	// 其他 class 的时限不受影响。
+	w := serve(tenantRequest("POST", "/api/products/bulk", "", `[{"name":"Bulk","price":1}]`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for bulk, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestCanceledRequestReturns499 客户端断开后请求以 499 结束；幂等记录被释放，重试可以正常执行。
+func TestCanceledRequestReturns499(t *testing.T) {
+	defer setupTestDB()()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := tenantRequest("POST", "/api/products", "", `{"name":"Canceled","price":1}`)
+	req.Header.Set("Idempotency-Key", "canceled-request")
+	expectError(t, serve(req.WithContext(ctx)), handlers.StatusClientClosedRequest, apierror.RequestCanceled)
+
+	retry := tenantRequest("POST", "/api/products", "", `{"name":"Canceled","price":1}`)
+	retry.Header.Set("Idempotency-Key", "canceled-request")
+	if w := serve(retry); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry after a canceled request should create the product, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestExportErrorAbortsStream 同步导出在开始输出后出错：连接被中断，客户端读取响应时报错，
+// 而不是收到一个看起来完整、实际被截断的文件。开始输出之前出错时按普通错误响应，见 TestExportFailureBeforeOutput。
+func TestExportErrorAbortsStream(t *testing.T) {
+	defer setupTestDB()()
+	createProductWithName(t, "Exported")
+
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	export := func() error {
+		resp, err := http.Get(server.URL + "/api/products/export?format=csv")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		_, err = io.ReadAll(resp.Body)
+		return err
+	}
+
+	if err := export(); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	// 第二行的 stock 不是整数：第一行已经输出后读取第二行失败。
+	if _, err := utils.DB.Exec(`INSERT INTO products (tenant_id, name, price, stock, created_at, updated_at) VALUES (?, 'Broken', 1, 'many', ?, ?)`, models.DefaultTenant, time.Now(), time.Now()); err != nil {
+		t.Fatalf("insert broken row failed: %v", err)
+	}
+	if err := export(); err == nil {
+		t.Fatal("expected a truncated export to fail on the client")
+	}
+}
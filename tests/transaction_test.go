@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang-starter/models"
+	"golang-starter/utils"
+)
+
+func TestWithTxCommitsAndRollsBack(t *testing.T) {
+	defer setupTestDB()()
+	ctx := context.Background()
+
+	errBoom := errors.New("boom")
+	err := utils.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := models.CreateProduct(ctx, tx, models.DefaultTenant, &models.Product{Name: "Rolled back", Price: 1}); err != nil {
+			return err
+		}
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("expected the error from fn, got %v", err)
+	}
+
+	var created *models.Product
+	if err := utils.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		created, err = models.CreateProduct(ctx, tx, models.DefaultTenant, &models.Product{Name: "Committed", Price: 1})
+		if err != nil {
+			return err
+		}
+		// 同一个事务中的后续操作能看到之前的写入。
+		created, err = models.UpdateLocalProduct(ctx, tx, models.DefaultTenant, created.ID, []string{"stock"}, models.Product{Stock: 7})
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	products, err := models.GetAllProducts(ctx, utils.ReadDB, models.DefaultTenant, models.GetAllProductsParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].Name != "Committed" || products[0].Stock != 7 || created.Stock != 7 {
+		t.Fatalf("expected only the committed product with stock 7, got %+v", products)
+	}
+}
+
+// TestRunTxRetriesBusy 另一个连接持有写锁、且 busy_timeout 为 0 时 BEGIN 立即返回 SQLITE_BUSY：
+// RunTx 应该在锁释放后重试成功；锁一直不释放时在 TxMaxAttempts 次后返回 busy 错误。
+func TestRunTxRetriesBusy(t *testing.T) {
+	defer setupTestDB()()
+	ctx := context.Background()
+
+	impatient, err := sql.Open("sqlite3", "file:"+utils.DBPath+"?_busy_timeout=0&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer impatient.Close()
+	holder, err := sql.Open("sqlite3", "file:"+utils.DBPath+"?_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Close()
+
+	holdLock := func(d time.Duration) chan struct{} {
+		tx, err := holder.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan struct{})
+		go func() {
+			time.Sleep(d)
+			tx.Rollback()
+			close(done)
+		}()
+		return done
+	}
+
+	attempts := 0
+	released := holdLock(50 * time.Millisecond)
+	err = utils.RunTx(ctx, impatient, func(tx *sql.Tx) error {
+		attempts++
+		_, err := models.CreateProduct(ctx, tx, models.DefaultTenant, &models.Product{Name: "Retried", Price: 1})
+		return err
+	})
+	<-released
+	if err != nil {
+		t.Fatalf("expected the transaction to succeed after retrying, got %v", err)
+	}
+	if attempts != 1 {
+		// BEGIN IMMEDIATE 失败时 fn 还没有被调用；成功的那一次只调用一次 fn。
+		t.Fatalf("expected fn to run once, ran %d times", attempts)
+	}
+
+	defer func(previous int) { utils.TxMaxAttempts = previous }(utils.TxMaxAttempts)
+	utils.TxMaxAttempts = 2
+	released = holdLock(time.Second)
+	err = utils.RunTx(ctx, impatient, func(tx *sql.Tx) error { return nil })
+	<-released
+	if !utils.IsBusy(err) {
+		t.Fatalf("expected a busy error after giving up, got %v", err)
+	}
+}
+
+// TestBulkCreateIsAtomic 超过单条 INSERT 行数的批量创建分多条语句执行，但仍是全部成功或全部失败。
+func TestBulkCreateIsAtomic(t *testing.T) {
+	defer setupTestDB()()
+	ctx := context.Background()
+
+	newProducts := func(n int) []*models.Product {
+		products := make([]*models.Product, n)
+		for i := range products {
+			products[i] = &models.Product{Name: fmt.Sprintf("Bulk %d", i), Price: 1}
+		}
+		return products
+	}
+
+	created, err := models.ProductsBulk(ctx, utils.DB, models.DefaultTenant, newProducts(1200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1200 || created[1199].ID != created[0].ID+1199 {
+		t.Fatalf("expected 1200 products with consecutive ids, got %d", len(created))
+	}
+
+	// 配额只够前两批：第三批超出配额，前两批也要回滚。
+	quota := 2200
+	if _, err := models.SetTenantQuota(ctx, utils.DB, models.DefaultTenant, &quota); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.ProductsBulk(ctx, utils.DB, models.DefaultTenant, newProducts(1200)); !errors.Is(err, models.ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if count, _ := models.CountProducts(ctx, utils.ReadDB, models.DefaultTenant); count != 1200 {
+		t.Fatalf("a rejected bulk create must not write anything, got %d products", count)
+	}
+}
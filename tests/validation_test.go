@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang-starter/validation"
+)
+
+type validationErrorBody struct {
+	Code    int                     `json:"code"`
+	Message string                  `json:"message"`
+	Errors  []validation.FieldError `json:"errors"`
+}
+
+func decodeValidationError(t *testing.T, w *httptest.ResponseRecorder) validationErrorBody {
+	t.Helper()
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d. Body: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	var body validationErrorBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode error json: %v", err)
+	}
+	return body
+}
+
+func TestCreateProductReturnsAllFieldErrors(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	req := httptest.NewRequest("POST", "/api/products", strings.NewReader(`{"name":"","price":0,"stock":-1}`))
+	req.Header.Set("Content-Type", "application/json")
+	body := decodeValidationError(t, serve(req))
+
+	got := map[string]string{}
+	for _, fe := range body.Errors {
+		got[fe.Field] = fe.Code
+	}
+	want := map[string]string{"name": "required", "price": "gt", "stock": "min"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected field errors %v, got %v", want, got)
+	}
+}
+
+func TestUpdateProductValidationError(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	id := createProduct(t)
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/products/%d", id), strings.NewReader(`{"name":"ok","price":-5,"stock":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	body := decodeValidationError(t, serve(req))
+
+	if len(body.Errors) != 1 || body.Errors[0].Field != "price" || body.Errors[0].Message != "price must be greater than 0" {
+		t.Fatalf("unexpected field errors: %+v", body.Errors)
+	}
+}
+
+func TestBulkCreateReportsIndexedFieldErrors(t *testing.T) {
+	teardown := setupTestDB()
+	defer teardown()
+
+	payload := `[{"name":"A","price":1,"stock":1},{"name":"","price":1,"stock":1},{"name":"C","price":1,"stock":-2}]`
+	req := httptest.NewRequest("POST", "/api/products/bulk", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	body := decodeValidationError(t, serve(req))
+
+	if len(body.Errors) != 2 {
+		t.Fatalf("expected 2 field errors, got %+v", body.Errors)
+	}
+	if body.Errors[0].Field != "products[1].name" || body.Errors[0].Message != "products[1].name is required" {
+		t.Fatalf("unexpected first error: %+v", body.Errors[0])
+	}
+	if body.Errors[1].Field != "products[2].stock" || body.Errors[1].Code != "min" {
+		t.Fatalf("unexpected second error: %+v", body.Errors[1])
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	validation.RegisterRule("even", func(v reflect.Value, _ string) (string, bool) {
+		return "must be even", v.Int()%2 == 0
+	})
+
+	type sample struct {
+		Code  string   `json:"code" validate:"required,len=3,regex=^[A-Z]+$"`
+		Title string   `json:"title" validate:"min=2,max=5"`
+		Count int      `json:"count" validate:"even,lt=10"`
+		Tags  []string `json:"tags" validate:"required"`
+		Skip  string
+	}
+
+	errs := validation.Struct(sample{Code: "ab", Title: "x", Count: 11})
+	got := []string{}
+	for _, fe := range errs {
+		got = append(got, fe.Field+":"+fe.Code)
+	}
+	want := []string{"code:len", "code:regex", "title:min", "count:even", "count:lt", "tags:required"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if errs := validation.Struct(sample{Code: "ABC", Title: "abc", Count: 4, Tags: []string{"x"}}); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+}
@@ -4,47 +4,122 @@ package utils
 import (
 	// database/sql：Go 标准库的通用 SQL 接口层（连接池、Query/Exec、Rows/Row 等）。
 	"database/sql"
+	// errors：合并关闭连接池时的错误。
+	"errors"
+	// fmt：拼接 PRAGMA 语句。
+	"fmt"
 	// log：输出数据库初始化/关闭过程中的日志信息。
 	"log"
+	// sync/atomic：并发安全的 schema 代数计数。
+	"sync/atomic"
 
 	// sqlite3 driver：用下划线导入触发 init() 注册驱动到 database/sql。
 	// 如果缺少这一行，sql.Open("sqlite3", ...) 会报 unknown driver。
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// DB 全局数据库连接
+// DB 全局数据库连接（写连接池）
 // 注意：*sql.DB 表示“连接池句柄”，不是单个连接；它是并发安全的。
+// SQLite 同一时间只允许一个写事务，因此写连接池只有一个连接：并发的写请求在 Go 这一侧排队，
+// 而不是在 SQLite 里竞争锁后返回 "database is locked"。
 var DB *sql.DB
 
+// ReadDB 只读连接池（连接设置了 query_only，最多 DBConfig.MaxReadConns 个连接）。
+// 只读的查询应使用 ReadDB：WAL 模式下读不会被写阻塞，长时间的读（例如导出）也不会占住唯一的写连接。
+var ReadDB *sql.DB
+
+// BackupDB 是 VACUUM INTO（备份）专用的连接池，最多一个连接，不保留空闲连接。
+// VACUUM INTO 不能在 query_only 的只读连接上执行；使用独立的连接，备份期间 DB 这个唯一的写连接不会被占用，
+// 写请求照常执行（WAL 模式下备份读到的是开始时的一致快照）。
+var BackupDB *sql.DB
+
+// DBPath 是 SQLite 数据库文件路径；命令行工具可以在调用 InitDB 之前修改。
+var DBPath = "./database.db"
+
+// SchemaVersion 是当前程序的数据库 schema 版本，建表后写入 PRAGMA user_version。
+// 修改表结构时加 1；恢复备份时拒绝版本更高（由更新的程序创建）的数据库。
+const SchemaVersion = 2
+
 // InitDB 初始化数据库连接
 func InitDB() {
 	// err：用于接收后续 Open/Ping/Exec 的错误。
 	var err error
+	if err = DBConfig.Validate(); err != nil {
+		log.Fatal("Invalid database configuration: ", err)
+	}
 	// sql.Open：创建 *sql.DB 句柄；对不少驱动而言，此时未必真正建立连接，因此需要 Ping 验证。
-	DB, err = sql.Open("sqlite3", "./database.db")
+	DB, err = sql.Open("sqlite3", DBConfig.dsn(DBPath, false))
 	if err != nil {
 		// log.Fatal：打印日志并退出进程；用于“无法启动就直接失败”的场景。
 		log.Fatal("Failed to connect to database:", err)
 	}
+	// 单个写连接，并且不让它因为空闲被关闭（每次重新打开都要重新执行连接参数）。
+	DB.SetMaxOpenConns(1)
+	DB.SetMaxIdleConns(1)
+	DB.SetConnMaxLifetime(0)
 
 	// 检查数据库连接
 	// Ping：确认能够与数据库正常通信（SQLite 是文件型 DB，这里也可用于验证打开是否成功）。
+	// 写连接先建立：journal_mode 等持久化的设置由它写入数据库文件，之后的只读连接直接沿用。
 	if err = DB.Ping(); err != nil {
 		log.Fatal("Failed to ping database:", err)
 	}
 
-	// 提示：数据库连接建立成功。
-	log.Println("Database connection established successfully")
+	ReadDB, err = sql.Open("sqlite3", DBConfig.dsn(DBPath, true))
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	ReadDB.SetMaxOpenConns(DBConfig.MaxReadConns)
+	ReadDB.SetMaxIdleConns(DBConfig.MaxReadConns)
+	if err = ReadDB.Ping(); err != nil {
+		log.Fatal("Failed to ping database:", err)
+	}
+
+	// 备份连接在需要时才建立，用完即关闭。
+	BackupDB, err = sql.Open("sqlite3", DBConfig.dsn(DBPath, false))
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	BackupDB.SetMaxOpenConns(1)
+	BackupDB.SetMaxIdleConns(0)
+
+	// 提示：数据库连接建立成功；journal_mode 以数据库实际生效的值为准（例如内存数据库不支持 WAL）。
+	var journalMode string
+	if err = DB.QueryRow(`PRAGMA journal_mode`).Scan(&journalMode); err != nil {
+		log.Fatal("Failed to read journal mode:", err)
+	}
+	log.Printf("Database connection established successfully (journal_mode=%s, synchronous=%s, busy_timeout=%s, read connections=%d)",
+		journalMode, DBConfig.Synchronous, DBConfig.BusyTimeout, DBConfig.MaxReadConns)
 
 	// 创建表
 	// 启动时建表：使用 IF NOT EXISTS，重复启动不会报错（适合学习项目）。
+	Migrate()
+}
+
+// schemaGeneration 在每次执行迁移后加 1；缓存了预编译语句的一方据此判断语句是否需要重新准备。
+var schemaGeneration atomic.Uint64
+
+// Migrate 建表并补齐旧版本缺少的列（幂等），InitDB 会自动调用。
+// 完成后 schema 代数加 1：表结构可能已经变化，之前缓存的预编译语句都会失效。
+func Migrate() {
 	createTables()
+	schemaGeneration.Add(1)
+}
+
+// SchemaGeneration 返回迁移执行的次数，每次 Migrate 之后都不同。
+func SchemaGeneration() uint64 {
+	return schemaGeneration.Load()
 }
 
 // CloseDB 关闭数据库连接
 func CloseDB() {
 	// Close：关闭连接池并释放资源；关闭后 DB 不应再被使用。
-	if err := DB.Close(); err != nil {
+	// 先关闭只读与备份连接池：最后一个连接关闭时 SQLite 会把 WAL 合并回数据库文件并删除 -wal / -shm。
+	err := errors.Join(ReadDB.Close(), BackupDB.Close())
+	if closeErr := DB.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
 		// 关闭失败一般不致命，但应记录下来，便于排查资源泄露等问题。
 		log.Println("Error closing database connection:", err)
 	} else {
@@ -58,6 +133,7 @@ func createTables() {
 	productTableSQL := `
 	CREATE TABLE IF NOT EXISTS products (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL DEFAULT 'default',
 		name TEXT NOT NULL,
 		price REAL NOT NULL,
 		stock INTEGER NOT NULL DEFAULT 0,
@@ -73,6 +149,159 @@ func createTables() {
 		log.Fatal("Failed to create products table:", err)
 	}
 
+	// 旧版本的 products 表没有 tenant_id：补上该列，已有数据归属默认租户。
+	addColumnIfMissing("products", "tenant_id", `TEXT NOT NULL DEFAULT 'default'`)
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_products_tenant ON products (tenant_id, id)`); err != nil {
+		log.Fatal("Failed to create products tenant index:", err)
+	}
+
 	// 提示：products 表已初始化完成。
 	log.Println("Products table initialized")
+
+	// tenantsTableSQL：租户级配置；没有记录的租户使用默认配置。
+	// max_products 为 NULL 时使用默认配额，<= 0 表示不限制。
+	tenantsTableSQL := `
+	CREATE TABLE IF NOT EXISTS tenants (
+		id TEXT PRIMARY KEY,
+		max_products INTEGER,
+		updated_at DATETIME NOT NULL
+	);
+	`
+
+	if _, err := DB.Exec(tenantsTableSQL); err != nil {
+		log.Fatal("Failed to create tenants table:", err)
+	}
+
+	log.Println("Tenants table initialized")
+
+	// jobsTableSQL：异步任务表；任务状态持久化在数据库中，进程重启后仍可继续执行或查询。
+	jobsTableSQL := `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL DEFAULT 'default',
+		type TEXT NOT NULL,
+		status TEXT NOT NULL,
+		payload TEXT NOT NULL DEFAULT '',
+		progress INTEGER NOT NULL DEFAULT 0,
+		total INTEGER NOT NULL DEFAULT 0,
+		result TEXT NOT NULL DEFAULT '',
+		result_file TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		cancel_requested INTEGER NOT NULL DEFAULT 0,
+		checkpoint TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		started_at DATETIME,
+		finished_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status, id);
+	`
+
+	if _, err := DB.Exec(jobsTableSQL); err != nil {
+		log.Fatal("Failed to create jobs table:", err)
+	}
+	addColumnIfMissing("jobs", "tenant_id", `TEXT NOT NULL DEFAULT 'default'`)
+	addColumnIfMissing("jobs", "checkpoint", `TEXT NOT NULL DEFAULT ''`)
+
+	log.Println("Jobs table initialized")
+
+	// idempotencyTableSQL：保存带 Idempotency-Key 的 POST 请求的首次响应，用于重试时原样重放。
+	// 同一个 key 在不同的租户、method、path 下互不影响；status 为 0 表示请求仍在处理中。
+	// 旧版本的表主键不含 tenant_id；表中只有短期缓存，直接重建。
+	if !columnExists("idempotency_keys", "tenant_id") {
+		if _, err := DB.Exec(`DROP TABLE IF EXISTS idempotency_keys`); err != nil {
+			log.Fatal("Failed to migrate idempotency_keys table:", err)
+		}
+	}
+	idempotencyTableSQL := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		tenant_id TEXT NOT NULL,
+		key TEXT NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		request_hash TEXT NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		content_type TEXT NOT NULL DEFAULT '',
+		location TEXT NOT NULL DEFAULT '',
+		body BLOB,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		PRIMARY KEY (tenant_id, key, method, path)
+	);
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+	`
+
+	if _, err := DB.Exec(idempotencyTableSQL); err != nil {
+		log.Fatal("Failed to create idempotency_keys table:", err)
+	}
+
+	log.Println("Idempotency keys table initialized")
+
+	// apiKeysTableSQL：API 密钥只保存 SHA-256 哈希与用于辨认的前缀，明文只在创建/轮换时返回一次。
+	// revoked_at 非空表示已吊销；吊销的记录保留用于审计。
+	apiKeysTableSQL := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		role TEXT NOT NULL,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		platform INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		rotated_at DATETIME,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	);
+	`
+
+	if _, err := DB.Exec(apiKeysTableSQL); err != nil {
+		log.Fatal("Failed to create api_keys table:", err)
+	}
+	// tenant_id 为空表示密钥不绑定租户；不绑定租户也不是平台级密钥时只能访问默认租户。
+	addColumnIfMissing("api_keys", "tenant_id", `TEXT NOT NULL DEFAULT ''`)
+	// 旧版本把不绑定租户的 admin 密钥视为平台级密钥：补上 platform 列时保留它们原有的权限。
+	if !columnExists("api_keys", "platform") {
+		addColumnIfMissing("api_keys", "platform", `INTEGER NOT NULL DEFAULT 0`)
+		if _, err := DB.Exec(`UPDATE api_keys SET platform = 1 WHERE role = 'admin' AND tenant_id = ''`); err != nil {
+			log.Fatal("Failed to migrate api_keys.platform:", err)
+		}
+	}
+
+	log.Println("API keys table initialized")
+
+	// PRAGMA 不支持参数绑定，版本号是常量，直接拼接。
+	if _, err := DB.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, SchemaVersion)); err != nil {
+		log.Fatal("Failed to set schema version:", err)
+	}
+}
+
+// columnExists 判断表中是否已有某一列。
+func columnExists(table, column string) bool {
+	rows, err := DB.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		log.Fatal("Failed to inspect table ", table, ": ", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			log.Fatal("Failed to inspect table ", table, ": ", err)
+		}
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// addColumnIfMissing 为旧版本创建的表补充新增的列。
+func addColumnIfMissing(table, column, definition string) {
+	if columnExists(table, column) {
+		return
+	}
+	if _, err := DB.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition); err != nil {
+		log.Fatal("Failed to add column ", table, ".", column, ": ", err)
+	}
 }
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SQLiteConfig 是打开数据库时使用的 SQLite 参数，每个新连接都会应用。
+type SQLiteConfig struct {
+	// JournalMode：日志模式；WAL 下读不阻塞写、写不阻塞读。
+	JournalMode string
+	// Synchronous：落盘级别；WAL 模式下 NORMAL 已经不会损坏数据库，只是掉电时可能丢失最后几个事务。
+	Synchronous string
+	// BusyTimeout：数据库被其他连接（例如另一个进程）锁住时的最长等待时间，超时返回 "database is locked"。
+	BusyTimeout time.Duration
+	// ForeignKeys：是否检查外键约束（SQLite 默认关闭）。
+	ForeignKeys bool
+	// MaxReadConns：只读连接池的最大连接数；写连接池固定只有一个连接。
+	MaxReadConns int
+}
+
+// DBConfig 是 InitDB 使用的参数；cmd/server 在调用 InitDB 之前根据环境变量修改。
+var DBConfig = SQLiteConfig{
+	JournalMode:  "WAL",
+	Synchronous:  "NORMAL",
+	BusyTimeout:  5 * time.Second,
+	ForeignKeys:  true,
+	MaxReadConns: 4,
+}
+
+var (
+	journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	syncLevels   = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// Validate 检查参数是否合法，返回的错误列出所有无效项。
+func (c SQLiteConfig) Validate() error {
+	var errs []error
+	if !oneOf(c.JournalMode, journalModes) {
+		errs = append(errs, fmt.Errorf("journal mode must be one of %s", strings.Join(journalModes, ", ")))
+	}
+	if !oneOf(c.Synchronous, syncLevels) {
+		errs = append(errs, fmt.Errorf("synchronous must be one of %s", strings.Join(syncLevels, ", ")))
+	}
+	if c.BusyTimeout < 0 {
+		errs = append(errs, errors.New("busy timeout cannot be negative"))
+	}
+	if c.MaxReadConns < 1 {
+		errs = append(errs, errors.New("max read connections must be at least 1"))
+	}
+	return errors.Join(errs...)
+}
+
+// dsn 生成 go-sqlite3 的连接字符串；参数由驱动在每个新连接上执行，连接池扩容后的连接也一样。
+// readOnly 为 true 时连接设置 query_only，任何写操作都会失败；否则写事务以 BEGIN IMMEDIATE 开始，
+// 避免两个读事务同时升级为写事务时其中一个直接失败。
+func (c SQLiteConfig) dsn(path string, readOnly bool) string {
+	params := url.Values{}
+	params.Set("_journal_mode", c.JournalMode)
+	params.Set("_synchronous", c.Synchronous)
+	params.Set("_busy_timeout", fmt.Sprint(c.BusyTimeout.Milliseconds()))
+	params.Set("_foreign_keys", fmt.Sprint(c.ForeignKeys))
+	if readOnly {
+		params.Set("_query_only", "true")
+	} else {
+		params.Set("_txlock", "immediate")
+	}
+	return "file:" + path + "?" + params.Encode()
+}
+
+func oneOf(value string, allowed []string) bool {
+	for _, v := range allowed {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// TxMaxAttempts 是 WithTx 遇到 SQLITE_BUSY / SQLITE_LOCKED 时最多执行事务的次数（包括第一次）。
+var TxMaxAttempts = 5
+
+// TxRetryDelay 是第一次重试前的等待时间，之后每次翻倍。
+var TxRetryDelay = 10 * time.Millisecond
+
+// WithTx 在写连接池 DB 上执行事务，见 RunTx。
+func WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return RunTx(ctx, DB, fn)
+}
+
+// RunTx 在 db 上开启事务执行 fn：fn 返回 nil 时提交，返回错误或 panic 时回滚。
+// busy_timeout 到期后仍拿不到锁（例如另一个进程长时间持有写锁）时 SQLite 返回 SQLITE_BUSY，
+// 此时整个事务回滚后重新执行，因此 fn 可能被调用多次，不应在事务之外产生副作用。
+func RunTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	delay := TxRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, fn)
+		if err == nil || !IsBusy(err) || attempt >= TxMaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+func runTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsBusy 判断错误是否为 SQLite 的锁冲突（SQLITE_BUSY / SQLITE_LOCKED 及其扩展码），这类错误重试即可。
+func IsBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
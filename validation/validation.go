@@ -0,0 +1,320 @@
+// Package validation 提供基于 struct tag 的声明式校验：
+//
+//	type Product struct {
+//		Name  string  `json:"name" validate:"required,max=255"`
+//		Price float64 `json:"price" validate:"gt=0"`
+//	}
+//
+// Struct 会一次性返回所有违反的规则（而不是遇到第一个就停止），
+// 每条错误包含字段名（取 json tag）、规则代码与可读信息，便于客户端逐字段展示。
+//
+// 内置规则：
+//   - required：字符串非空白、数值非零、指针/切片/map 非空
+//   - min=N / max=N：数值的上下限；作用于字符串、切片时表示长度（按字符数）
+//   - gt=N / lt=N：数值严格大于 / 小于
+//   - len=N：长度恰好为 N
+//   - regex=PATTERN：字符串匹配正则（PATTERN 中不能包含逗号）
+//   - oneof=A B C：字符串取值只能是空格分隔的候选值之一
+//
+// 业务相关的规则可以通过 RegisterRule 注册后在 tag 中按名字使用。
+//
+// 内置规则的错误信息来自 i18n 消息目录，默认使用英文，可以通过 Errors.Localize 按请求语言重新渲染。
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang-starter/i18n"
+)
+
+// FieldError 描述单个字段的一条校验错误。
+type FieldError struct {
+	// Field：字段路径，例如 "name"、"products[1].price"。
+	Field string `json:"field"`
+	// Code：规则代码，例如 "required"、"gt"、"max"。
+	Code string `json:"code"`
+	// Message：可读的错误信息（默认语言），可以通过 Errors.Localize 换成其他语言。
+	Message string `json:"message"`
+	// Key / Params：内置规则对应的消息 key 与参数（字段名在渲染时以 {field} 传入）；自定义规则没有 key。
+	Key    string            `json:"-"`
+	Params map[string]string `json:"-"`
+}
+
+// NewFieldError 创建一条使用消息目录 key 的字段错误，信息按默认语言渲染；param 是规则参数（例如长度上限）。
+// 供 struct tag 之外的校验来源（例如 OpenAPI 文档）产生与内置规则一致的错误。
+func NewFieldError(field, code, key, param string) FieldError {
+	fe := FieldError{Field: field, Code: code, Key: key, Params: map[string]string{"param": param}}
+	fe.Message = i18n.Format(i18n.Default, key, fe.messageParams())
+	return fe
+}
+
+// Errors 是一组校验错误；为空表示校验通过。
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, fe.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Prefix 给所有错误的字段路径加上前缀，例如 Prefix("products[1]") 把 "name" 变成 "products[1].name"。
+func (e Errors) Prefix(prefix string) Errors {
+	prefixed := make(Errors, len(e))
+	for i, fe := range e {
+		fe.Message = strings.Replace(fe.Message, fe.Field, prefix+"."+fe.Field, 1)
+		fe.Field = prefix + "." + fe.Field
+		prefixed[i] = fe
+	}
+	return prefixed
+}
+
+// Localize 按 lang 重新渲染内置规则的错误信息；自定义规则的信息保持不变。
+func (e Errors) Localize(lang i18n.Lang) Errors {
+	localized := make(Errors, len(e))
+	for i, fe := range e {
+		if fe.Key != "" {
+			fe.Message = i18n.Format(lang, fe.Key, fe.messageParams())
+		}
+		localized[i] = fe
+	}
+	return localized
+}
+
+func (fe FieldError) messageParams() map[string]string {
+	params := map[string]string{"field": fe.Field}
+	for k, v := range fe.Params {
+		params[k] = v
+	}
+	return params
+}
+
+// RuleFunc 是自定义规则：value 为字段值，param 为 tag 中 "=" 后的参数。
+// 校验失败时返回可读信息（不含字段名，例如 "must be a valid SKU"）与 ok=false。
+type RuleFunc func(value reflect.Value, param string) (message string, ok bool)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{}
+
+	regexMu    sync.Mutex
+	regexCache = map[string]*regexp.Regexp{}
+)
+
+// RegisterRule 注册一个自定义规则；与内置规则同名时会覆盖内置实现。
+func RegisterRule(name string, fn RuleFunc) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = fn
+}
+
+// Struct 校验结构体（或其指针）上所有带 validate tag 的导出字段。
+func Struct(v any) Errors {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" || !field.IsExported() {
+			continue
+		}
+		errs = append(errs, checkField(fieldName(field), rv.Field(i), tag)...)
+	}
+	return errs
+}
+
+// Slice 逐个校验切片中的结构体，字段路径形如 name[i].field。
+func Slice[T any](name string, items []T) Errors {
+	var errs Errors
+	for i := range items {
+		if itemErrs := Struct(&items[i]); len(itemErrs) > 0 {
+			errs = append(errs, itemErrs.Prefix(fmt.Sprintf("%s[%d]", name, i))...)
+		}
+	}
+	return errs
+}
+
+// fieldName 取 json tag 中的名字作为字段名，没有 json tag 时使用 Go 字段名。
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// checkField 按 tag 中声明的顺序检查每条规则；required 失败时不再检查其余规则。
+func checkField(name string, value reflect.Value, tag string) Errors {
+	var errs Errors
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		ruleName, param, _ := strings.Cut(rule, "=")
+
+		key, message, ok := checkRule(value, ruleName, param)
+		if ok {
+			continue
+		}
+		if key != "" {
+			errs = append(errs, NewFieldError(name, ruleName, key, param))
+		} else {
+			errs = append(errs, FieldError{Field: name, Code: ruleName, Message: name + " " + message})
+		}
+		if ruleName == "required" {
+			break
+		}
+	}
+	return errs
+}
+
+// checkRule 检查单条规则；失败时内置规则返回消息 key，自定义规则返回可读信息。
+func checkRule(value reflect.Value, rule, param string) (key, message string, ok bool) {
+	rulesMu.RLock()
+	custom, ok := rules[rule]
+	rulesMu.RUnlock()
+	if ok {
+		message, ok := custom(value, param)
+		return "", message, ok
+	}
+
+	switch rule {
+	case "required":
+		if isZero(value) {
+			return "validation.required", "", false
+		}
+	case "min", "max", "gt", "lt":
+		key, ok := checkBound(value, rule, param)
+		return key, "", ok
+	case "len":
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			panic(fmt.Sprintf("validation: invalid len param %q", param))
+		}
+		if length(value) != n {
+			return "validation.len", "", false
+		}
+	case "regex":
+		if value.Kind() != reflect.String {
+			panic("validation: regex rule requires a string field")
+		}
+		if !compileRegex(param).MatchString(value.String()) {
+			return "validation.regex", "", false
+		}
+	case "oneof":
+		if value.Kind() != reflect.String {
+			panic("validation: oneof rule requires a string field")
+		}
+		for _, candidate := range strings.Fields(param) {
+			if value.String() == candidate {
+				return "", "", true
+			}
+		}
+		return "validation.oneof", "", false
+	default:
+		panic(fmt.Sprintf("validation: unknown rule %q", rule))
+	}
+	return "", "", true
+}
+
+// checkBound 处理 min/max/gt/lt：数值比较值本身，字符串/切片/map 比较长度。失败时返回消息 key。
+func checkBound(value reflect.Value, rule, param string) (string, bool) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid %s param %q", rule, param))
+	}
+
+	var actual float64
+	isLength := false
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	default:
+		actual = float64(length(value))
+		isLength = true
+	}
+
+	switch rule {
+	case "min":
+		if actual < limit {
+			if isLength {
+				return "validation.min.length", false
+			}
+			if limit == 0 {
+				return "validation.min.nonnegative", false
+			}
+			return "validation.min", false
+		}
+	case "max":
+		if actual > limit {
+			if isLength {
+				return "validation.max.length", false
+			}
+			return "validation.max", false
+		}
+	case "gt":
+		if actual <= limit {
+			return "validation.gt", false
+		}
+	case "lt":
+		if actual >= limit {
+			return "validation.lt", false
+		}
+	}
+	return "", true
+}
+
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		return value.IsNil() || (value.Kind() != reflect.Pointer && value.Kind() != reflect.Interface && value.Len() == 0)
+	default:
+		return value.IsZero()
+	}
+}
+
+func length(value reflect.Value) int {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return value.Len()
+	}
+	panic(fmt.Sprintf("validation: length rules are not supported on %s", value.Kind()))
+}
+
+func compileRegex(pattern string) *regexp.Regexp {
+	regexMu.Lock()
+	defer regexMu.Unlock()
+	re, ok := regexCache[pattern]
+	if !ok {
+		re = regexp.MustCompile(pattern)
+		regexCache[pattern] = re
+	}
+	return re
+}
@@ -0,0 +1,51 @@
+// Package version 保存构建信息。发布构建时通过 ldflags 注入，例如：
+//
+//	go build -ldflags "-X golang-starter/version.Version=v1.2.0 -X golang-starter/version.Commit=$(git rev-parse HEAD) \
+//	    -X golang-starter/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+//
+// 未注入时 Commit 与 BuildTime 取 go 工具链记录的 VCS 信息（如果有）。
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// 由 ldflags 注入的构建信息。
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info 是 /api/version 与 server version 输出的构建信息。
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	// Modified：构建时工作区是否有未提交的修改（仅在使用 VCS 信息时可知）。
+	Modified bool `json:"modified,omitempty"`
+}
+
+// Get 返回当前进程的构建信息。
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	return info
+}